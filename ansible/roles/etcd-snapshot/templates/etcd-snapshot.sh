@@ -0,0 +1,38 @@
+#!/bin/bash
+# Saves v3 snapshots of {{ etcd_name }} to {{ etcd_snapshot_dir }}, and prunes old snapshots.
+#   {{ etcd_snapshot_script }} save [NAME]
+#   {{ etcd_snapshot_script }} prune KEEP MAX_AGE_MINUTES
+# A KEEP or MAX_AGE_MINUTES of 0 disables pruning by count or by age.
+set -euo pipefail
+
+save() {
+  local name="${1:-$(date +%Y-%m-%d-%H-%M-%S)}"
+  mkdir -p {{ etcd_snapshot_dir }}
+  chmod 0700 {{ etcd_snapshot_dir }}
+  {{ bin_dir }}/docker run --rm --net=host -e ETCDCTL_API=3 \
+    --volume={{ etcd_install_dir }}:{{ etcd_install_dir }}:ro \
+    --volume={{ etcd_snapshot_dir }}:{{ etcd_snapshot_dir }} \
+    {{ images.etcd }} /usr/local/bin/etcdctl \
+    --endpoints=https://127.0.0.1:{{ etcd_service_client_port }} \
+    --cacert={{ etcd_certificates.ca }} \
+    --cert={{ etcd_certificates.etcd_client }} \
+    --key={{ etcd_certificates.etcd_client_key }} \
+    snapshot save {{ etcd_snapshot_dir }}/${name}.db
+  (cd {{ etcd_snapshot_dir }} && sha256sum ${name}.db > ${name}.db.sha256)
+}
+
+prune() {
+  local keep="$1" max_age_minutes="$2"
+  if [ "$keep" -gt 0 ]; then
+    ls -1t {{ etcd_snapshot_dir }}/*.db 2>/dev/null | tail -n +$((keep + 1)) | while read -r f; do rm -f "$f" "$f.sha256"; done
+  fi
+  if [ "$max_age_minutes" -gt 0 ]; then
+    find {{ etcd_snapshot_dir }} -name '*.db' -mmin +"$max_age_minutes" | while read -r f; do rm -f "$f" "$f.sha256"; done
+  fi
+}
+
+case "${1:-}" in
+  save) save "${2:-}" ;;
+  prune) prune "$2" "$3" ;;
+  *) echo "usage: $0 save [NAME] | prune KEEP MAX_AGE_MINUTES" >&2; exit 1 ;;
+esac
@@ -53,7 +53,12 @@ type ClusterCatalog struct {
 
 	KismaticPreflightCheckerLinux string `yaml:"kismatic_preflight_checker"`
 
-	NewNode string `yaml:"new_node"`
+	NewNode    string `yaml:"new_node"`
+	RemoveNode string `yaml:"remove_node"`
+
+	// etcd and master node vars
+	EtcdMemberAdd    bool `yaml:"etcd_member_add"`
+	UpdateKubeconfig bool `yaml:"update_kubeconfig"`
 
 	NFSVolumes []NFSVolume `yaml:"nfs_volumes"`
 
@@ -75,6 +80,24 @@ type ClusterCatalog struct {
 
 	OnlineUpgrade bool `yaml:"online_upgrade"`
 
+	// upgrade snapshot and rollback vars
+	UpgradeSnapshotName      string            `yaml:"upgrade_snapshot_name"`
+	UpgradeSnapshotDirectory string            `yaml:"upgrade_snapshot_dir"`
+	RollbackPackages         map[string]string `yaml:"rollback_packages"`
+
+	// etcd backup vars
+	EtcdBackupName          string `yaml:"etcd_backup_name"`
+	EtcdBackupDirectory     string `yaml:"etcd_backup_dir"`
+	EtcdBackupKeep          int    `yaml:"etcd_backup_keep"`
+	EtcdBackupMaxAgeMinutes int    `yaml:"etcd_backup_max_age_minutes"`
+	EtcdBackupSchedule      string `yaml:"etcd_backup_schedule"`
+	EtcdRestoreFile         string `yaml:"etcd_restore_file"`
+	EtcdRestoreName         string `yaml:"etcd_restore_name"`
+	EtcdCompact             bool   `yaml:"etcd_compact"`
+
+	// add-ons to deploy when applying add-ons independently of an installation
+	AddOnsToApply []string `yaml:"apply_addons"`
+
 	DiagnosticsDirectory string `yaml:"diagnostics_dir"`
 	DiagnosticsDateTime  string `yaml:"diagnostics_date_time"`
 
@@ -97,6 +120,10 @@ type ClusterCatalog struct {
 	CloudProvider string `yaml:"cloud_provider"`
 	CloudConfig   string `yaml:"cloud_config_local"`
 
+	// secrets encryption vars
+	EncryptionConfig string `yaml:"encryption_config_local"`
+	RewriteSecrets   bool   `yaml:"rewrite_secrets"`
+
 	DNS struct {
 		Enabled  bool
 		Provider string
@@ -171,6 +198,20 @@ type ClusterCatalog struct {
 	NodeLabels         map[string][]string          `yaml:"node_labels"`
 	NodeTaints         map[string][]string          `yaml:"node_taints"`
 	KubeletNodeOptions map[string]map[string]string `yaml:"kubelet_node_overrides"`
+
+	NodeManagedLabels map[string]string   `yaml:"node_managed_labels"`
+	NodeManagedTaints map[string]string   `yaml:"node_managed_taints"`
+	NodeLabelsRemove  map[string][]string `yaml:"node_labels_remove"`
+	NodeTaintsRemove  map[string][]string `yaml:"node_taints_remove"`
+
+	RebootTimeoutSeconds int `yaml:"reboot_timeout_seconds,omitempty"`
+
+	CertificateRotationRestarts []string `yaml:"certificate_rotation_restarts"`
+	CARotationPhase             string   `yaml:"ca_rotation_phase,omitempty"`
+	CABundle                    string   `yaml:"ca_bundle_local,omitempty"`
+
+	OSUpdateDirectory    string   `yaml:"os_update_dir,omitempty"`
+	OSUpdateHeldPackages []string `yaml:"os_update_held_packages,omitempty"`
 }
 
 type DirectLVMBlockDevice struct {
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/util"
@@ -20,18 +21,58 @@ type addNodeOpts struct {
 	OutputFormat             string
 	Verbose                  bool
 	SkipPreFlight            bool
+	FromFile                 string
 }
 
-var validRoles = []string{"worker", "ingress", "storage"}
+var validRoles = []string{"etcd", "master", "worker", "ingress", "storage"}
 
 // NewCmdAddNode returns the command for adding node to the cluster
 func NewCmdAddNode(out io.Writer, installOpts *installOpts) *cobra.Command {
 	opts := &addNodeOpts{}
 	cmd := &cobra.Command{
-		Use:     "add-node NODE_NAME NODE_IP [NODE_INTERNAL_IP]",
-		Short:   "add a new node to an existing Kubernetes cluster",
+		Use:   "add-node NODE_NAME NODE_IP [NODE_INTERNAL_IP]",
+		Short: "add a new node to an existing Kubernetes cluster",
+		Long: `Add a new node to an existing Kubernetes cluster.
+
+A node with the etcd role joins the existing etcd cluster as a new member. Members
+are added one at a time, and only if all the existing members are healthy.
+After adding an etcd or master node, the API servers are reconfigured one at a time
+to use the new etcd member, and the certificates of the master nodes are regenerated
+if they are no longer valid for the plan file. If the load balanced address of the
+master nodes has changed, the kubeconfig files are updated on all nodes.
+
+Use --from-file to add many worker, ingress and storage nodes in a single run. The
+file lists the nodes along with their roles, labels and taints:
+
+  nodes:
+  - host: worker05
+    ip: 10.0.0.5
+    internalip: 192.168.0.5
+    roles: [worker, ingress]
+    labels:
+      team: web
+    taints:
+    - key: dedicated
+      value: web
+      effect: NoSchedule
+
+Nodes without roles are added as workers. The pre-flight checks run on all the new
+nodes in parallel, and the nodes are then added in a single run limited to them.
+The plan file is updated once, with the nodes that were added successfully. Nodes
+of the file that are already in the plan file are skipped, so the command can be
+run again after fixing the nodes that failed.
+`,
 		Aliases: []string{"add-worker"},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.FromFile != "" {
+				if len(args) != 0 {
+					return cmd.Usage()
+				}
+				if len(opts.Roles) > 0 || len(opts.NodeLabels) > 0 {
+					return errors.New("the roles and labels of the nodes must be set in the nodes file when using --from-file")
+				}
+				return doAddNodes(out, installOpts.planFilename, opts)
+			}
 			if len(args) < 2 || len(args) > 3 {
 				return cmd.Usage()
 			}
@@ -64,13 +105,14 @@ func NewCmdAddNode(out io.Writer, installOpts *installOpts) *cobra.Command {
 			return doAddNode(out, installOpts.planFilename, opts, newNode)
 		},
 	}
-	cmd.Flags().StringSliceVar(&opts.Roles, "roles", []string{}, "roles separated by ',' (options \"worker\"|\"ingress\"|\"storage\")")
+	cmd.Flags().StringSliceVar(&opts.Roles, "roles", []string{}, "roles separated by ',' (options \"etcd\"|\"master\"|\"worker\"|\"ingress\"|\"storage\")")
 	cmd.Flags().StringSliceVarP(&opts.NodeLabels, "labels", "l", []string{}, "key=value pairs separated by ','")
 	cmd.Flags().StringVar(&opts.GeneratedAssetsDirectory, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.Flags().BoolVar(&opts.RestartServices, "restart-services", false, "force restart clusters services (Use with care)")
 	cmd.Flags().BoolVar(&opts.Verbose, "verbose", false, "enable verbose logging from the installation")
 	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
 	cmd.Flags().BoolVar(&opts.SkipPreFlight, "skip-preflight", false, "skip pre-flight checks, useful when rerunning kismatic")
+	cmd.Flags().StringVar(&opts.FromFile, "from-file", "", "path to a file that lists the nodes to add, along with their roles, labels and taints")
 	return cmd
 }
 
@@ -129,40 +171,158 @@ func doAddNode(out io.Writer, planFile string, opts *addNodeOpts, newNode instal
 	return nil
 }
 
-// returns an error if the plan contains a node that is "equivalent"
-// to the new node that is being added
-func ensureNodeIsNew(plan install.Plan, newNode install.Node) error {
-	for _, n := range plan.Worker.Nodes {
-		if n.Host == newNode.Host {
-			return fmt.Errorf("according to the plan file, the host name of the new node is already being used by another worker node")
+func doAddNodes(out io.Writer, planFile string, opts *addNodeOpts) error {
+	planner := &install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: planFile}
+	}
+	execOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.GeneratedAssetsDirectory,
+		OutputFormat:             opts.OutputFormat,
+		Verbose:                  opts.Verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, execOpts)
+	if err != nil {
+		return err
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read plan file: %v", err)
+	}
+	nodes, err := install.ReadNewNodesFile(opts.FromFile)
+	if err != nil {
+		return err
+	}
+	if ok, errs := install.ValidateNewNodes(nodes); !ok {
+		util.PrintValidationErrors(out, errs)
+		return errors.New("information provided about the new nodes is invalid")
+	}
+	newNodes, err := newNodesNotInPlan(out, *plan, nodes)
+	if err != nil {
+		return err
+	}
+	if len(newNodes) == 0 {
+		util.PrettyPrintOk(out, "All the nodes are already part of the cluster")
+		return nil
+	}
+	// add new nodes to the plan just for validation
+	validatePlan := install.AddNodesToPlan(*plan, newNodes)
+	if _, errs := install.ValidatePlan(&validatePlan); errs != nil {
+		util.PrintValidationErrors(out, errs)
+		return errors.New("the plan file failed validation")
+	}
+	sshErrs := []error{}
+	for i := range newNodes {
+		nodeSSHCon := &install.SSHConnection{
+			SSHConfig: &plan.Cluster.SSH,
+			Node:      &newNodes[i].Node,
 		}
-		if n.IP == newNode.IP {
-			return fmt.Errorf("according to the plan file, the IP of the new node is already being used by another worker node")
+		if _, errs := install.ValidateSSHConnection(nodeSSHCon, "New node"); errs != nil {
+			sshErrs = append(sshErrs, errs...)
 		}
-		if newNode.InternalIP != "" && n.InternalIP == newNode.InternalIP {
-			return fmt.Errorf("according to the plan file, the internal IP of the new node is already being used by another worker node")
+	}
+	if len(sshErrs) != 0 {
+		util.PrintValidationErrors(out, sshErrs)
+		return errors.New("could not establish SSH connection to the new nodes")
+	}
+	if !opts.SkipPreFlight {
+		util.PrintHeader(out, "Running Pre-Flight Checks On New Nodes", '=')
+		failed, err := executor.RunNewNodesPreFlightCheck(*plan, newNodes)
+		if err != nil {
+			if len(failed) > 0 {
+				fmt.Fprintf(out, "\nThe pre-flight checks failed on nodes: %s\n", strings.Join(failed, ", "))
+			}
+			return err
 		}
 	}
-	for _, n := range plan.Ingress.Nodes {
-		if n.Host == newNode.Host {
-			return fmt.Errorf("according to the plan file, the host name of the new node is already being used by another ingress node")
+	updatedPlan, results, err := executor.AddNodes(plan, newNodes, opts.RestartServices)
+	// the plan is written once, with all the nodes that were added
+	if updatedPlan != nil {
+		if writeErr := planner.Write(updatedPlan); writeErr != nil {
+			return fmt.Errorf("error updating plan file to include the new nodes: %v", writeErr)
 		}
-		if n.IP == newNode.IP {
-			return fmt.Errorf("according to the plan file, the IP of the new node is already being used by another ingress node")
+	}
+	if len(results) > 0 {
+		fmt.Fprintln(out)
+		if printErr := printAddNodeResults(out, results); printErr != nil {
+			return printErr
 		}
-		if newNode.InternalIP != "" && n.InternalIP == newNode.InternalIP {
-			return fmt.Errorf("according to the plan file, the internal IP of the new node is already being used by another ingress node")
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return errors.New("some of the nodes could not be added to the cluster")
 		}
 	}
-	for _, n := range plan.Storage.Nodes {
-		if n.Host == newNode.Host {
-			return fmt.Errorf("according to the plan file, the host name of the new node is already being used by another storage node")
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The nodes were added to the cluster successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+// returns the nodes that are not in the plan yet. A node that is already in the plan
+// is skipped, unless it conflicts with a different node of the plan.
+func newNodesNotInPlan(out io.Writer, plan install.Plan, nodes []install.NewNode) ([]install.NewNode, error) {
+	newNodes := []install.NewNode{}
+	for _, n := range nodes {
+		var exists bool
+		for _, pn := range plan.GetUniqueNodes() {
+			if pn.Equal(n.Node) {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			util.PrettyPrintWarn(out, "Skipping node %q, it is already part of the cluster", n.Host)
+			continue
 		}
-		if n.IP == newNode.IP {
-			return fmt.Errorf("according to the plan file, the IP of the new node is already being used by another storage node")
+		if err := ensureNodeIsNew(plan, n.Node); err != nil {
+			return nil, fmt.Errorf("node %q: %v", n.Host, err)
 		}
-		if newNode.InternalIP != "" && n.InternalIP == newNode.InternalIP {
-			return fmt.Errorf("according to the plan file, the internal IP of the new node is already being used by another storage node")
+		newNodes = append(newNodes, n)
+	}
+	return newNodes, nil
+}
+
+func printAddNodeResults(out io.Writer, results []install.AddNodeResult) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Node\tRoles\tResult\n")
+	for _, r := range results {
+		result := "added"
+		if r.Err != nil {
+			result = fmt.Sprintf("failed: %v", r.Err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Node.Host, strings.Join(r.Roles, ","), result)
+	}
+	return w.Flush()
+}
+
+// returns an error if the plan contains a node that is "equivalent"
+// to the new node that is being added
+func ensureNodeIsNew(plan install.Plan, newNode install.Node) error {
+	groups := []struct {
+		role  string
+		nodes []install.Node
+	}{
+		{"etcd", plan.Etcd.Nodes},
+		{"master", plan.Master.Nodes},
+		{"worker", plan.Worker.Nodes},
+		{"ingress", plan.Ingress.Nodes},
+		{"storage", plan.Storage.Nodes},
+	}
+	for _, g := range groups {
+		for _, n := range g.nodes {
+			if n.Host == newNode.Host {
+				return fmt.Errorf("according to the plan file, the host name of the new node is already being used by another %s node", g.role)
+			}
+			if n.IP == newNode.IP {
+				return fmt.Errorf("according to the plan file, the IP of the new node is already being used by another %s node", g.role)
+			}
+			if newNode.InternalIP != "" && n.InternalIP == newNode.InternalIP {
+				return fmt.Errorf("according to the plan file, the internal IP of the new node is already being used by another %s node", g.role)
+			}
 		}
 	}
 	return nil
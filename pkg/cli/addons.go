@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type addOnsOpts struct {
+	generatedAssetsDir string
+	planFile           string
+}
+
+// NewCmdAddOns returns the command for managing the cluster add-ons
+func NewCmdAddOns(out io.Writer) *cobra.Command {
+	opts := addOnsOpts{}
+	cmd := &cobra.Command{
+		Use:   "addons",
+		Short: "Manage the add-ons of your Kubernetes cluster",
+		Long: `Manage the add-ons of your Kubernetes cluster.
+
+The add-ons are: ` + strings.Join(install.AddOnNames(), ", ") + `.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	cmd.AddCommand(NewCmdAddOnsStatus(out, &opts))
+	cmd.AddCommand(NewCmdAddOnsApply(out, &opts))
+	return cmd
+}
+
+type addOnsStatusOpts struct {
+	outputFormat string
+}
+
+// NewCmdAddOnsStatus returns the command for printing the status of the add-ons
+func NewCmdAddOnsStatus(out io.Writer, opts *addOnsOpts) *cobra.Command {
+	statusOpts := addOnsStatusOpts{}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the deployed add-on versions and their drift from the plan file",
+		Long: `Print the deployed add-on versions and their drift from the plan file.
+
+An add-on has drifted when it is enabled in the plan file but not deployed,
+when it is disabled in the plan file but deployed, or when its configuration
+(e.g. the number of DNS replicas or the dashboard service type) does not match
+the plan file. Use "kismatic addons apply" to reconcile the add-ons.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doAddOnsStatus(out, *opts, statusOpts)
+		},
+	}
+	cmd.Flags().StringVarP(&statusOpts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	return cmd
+}
+
+func doAddOnsStatus(out io.Writer, opts addOnsOpts, statusOpts addOnsStatusOpts) error {
+	if statusOpts.outputFormat != "simple" && statusOpts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", statusOpts.outputFormat)
+	}
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	// Keep stdout clean when printing JSON
+	status := out
+	if statusOpts.outputFormat == "json" {
+		status = os.Stderr
+	}
+	kubeClient, err := kubernetesClient(status, plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	statuses := install.GetAddOnStatuses(*plan, kubeClient)
+
+	if statusOpts.outputFormat == "json" {
+		b, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling add-on status: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	}
+	return printAddOnStatuses(out, statuses)
+}
+
+func printAddOnStatuses(out io.Writer, statuses []install.AddOnStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tEnabled\tDeployed\tVersion\tDrift\n")
+	for _, s := range statuses {
+		drift := "none"
+		if len(s.Drift) > 0 {
+			drift = strings.Join(s.Drift, "; ")
+		}
+		if s.Note != "" {
+			drift = s.Note
+		}
+		version := s.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, yesNo(s.Enabled), yesNo(s.Deployed), version, drift)
+	}
+	return w.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+type addOnsApplyOpts struct {
+	verbose      bool
+	outputFormat string
+	dryRun       bool
+}
+
+// NewCmdAddOnsApply returns the command for deploying add-ons
+func NewCmdAddOnsApply(out io.Writer, opts *addOnsOpts) *cobra.Command {
+	applyOpts := addOnsApplyOpts{}
+	cmd := &cobra.Command{
+		Use:   "apply [NAME...]",
+		Short: "Deploy the given add-ons according to the plan file",
+		Long: `Deploy the given add-ons according to the plan file.
+
+The add-ons are (re)deployed using the configuration in the plan file, without
+running a full installation. When no add-ons are given, all the add-ons that are
+enabled in the plan file are deployed.
+
+The add-ons are: ` + strings.Join(install.AddOnNames(), ", ") + `.
+`,
+		Example: `  # Scale DNS after changing add_ons.dns.options.replicas in the plan file
+  kismatic addons apply dns
+
+  # Redeploy the dashboard and heapster
+  kismatic addons apply dashboard heapster`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doAddOnsApply(out, *opts, applyOpts, args)
+		},
+	}
+	cmd.Flags().BoolVar(&applyOpts.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&applyOpts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().BoolVar(&applyOpts.dryRun, "dry-run", false, "simulate the deployment of the add-ons, but don't make any changes to the cluster")
+	return cmd
+}
+
+func doAddOnsApply(out io.Writer, opts addOnsOpts, applyOpts addOnsApplyOpts, names []string) error {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		names = install.EnabledAddOns(*plan)
+	}
+	if err = install.ValidateAddOnNames(*plan, names); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             applyOpts.outputFormat,
+		Verbose:                  applyOpts.verbose,
+		DryRun:                   applyOpts.dryRun,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	util.PrintHeader(out, fmt.Sprintf("Apply Add-Ons: %s", strings.Join(names, ", ")), '=')
+	if err := executor.ApplyAddOns(*plan, names); err != nil {
+		return fmt.Errorf("error applying add-ons: %v", err)
+	}
+	if !applyOpts.dryRun {
+		fmt.Fprintln(out)
+		util.PrintColor(out, util.Green, "The add-ons were applied successfully!\n")
+		fmt.Fprintln(out)
+	}
+	return nil
+}
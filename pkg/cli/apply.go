@@ -104,6 +104,11 @@ func (c *applyCmd) run() error {
 		return fmt.Errorf("error installing: %v", err)
 	}
 
+	// Generate the encryption configuration of the secrets
+	if err := install.GenerateEncryptionConfig(plan, c.generatedAssetsDir); err != nil {
+		return fmt.Errorf("error generating encryption configuration: %v", err)
+	}
+
 	// Generate kubeconfig
 	util.PrintHeader(c.out, "Generating Kubeconfig File", '=')
 	err = install.GenerateKubeconfig(plan, c.generatedAssetsDir)
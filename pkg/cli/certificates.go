@@ -17,6 +17,11 @@ func NewCmdCertificates(out io.Writer) *cobra.Command {
 	}
 
 	cmd.AddCommand(NewCmdGenerate(out))
+	cmd.AddCommand(NewCmdList(out))
+	cmd.AddCommand(NewCmdRotate(out))
+	cmd.AddCommand(NewCmdRotateCA(out))
+	cmd.AddCommand(NewCmdExportCSRs(out))
+	cmd.AddCommand(NewCmdImport(out))
 
 	return cmd
 }
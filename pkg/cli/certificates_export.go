@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesExportOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	nodesFile          string
+}
+
+// NewCmdExportCSRs creates a new certificates export-csrs command
+func NewCmdExportCSRs(out io.Writer) *cobra.Command {
+	opts := &certificatesExportOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "export-csrs DIR [options]",
+		Short: "Export the certificate signing requests of the cluster certificates, to be signed outside of kismatic",
+		Long: `Export the certificate signing requests of the cluster certificates, to be signed outside of kismatic.
+
+A certificate signing request is written to DIR for every certificate of the cluster that
+does not exist in the --generated-assets-dir yet, with the common name, subject alternative
+names and organizations kismatic expects. The private keys are generated in the
+--generated-assets-dir, and never leave it. Running the command again reuses the keys.
+
+Once signed, the certificates are added with "kismatic certificates import".
+
+Use --nodes-file to export the certificate signing requests of the nodes that are about to
+be added with "kismatic add-node --from-file".
+`,
+		Example: `  # Export the certificate signing requests of the cluster
+  kismatic certificates export-csrs csrs/`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doCertificatesExportCSRs(out, args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.nodesFile, "nodes-file", "", "path to a file that lists the nodes to add, as used by add-node --from-file")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
+
+	return cmd
+}
+
+func doCertificatesExportCSRs(out io.Writer, dir string, opts *certificatesExportOpts) error {
+	plan, err := certificatesPlan(out, opts.planFile, opts.nodesFile)
+	if err != nil {
+		return err
+	}
+	pki := &install.LocalPKI{
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	exported, err := pki.ExportCertificateRequests(plan, dir)
+	if err != nil {
+		return err
+	}
+	if len(exported) == 0 {
+		util.PrettyPrintOk(out, "All the certificates of the cluster exist in %q", opts.generatedAssetsDir)
+		return nil
+	}
+	for _, name := range exported {
+		util.PrettyPrintOk(out, "Exported certificate signing request %q", name)
+	}
+	fmt.Fprintf(out, "\nSign the requests, and import the certificates with \"kismatic certificates import\".\n")
+	return nil
+}
+
+// returns the plan, including the nodes of the nodes file
+func certificatesPlan(out io.Writer, planFile string, nodesFile string) (*install.Plan, error) {
+	planner := install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return nil, planFileNotFoundErr{filename: planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %q: %v", planFile, err)
+	}
+	if nodesFile == "" {
+		return plan, nil
+	}
+	nodes, err := install.ReadNewNodesFile(nodesFile)
+	if err != nil {
+		return nil, err
+	}
+	if ok, errs := install.ValidateNewNodes(nodes); !ok {
+		util.PrintValidationErrors(out, errs)
+		return nil, errors.New("information provided about the new nodes is invalid")
+	}
+	newNodes, err := newNodesNotInPlan(out, *plan, nodes)
+	if err != nil {
+		return nil, err
+	}
+	updatedPlan := install.AddNodesToPlan(*plan, newNodes)
+	return &updatedPlan, nil
+}
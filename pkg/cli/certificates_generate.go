@@ -17,6 +17,7 @@ type certificatesGenerateOpts struct {
 	organizations      []string
 	overwrite          bool
 	generatedAssetsDir string
+	planFile           string
 }
 
 // NewCmdGenerate creates a new certificates generate command
@@ -52,11 +53,16 @@ func NewCmdGenerate(out io.Writer) *cobra.Command {
 	cmd.Flags().StringSliceVar(&opts.organizations, "organizations", []string{}, "comma-separated list of names that should be included in the certificate's organization field.")
 	cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, "overwrite existing certificate if it already exists in the target directory.")
 	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
 
 	return cmd
 }
 
 func doCertificatesGenerate(name string, opts *certificatesGenerateOpts, out io.Writer) error {
+	plan, err := certificatesPlan(out, opts.planFile, "")
+	if err != nil {
+		return err
+	}
 	ansibleDir := "ansible"
 	certsDir := filepath.Join(opts.generatedAssetsDir, "keys")
 	pki := &install.LocalPKI{
@@ -72,8 +78,10 @@ func doCertificatesGenerate(name string, opts *certificatesGenerateOpts, out io.
 	if commonName == "" {
 		commonName = name
 	}
-	validityPeriod := fmt.Sprintf("%dh", opts.validityPeriod*24)
-	exists, err := pki.GenerateCertificate(name, validityPeriod, commonName, opts.subjAltNames, opts.organizations, ca, opts.overwrite)
+	// the key algorithm and subject are the ones of the plan file
+	certs := plan.Cluster.Certificates
+	certs.Expiry = fmt.Sprintf("%dh", opts.validityPeriod*24)
+	exists, err := pki.GenerateCertificate(name, certs, commonName, opts.subjAltNames, opts.organizations, ca, opts.overwrite)
 	if err != nil {
 		return err
 	}
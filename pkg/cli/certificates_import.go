@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesImportOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	nodesFile          string
+}
+
+// NewCmdImport creates a new certificates import command
+func NewCmdImport(out io.Writer) *cobra.Command {
+	opts := &certificatesImportOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "import DIR [options]",
+		Short: "Import the certificates signed for the requests of \"kismatic certificates export-csrs\"",
+		Long: `Import the certificates signed for the requests of "kismatic certificates export-csrs".
+
+DIR contains a certificate for each exported request, named after the request, e.g.
+"admin.pem" for "admin.csr", along with the certificates of the CAs that signed them:
+  - ca.pem: the cluster CA, optionally followed by the CAs of its chain up to the root CA
+  - proxy-client-ca.pem: the CA that signed the proxy-client certificate
+
+The CA certificates can be omitted once imported. The proxy-client CA must not be the
+cluster CA, as the API server trusts any certificate of the proxy-client CA to
+authenticate users on behalf of the aggregated API servers.
+
+The certificates are validated against the cluster, their private key, their CA and the
+extended key usages of their component, and placed in the --generated-assets-dir. Nothing is imported if any certificate is not valid.
+The cluster can then be installed without the keys of the CAs.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doCertificatesImport(out, args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.nodesFile, "nodes-file", "", "path to a file that lists the nodes to add, as used by add-node --from-file")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
+
+	return cmd
+}
+
+func doCertificatesImport(out io.Writer, dir string, opts *certificatesImportOpts) error {
+	plan, err := certificatesPlan(out, opts.planFile, opts.nodesFile)
+	if err != nil {
+		return err
+	}
+	pki := &install.LocalPKI{
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	imported, errs := pki.ImportCertificates(plan, dir)
+	if len(errs) > 0 {
+		util.PrettyPrintErr(out, "Validating certificates of %q", dir)
+		util.PrintValidationErrors(out, errs)
+		return errors.New("the certificates failed validation, nothing was imported")
+	}
+	for _, name := range imported {
+		util.PrettyPrintOk(out, "Imported certificate %q", name)
+	}
+	fmt.Fprintf(out, "\nThe certificates were imported in %q.\n", opts.generatedAssetsDir)
+	return nil
+}
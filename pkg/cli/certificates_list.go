@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesListOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	expiringWithin     time.Duration
+	remote             bool
+	outputFormat       string
+}
+
+// NewCmdList creates a new certificates list command
+func NewCmdList(out io.Writer) *cobra.Command {
+	opts := &certificatesListOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "list [options]",
+		Short: "List the cluster certificates in the --generated-assets-dir, and when they expire",
+		Long: `List the cluster certificates in the --generated-assets-dir, and when they expire.
+
+The subject, subject alternative names, issuer and expiration date of every certificate
+are listed. Certificates that expire within --expiring-within are flagged.
+
+With --remote, the certificates that are deployed on the nodes of the plan file are
+compared with the certificates in the --generated-assets-dir, over SSH.
+
+The command fails if a certificate is expiring, or if a deployed certificate does not
+match, unless the output format is "prometheus". The "prometheus" output format can
+be written to the textfile directory of the Prometheus node exporter.
+`,
+		Example: `  # Write the certificate expiration metrics for the node exporter
+  kismatic certificates list -o prometheus > /var/lib/node_exporter/kismatic_certificates.prom`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doCertificatesList(out, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().DurationVar(&opts.expiringWithin, "expiring-within", 30*24*time.Hour, "flag the certificates that expire within this duration")
+	cmd.Flags().BoolVar(&opts.remote, "remote", false, "compare the certificates with the certificates deployed on the nodes of the plan file")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "table", `output format (options "table"|"json"|"prometheus")`)
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
+
+	return cmd
+}
+
+func doCertificatesList(out io.Writer, opts *certificatesListOpts) error {
+	if !util.Contains(opts.outputFormat, []string{"table", "json", "prometheus"}) {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	certsDir := filepath.Join(opts.generatedAssetsDir, "keys")
+	certs, err := install.ListCertificates(certsDir, opts.expiringWithin, time.Now())
+	if err != nil {
+		return err
+	}
+	if opts.remote {
+		planner := install.FilePlanner{File: opts.planFile}
+		if !planner.PlanExists() {
+			return planFileNotFoundErr{filename: opts.planFile}
+		}
+		plan, err := planner.Read()
+		if err != nil {
+			return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+		}
+		certs = install.CheckDeployedCertificates(plan, certs)
+	}
+
+	switch opts.outputFormat {
+	case "json":
+		b, err := json.MarshalIndent(certs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling certificates: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+	case "prometheus":
+		printCertificatesPrometheus(out, certs, opts.remote)
+		return nil
+	default:
+		printCertificates(out, certs, opts.remote)
+	}
+
+	var expiring int
+	for _, c := range certs {
+		if c.Expiring {
+			expiring++
+		}
+	}
+	mismatched := install.MismatchedCertificates(certs)
+	if expiring > 0 || len(mismatched) > 0 {
+		return fmt.Errorf("%d certificate(s) expiring within %s, %d deployed certificate(s) not matching", expiring, opts.expiringWithin, len(mismatched))
+	}
+	return nil
+}
+
+func printCertificates(out io.Writer, certs []install.CertificateInfo, remote bool) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tSubject\tIssuer\tExpires\tStatus\tSANs\n")
+	for _, c := range certs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, c.Subject, c.Issuer, c.NotAfter.Format("2006-01-02"), certificateStatus(c), orDash(strings.Join(c.SANs, ",")))
+	}
+	w.Flush()
+	if !remote {
+		return
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Host\tPath\tCertificate\tMatch\n")
+	for _, c := range certs {
+		for _, d := range c.Deployed {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Host, d.Path, c.Name, yesNo(d.Match))
+		}
+	}
+	w.Flush()
+	for _, c := range certs {
+		for _, d := range c.Deployed {
+			if d.Error != "" {
+				util.PrettyPrintErr(out, "%s: %s: %s", d.Host, d.Path, d.Error)
+			}
+		}
+	}
+}
+
+func certificateStatus(c install.CertificateInfo) string {
+	switch {
+	case c.Expired:
+		return "EXPIRED"
+	case c.Expiring:
+		return fmt.Sprintf("EXPIRING in %dd", int(time.Until(c.NotAfter).Hours()/24))
+	default:
+		return "OK"
+	}
+}
+
+// writes the certificates in the Prometheus text exposition format
+func printCertificatesPrometheus(out io.Writer, certs []install.CertificateInfo, remote bool) {
+	fmt.Fprintln(out, "# HELP kismatic_certificate_expiration_timestamp_seconds The time at which the certificate expires, in seconds since the epoch.")
+	fmt.Fprintln(out, "# TYPE kismatic_certificate_expiration_timestamp_seconds gauge")
+	for _, c := range certs {
+		fmt.Fprintf(out, "kismatic_certificate_expiration_timestamp_seconds{name=%q,subject=%q,issuer=%q} %d\n", c.Name, c.Subject, c.Issuer, c.NotAfter.Unix())
+	}
+	fmt.Fprintln(out, "# HELP kismatic_certificate_expiring Whether the certificate expires within the threshold.")
+	fmt.Fprintln(out, "# TYPE kismatic_certificate_expiring gauge")
+	for _, c := range certs {
+		fmt.Fprintf(out, "kismatic_certificate_expiring{name=%q} %d\n", c.Name, boolToInt(c.Expiring))
+	}
+	if !remote {
+		return
+	}
+	fmt.Fprintln(out, "# HELP kismatic_certificate_deployed_match Whether the certificate deployed on the node matches the generated certificate.")
+	fmt.Fprintln(out, "# TYPE kismatic_certificate_deployed_match gauge")
+	for _, c := range certs {
+		for _, d := range c.Deployed {
+			fmt.Fprintf(out, "kismatic_certificate_deployed_match{name=%q,host=%q,path=%q} %d\n", c.Name, d.Host, d.Path, boolToInt(d.Match))
+		}
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesRotateOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	components         []string
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdRotate creates a new certificates rotate command
+func NewCmdRotate(out io.Writer) *cobra.Command {
+	opts := &certificatesRotateOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate [options]",
+		Short: "Regenerate the cluster certificates from the existing CA, and roll them out one node at a time",
+		Long: fmt.Sprintf(`Regenerate the cluster certificates from the existing CA, and roll them out one node at a time.
+
+The certificates of the selected components are regenerated in the --generated-assets-dir,
+signed by the existing certificate authority. They are then deployed to the etcd nodes, the
+master nodes and the other nodes, one node at a time. On each node, the components are
+restarted in order: etcd, kube-apiserver, kube-controller-manager, kube-scheduler and the kubelet.
+The rollout stops at the first node where the etcd cluster, the control plane or the node is
+not healthy once the components are back.
+
+Components: %s
+
+The API server also uses the etcd client certificate, and is restarted when the etcd
+certificates are rotated. Rotating the admin certificate regenerates the kubeconfig file
+in the --generated-assets-dir.
+
+The CA and service account certificates are not rotated.
+`, strings.Join(install.CertificateComponents(), ", ")),
+		Example: `  # Rotate the certificates of all the components
+  kismatic certificates rotate
+
+  # Rotate the kubelet certificates
+  kismatic certificates rotate --component kubelet`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doCertificatesRotate(out, opts)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&opts.components, "component", install.CertificateComponents(), "rotate the certificates of these components")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
+
+	return cmd
+}
+
+func doCertificatesRotate(out io.Writer, opts *certificatesRotateOpts) error {
+	if err := install.ValidateCertificateComponents(opts.components); err != nil {
+		return err
+	}
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = executor.RotateCertificates(*plan, opts.components); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The certificates were rotated successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type certificatesRotateCAOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	force              bool
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdRotateCA creates a new certificates rotate-ca command
+func NewCmdRotateCA(out io.Writer) *cobra.Command {
+	opts := &certificatesRotateCAOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate-ca [options]",
+		Short: "Replace the cluster CA, one phase at a time",
+		Long: `Replace the cluster CA, one phase at a time.
+
+The cluster CA is replaced in three phases. Every run of the command runs the next phase:
+  - trust: a new CA is generated, and the nodes trust both the old and new CAs
+  - reissue: the certificates and the kubeconfig are reissued from the new CA
+  - remove-old-ca: the nodes stop trusting the old CA
+
+In every phase, the certificates are rolled out one node at a time, restarting the etcd
+members, the control plane and the kubelet, and the phase is verified against the
+cluster once all the nodes are done. A phase that fails can be resumed by running the
+command again.
+
+The service account key pair is not replaced, so that the service account tokens remain
+valid. The CA of the tokens is updated by the controller manager, but the pods only read it
+when they start: the pods that mount a service account token must be restarted after the
+"trust" phase. The "reissue" phase does not start while such pods run, unless --force is set.
+
+The proxy-client CA is not replaced.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doCertificatesRotateCA(out, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.force, "force", false, "run the reissue phase even if pods still use the old CA")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
+
+	return cmd
+}
+
+func doCertificatesRotateCA(out io.Writer, opts *certificatesRotateCAOpts) error {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	state, err := install.ReadCARotationState(opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	phase := state.NextPhase()
+	util.PrintHeader(out, "Cluster CA Rotation", '=')
+	printCARotationPhases(out, phase)
+
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+
+	if phase == install.CARotationPhaseReissue {
+		if err = checkStaleCAPods(out, plan, opts.generatedAssetsDir, state, opts.force); err != nil {
+			return err
+		}
+	}
+
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = executor.RotateClusterCA(*plan); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The %s phase of the cluster CA rotation completed successfully!\n", phase)
+	switch phase {
+	case install.CARotationPhaseTrust:
+		fmt.Fprintln(out, "Restart the pods that mount a service account token, and run the command again to reissue the certificates.")
+	case install.CARotationPhaseReissue:
+		fmt.Fprintln(out, "Run the command again to remove the old CA.")
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+func printCARotationPhases(out io.Writer, next string) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Phase\tStatus\n")
+	status := "done"
+	for _, p := range install.CARotationPhases() {
+		if p == next {
+			fmt.Fprintf(w, "%s\t%s\n", p, "next")
+			status = "pending"
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", p, status)
+	}
+	w.Flush()
+}
+
+// the pods that started before the nodes trusted the new CA must be restarted
+// before the API server certificate is reissued
+func checkStaleCAPods(out io.Writer, plan *install.Plan, generatedAssetsDir string, state install.CARotationState, force bool) error {
+	util.PrintHeader(out, "Validate Pods Trust the New CA", '=')
+	client, err := kubernetesClient(out, plan, generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	pods, err := client.ListPods()
+	if err != nil {
+		return fmt.Errorf("error listing pods: %v", err)
+	}
+	stale := install.StaleCAPods(pods, state.TrustedAt)
+	if len(stale) == 0 {
+		util.PrettyPrintOk(out, "All the pods that mount a service account token trust the new CA")
+		return nil
+	}
+	for _, p := range stale {
+		util.PrettyPrintWarn(out, "Pod %s/%s started before the new CA was trusted", p.Namespace, p.Name)
+	}
+	if force {
+		util.PrettyPrintWarn(out, "\nIgnoring %d pod(s) that only trust the old CA", len(stale))
+		return nil
+	}
+	return fmt.Errorf("%d pod(s) only trust the old CA. Restart them, or use --force to reissue the certificates anyway", len(stale))
+}
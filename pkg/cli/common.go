@@ -2,7 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"io"
 
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/pflag"
 )
 
@@ -17,3 +21,21 @@ type planFileNotFoundErr struct {
 func (e planFileNotFoundErr) Error() string {
 	return fmt.Sprintf("Plan file not found at %q. If you don't have a plan file, you may generate one with 'kismatic install plan'", e.filename)
 }
+
+// kubernetesClient returns a client that talks to the API server directly, using the
+// generated admin credentials. When the API server cannot be reached from this machine,
+// kubectl is run over SSH on the first master node instead, and the reason is logged to out.
+func kubernetesClient(out io.Writer, plan *install.Plan, generatedAssetsDir string) (data.ClusterClient, error) {
+	apiClient, err := install.NewKubernetesAPIClient(plan, generatedAssetsDir)
+	if err == nil {
+		if err = apiClient.Healthy(); err == nil {
+			return apiClient, nil
+		}
+	}
+	util.PrettyPrintWarn(out, "Cannot reach the API server, using kubectl on node %q: %v", plan.Master.Nodes[0].Host, err)
+	client, sshErr := plan.GetSSHClient(plan.Master.Nodes[0].Host)
+	if sshErr != nil {
+		return nil, fmt.Errorf("error getting SSH client: %v", sshErr)
+	}
+	return data.RemoteKubectl{SSHClient: client}, nil
+}
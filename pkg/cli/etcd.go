@@ -0,0 +1,405 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type etcdOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdEtcd returns the command for managing the Kubernetes etcd cluster
+func NewCmdEtcd(in io.Reader, out io.Writer) *cobra.Command {
+	opts := etcdOpts{}
+	cmd := &cobra.Command{
+		Use:   "etcd",
+		Short: "Manage the etcd cluster of your Kubernetes cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	cmd.AddCommand(NewCmdEtcdStatus(out, &opts))
+	cmd.AddCommand(NewCmdEtcdBackup(out, &opts))
+	cmd.AddCommand(NewCmdEtcdRestore(in, out, &opts))
+	cmd.AddCommand(NewCmdEtcdDefrag(out, &opts))
+	cmd.AddCommand(NewCmdEtcdCompact(out, &opts))
+	return cmd
+}
+
+// adds the flags of the subcommands that run the executor
+func addEtcdExecutorFlags(cmd *cobra.Command, opts *etcdOpts) {
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+}
+
+type etcdStatusOpts struct {
+	outputFormat string
+}
+
+// NewCmdEtcdStatus returns the command for printing the status of the etcd members
+func NewCmdEtcdStatus(out io.Writer, opts *etcdOpts) *cobra.Command {
+	statusOpts := etcdStatusOpts{}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the health, leader, raft index, database size and version of every etcd member",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doEtcdStatus(out, *opts, statusOpts)
+		},
+	}
+	cmd.Flags().StringVarP(&statusOpts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	return cmd
+}
+
+func doEtcdStatus(out io.Writer, opts etcdOpts, statusOpts etcdStatusOpts) error {
+	if statusOpts.outputFormat != "simple" && statusOpts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", statusOpts.outputFormat)
+	}
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	statuses := install.EtcdClusterStatus(plan)
+
+	if statusOpts.outputFormat == "json" {
+		b, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling etcd status: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+	} else {
+		printEtcdStatuses(out, statuses)
+	}
+	for _, s := range statuses {
+		if !s.Healthy {
+			return errors.New("some of the etcd members are not healthy")
+		}
+	}
+	return nil
+}
+
+func printEtcdStatuses(out io.Writer, statuses []install.EtcdMemberStatus) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Host\tHealthy\tLeader\tMember ID\tRaft Term\tRaft Index\tDB Size\tVersion\n")
+	for _, s := range statuses {
+		if s.MemberID == "" {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t-\n", s.Host, yesNo(s.Healthy))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", s.Host, yesNo(s.Healthy), yesNo(s.Leader), s.MemberID, s.RaftTerm, s.RaftIndex, HumanFormat(float64(s.DBSize)), s.Version)
+	}
+	w.Flush()
+	for _, s := range statuses {
+		if s.Error != "" {
+			util.PrettyPrintErr(out, "%s: %s", s.Host, s.Error)
+		}
+	}
+}
+
+type etcdBackupOpts struct {
+	backupDir string
+	keep      int
+	maxAge    time.Duration
+	schedule  string
+}
+
+// NewCmdEtcdBackup returns the command for backing up the Kubernetes etcd cluster
+func NewCmdEtcdBackup(out io.Writer, opts *etcdOpts) *cobra.Command {
+	backupOpts := etcdBackupOpts{}
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Take a snapshot of the etcd cluster, and store it locally",
+		Long: `Take a snapshot of the etcd cluster, and store it locally.
+
+A v3 snapshot is taken from the first healthy etcd member, and copied to a new
+directory in the backup directory, along with its checksum and the versions of the
+cluster. The checksum is verified once the snapshot has been copied.
+
+Snapshots are also kept on the etcd nodes in /var/lib/etcd_k8s_snapshots. Old backups
+are removed from the etcd nodes and from the backup directory according to --keep
+and --max-age.
+
+Use --schedule to install a systemd timer on the etcd nodes instead, which saves a
+snapshot on the node according to a systemd calendar event (e.g. "daily" or
+"*-*-* 02:00:00"), and removes old snapshots from the node. Cron expressions are not
+supported. Scheduled snapshots are not copied locally. Use --schedule none to remove the timer.
+`,
+		Example: `  # Backup etcd, keeping the 5 most recent backups
+  kismatic etcd backup --keep 5
+
+  # Save a snapshot on the etcd nodes every day, keeping a week of snapshots
+  kismatic etcd backup --schedule daily --keep 0 --max-age 168h`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			if backupOpts.keep < 0 || backupOpts.maxAge < 0 {
+				return fmt.Errorf("--keep and --max-age cannot be negative")
+			}
+			if backupOpts.schedule != "" {
+				if err := install.ValidateEtcdBackupSchedule(backupOpts.schedule); err != nil {
+					return fmt.Errorf("invalid --schedule: %v", err)
+				}
+			}
+			return doEtcdBackup(out, *opts, backupOpts)
+		},
+	}
+	cmd.Flags().StringVar(&backupOpts.backupDir, "backup-dir", "", "directory where the backups are stored (default \"<generated-assets-dir>/etcd-backups\")")
+	cmd.Flags().IntVar(&backupOpts.keep, "keep", 10, "number of backups to keep, 0 to keep all of them")
+	cmd.Flags().DurationVar(&backupOpts.maxAge, "max-age", 0, "remove backups that are older than this duration (e.g. 720h), 0 to keep all of them")
+	cmd.Flags().StringVar(&backupOpts.schedule, "schedule", "", "install a systemd timer on the etcd nodes that saves a snapshot on the given systemd calendar event, or \"none\" to remove it")
+	addEtcdExecutorFlags(cmd, opts)
+	return cmd
+}
+
+func doEtcdBackup(out io.Writer, opts etcdOpts, backupOpts etcdBackupOpts) error {
+	plan, executor, err := newEtcdExecutor(out, opts)
+	if err != nil {
+		return err
+	}
+	retention := install.EtcdBackupRetention{Keep: backupOpts.keep, MaxAge: backupOpts.maxAge}
+	if backupOpts.schedule != "" {
+		if err = executor.ScheduleEtcdBackups(*plan, backupOpts.schedule, retention); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+		if backupOpts.schedule == install.EtcdBackupScheduleNone {
+			util.PrintColor(out, util.Green, "The etcd backup schedule was removed successfully!\n")
+		} else {
+			util.PrintColor(out, util.Green, "Etcd backups were scheduled successfully!\n")
+		}
+		fmt.Fprintln(out)
+		return nil
+	}
+
+	backupDir := backupOpts.backupDir
+	if backupDir == "" {
+		backupDir = install.EtcdBackupsDir(opts.generatedAssetsDir)
+	}
+	backup, err := install.NewEtcdBackup(*plan, backupDir)
+	if err != nil {
+		return err
+	}
+	if err = executor.BackupEtcd(*plan, *backup, retention); err != nil {
+		os.RemoveAll(backup.Directory)
+		return err
+	}
+	if err = install.FinalizeEtcdBackup(backup); err != nil {
+		os.RemoveAll(backup.Directory)
+		return err
+	}
+	util.PrettyPrintOk(out, "Stored the snapshot of member %q in %q", backup.Member, backup.Directory)
+	util.PrettyPrintOk(out, "SHA256: %s", backup.SHA256)
+
+	removed, err := install.PruneEtcdBackups(backupDir, retention, time.Now())
+	for _, b := range removed {
+		util.PrettyPrintOk(out, "Removed backup %q", b.Name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The etcd cluster was backed up successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+type etcdRestoreOpts struct {
+	backupDir string
+	force     bool
+}
+
+// NewCmdEtcdRestore returns the command for restoring the Kubernetes etcd cluster from a snapshot
+func NewCmdEtcdRestore(in io.Reader, out io.Writer, opts *etcdOpts) *cobra.Command {
+	restoreOpts := etcdRestoreOpts{}
+	cmd := &cobra.Command{
+		Use:   "restore SNAPSHOT",
+		Short: "Rebuild the etcd cluster from a snapshot",
+		Long: `Rebuild the etcd cluster from a snapshot, for example after it has lost quorum.
+
+SNAPSHOT is either the name of a backup taken with "kismatic etcd backup", or the
+path to an etcd v3 snapshot file. The checksum of the snapshot is verified when
+it is known.
+
+The API servers, schedulers and controller managers are stopped, and the snapshot
+is restored on every etcd node of the plan file, with new member IDs. The previous
+data of every member is kept on the node. Once all the members are healthy and the
+cluster has all the members of the plan file, the control plane is started again
+and validated.
+
+Any changes made to the cluster after the snapshot was taken will be lost.
+`,
+		Example: `  # Restore the most recent backup
+  kismatic etcd restore $(ls -t generated/etcd-backups | head -1)`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doEtcdRestore(in, out, *opts, restoreOpts, args[0])
+		},
+	}
+	cmd.Flags().StringVar(&restoreOpts.backupDir, "backup-dir", "", "directory where the backups are stored (default \"<generated-assets-dir>/etcd-backups\")")
+	cmd.Flags().BoolVar(&restoreOpts.force, "force", false, "do not prompt")
+	addEtcdExecutorFlags(cmd, opts)
+	return cmd
+}
+
+func doEtcdRestore(in io.Reader, out io.Writer, opts etcdOpts, restoreOpts etcdRestoreOpts, snapshot string) error {
+	backupDir := restoreOpts.backupDir
+	if backupDir == "" {
+		backupDir = install.EtcdBackupsDir(opts.generatedAssetsDir)
+	}
+	file, backup, err := install.EtcdRestoreSnapshot(backupDir, snapshot)
+	if err != nil {
+		return err
+	}
+	plan, executor, err := newEtcdExecutor(out, opts)
+	if err != nil {
+		return err
+	}
+
+	util.PrintHeader(out, "Restore Etcd", '=')
+	fmt.Fprintf(out, "Snapshot: %s\n", file)
+	if backup != nil {
+		fmt.Fprintf(out, "Taken on %s from member %q of cluster %q, running Kubernetes %s\n", backup.CreatedAt.Format("2006-01-02 15:04:05"), backup.Member, backup.ClusterName, backup.KubernetesVersion)
+		if backup.ClusterName != plan.Cluster.Name {
+			util.PrettyPrintWarn(out, "The snapshot was taken from cluster %q, but the plan file is for cluster %q", backup.ClusterName, plan.Cluster.Name)
+		}
+		if backup.KubernetesVersion != plan.Cluster.Version {
+			util.PrettyPrintWarn(out, "The snapshot was taken when the cluster was running Kubernetes %s, but the plan file is for %s", backup.KubernetesVersion, plan.Cluster.Version)
+		}
+	}
+	hosts := []string{}
+	for _, n := range plan.Etcd.Nodes {
+		hosts = append(hosts, n.Host)
+	}
+	fmt.Fprintf(out, "The etcd cluster will be rebuilt on %s. Changes made to the cluster after the snapshot was taken will be lost.\n", strings.Join(hosts, ", "))
+	if !restoreOpts.force {
+		ans, err := util.PromptForString(in, out, "Continue with the restore?", "N", []string{"N", "y"})
+		if err != nil {
+			return fmt.Errorf("error getting user response: %v", err)
+		}
+		if strings.ToLower(ans) != "y" {
+			return errors.New("Restore aborted")
+		}
+	}
+
+	if err = executor.RestoreEtcd(*plan, file); err != nil {
+		return err
+	}
+	util.PrintHeader(out, "Validate Control Plane", '=')
+	if err = executor.ValidateControlPlane(*plan); err != nil {
+		return fmt.Errorf("error validating the control plane: %v", err)
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The etcd cluster was restored successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+// NewCmdEtcdDefrag returns the command for defragmenting the etcd members
+func NewCmdEtcdDefrag(out io.Writer, opts *etcdOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "defrag",
+		Short: "Defragment the etcd members one at a time, to release unused disk space",
+		Long: `Defragment the etcd members one at a time, to release unused disk space.
+
+A member does not serve requests while it is defragmented. All the members must be
+healthy before and after a member is defragmented.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doEtcdDefrag(out, *opts, false)
+		},
+	}
+	addEtcdExecutorFlags(cmd, opts)
+	return cmd
+}
+
+// NewCmdEtcdCompact returns the command for compacting the etcd keyspace
+func NewCmdEtcdCompact(out io.Writer, opts *etcdOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "Compact the etcd keyspace, and defragment the etcd members one at a time",
+		Long: `Compact the etcd keyspace, and defragment the etcd members one at a time.
+
+The keyspace is compacted at its current revision, which discards the previous
+versions of the keys. The members are then defragmented one at a time, to release
+the disk space that was used by the previous versions. All the members must be
+healthy before and after a member is defragmented.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doEtcdDefrag(out, *opts, true)
+		},
+	}
+	addEtcdExecutorFlags(cmd, opts)
+	return cmd
+}
+
+func doEtcdDefrag(out io.Writer, opts etcdOpts, compact bool) error {
+	plan, executor, err := newEtcdExecutor(out, opts)
+	if err != nil {
+		return err
+	}
+	if err = executor.DefragEtcd(*plan, compact); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The etcd maintenance completed successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+func newEtcdExecutor(out io.Writer, opts etcdOpts) (*install.Plan, install.Executor, error) {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return nil, nil, planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return nil, nil, err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return nil, nil, err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, executor, nil
+}
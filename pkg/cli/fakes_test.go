@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"time"
+
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/tls"
 )
@@ -35,6 +37,14 @@ func (fe *fakeExecutor) AddNode(p *install.Plan, newNode install.Node, roles []s
 	return nil, nil
 }
 
+func (fe *fakeExecutor) AddNodes(p *install.Plan, nodes []install.NewNode, restartServices bool) (*install.Plan, []install.AddNodeResult, error) {
+	return nil, nil, nil
+}
+
+func (fe *fakeExecutor) RemoveNode(p *install.Plan, host string, unreachable bool) (*install.Plan, error) {
+	return nil, nil
+}
+
 func (fe *fakeExecutor) GenerateCertificates(*install.Plan, bool) error {
 	return nil
 }
@@ -56,11 +66,23 @@ func (fe *fakeExecutor) RunNewNodePreFlightCheck(install.Plan, install.Node) err
 	return nil
 }
 
+func (fe *fakeExecutor) RunNewNodesPreFlightCheck(install.Plan, []install.NewNode) ([]string, error) {
+	return nil, nil
+}
+
 func (fe *fakeExecutor) RunUpgradePreFlightCheck(*install.Plan, install.ListableNode) error {
 	return nil
 }
 
-func (fe *fakeExecutor) UpgradeNodes(install.Plan, []install.ListableNode, bool, int, bool) error {
+func (fe *fakeExecutor) UpgradeNodes(install.Plan, []install.UpgradeBatch, bool, bool) error {
+	return nil
+}
+
+func (fe *fakeExecutor) SnapshotNodes(install.Plan, install.UpgradeSnapshot) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RollbackNodes(install.Plan, install.UpgradeSnapshot, []install.NodeSnapshot, bool) error {
 	return nil
 }
 
@@ -76,6 +98,46 @@ func (fe *fakeExecutor) UpgradeClusterServices(install.Plan) error {
 	return nil
 }
 
+func (fe *fakeExecutor) ApplyAddOns(install.Plan, []string) error {
+	return nil
+}
+
+func (fe *fakeExecutor) ReconcileNodes(install.Plan, []install.NodeDrift) error {
+	return nil
+}
+
+func (fe *fakeExecutor) DrainNode(install.Plan, install.Node) error {
+	return nil
+}
+
+func (fe *fakeExecutor) UncordonNode(install.Plan, install.Node) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RebootNodes(install.Plan, []install.UpgradeBatch, time.Duration) error {
+	return nil
+}
+
+func (fe *fakeExecutor) UpdateOSPackages(install.Plan, []install.UpgradeBatch, time.Duration) ([]install.OSUpdateResult, error) {
+	return nil, nil
+}
+
+func (fe *fakeExecutor) BackupEtcd(install.Plan, install.EtcdBackup, install.EtcdBackupRetention) error {
+	return nil
+}
+
+func (fe *fakeExecutor) ScheduleEtcdBackups(install.Plan, string, install.EtcdBackupRetention) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RestoreEtcd(install.Plan, string) error {
+	return nil
+}
+
+func (fe *fakeExecutor) DefragEtcd(install.Plan, bool) error {
+	return nil
+}
+
 func (fe *fakeExecutor) RunSmokeTest(p *install.Plan) error {
 	return nil
 }
@@ -113,7 +175,29 @@ func (fp *fakePKI) GenerateClusterCerts(p *install.Plan, ca *tls.CA, users []str
 	return fp.err
 }
 
-func (fp *fakePKI) GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
+func (fp *fakePKI) GenerateCertificate(name string, certs install.CertsConfig, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
 	fp.called = true
 	return false, fp.err
 }
+
+func (fp *fakePKI) RotateCertificates(p *install.Plan, components []string, clusterCA *tls.CA, proxyClientCA *tls.CA) ([]string, error) {
+	fp.called = true
+	return nil, fp.err
+}
+
+func (fp *fakePKI) PrepareCARotationPhase(p *install.Plan, phase string) error {
+	fp.called = true
+	return fp.err
+}
+
+func (fe *fakeExecutor) RotateEncryptionKey(install.Plan) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RotateCertificates(install.Plan, []string) error {
+	return nil
+}
+
+func (fe *fakeExecutor) RotateClusterCA(install.Plan) error {
+	return nil
+}
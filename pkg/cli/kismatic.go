@@ -23,6 +23,7 @@ more documentation is available at https://github.com/apprenda/kismatic`,
 	cmd.AddCommand(NewCmdVersion(buildDate, out))
 	cmd.AddCommand(NewCmdInstall(in, out))
 	cmd.AddCommand(NewCmdReset(in, out))
+	cmd.AddCommand(NewCmdRemoveNode(in, out))
 	cmd.AddCommand(NewCmdVolume(in, out))
 	cmd.AddCommand(NewCmdIP(out))
 	cmd.AddCommand(NewCmdDashboard(in, out))
@@ -32,6 +33,13 @@ more documentation is available at https://github.com/apprenda/kismatic`,
 	cmd.AddCommand(NewCmdDiagnostic(out))
 	cmd.AddCommand(NewCmdCertificates(out))
 	cmd.AddCommand(NewCmdSeedRegistry(out, stderr))
+	cmd.AddCommand(NewCmdAddOns(out))
+	cmd.AddCommand(NewCmdNodes(out))
+	cmd.AddCommand(NewCmdNode(out))
+	cmd.AddCommand(NewCmdReboot(out))
+	cmd.AddCommand(NewCmdOSUpdate(out))
+	cmd.AddCommand(NewCmdEtcd(in, out))
+	cmd.AddCommand(NewCmdSecrets(out))
 
 	return cmd, nil
 }
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type nodeOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdNode returns the command for performing maintenance on a node of the cluster
+func NewCmdNode(out io.Writer) *cobra.Command {
+	opts := nodeOpts{}
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Perform maintenance on a node of your Kubernetes cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.PersistentFlags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.PersistentFlags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	cmd.AddCommand(NewCmdNodeDrain(out, &opts))
+	cmd.AddCommand(NewCmdNodeUncordon(out, &opts))
+	cmd.AddCommand(NewCmdNodeMaintenance(out, &opts))
+	return cmd
+}
+
+// NewCmdNodeDrain returns the command for draining a node
+func NewCmdNodeDrain(out io.Writer, opts *nodeOpts) *cobra.Command {
+	var ignoreSafetyChecks bool
+	cmd := &cobra.Command{
+		Use:   "drain HOST",
+		Short: "Cordon the node and evict its pods",
+		Long: `Cordon the node and evict its pods.
+
+Before draining the node, the same safety checks as an online upgrade are run to verify
+that draining the node will not result in data or availability loss.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			m, err := newNodeMaintenance(out, *opts, args[0])
+			if err != nil {
+				return err
+			}
+			if err = m.drain(ignoreSafetyChecks); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+			util.PrintColor(out, util.Green, "The node %q was drained successfully!\n", m.node.Host)
+			fmt.Fprintln(out)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&ignoreSafetyChecks, "ignore-safety-checks", false, "drain the node even if the safety checks fail")
+	return cmd
+}
+
+// NewCmdNodeUncordon returns the command for uncordoning a node
+func NewCmdNodeUncordon(out io.Writer, opts *nodeOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uncordon HOST",
+		Short: "Wait for the node to be ready, and mark it as schedulable",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			m, err := newNodeMaintenance(out, *opts, args[0])
+			if err != nil {
+				return err
+			}
+			if err = m.executor.UncordonNode(*m.plan, m.node); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+			util.PrintColor(out, util.Green, "The node %q was uncordoned successfully!\n", m.node.Host)
+			fmt.Fprintln(out)
+			return nil
+		},
+	}
+	return cmd
+}
+
+type nodeMaintenanceOpts struct {
+	ignoreSafetyChecks bool
+	reboot             bool
+	rebootTimeout      time.Duration
+}
+
+// NewCmdNodeMaintenance returns the command for running a command on a drained node
+func NewCmdNodeMaintenance(out io.Writer, opts *nodeOpts) *cobra.Command {
+	maintenanceOpts := nodeMaintenanceOpts{}
+	cmd := &cobra.Command{
+		Use:   "maintenance HOST -- CMD [ARGS...]",
+		Short: "Drain the node, run a command on it over SSH, and uncordon it once it is ready",
+		Long: `Drain the node, run a command on it over SSH, and uncordon it once it is ready.
+
+The node is drained after running the same safety checks as an online upgrade. The
+command is then run on the node as the SSH user of the plan file. Use --reboot to
+reboot the node after the command, and wait for it to come back. Once the node is
+Ready, it is uncordoned.
+
+If the command fails, the node is left cordoned. Use "kismatic node uncordon" once
+the node has been fixed.
+`,
+		Example: `  # Update the firmware of a node
+  kismatic node maintenance worker01 --reboot -- sudo /opt/firmware/update.sh`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+				return cmd.Usage()
+			}
+			m, err := newNodeMaintenance(out, *opts, args[0])
+			if err != nil {
+				return err
+			}
+			return m.run(args[1:], maintenanceOpts)
+		},
+	}
+	cmd.Flags().BoolVar(&maintenanceOpts.ignoreSafetyChecks, "ignore-safety-checks", false, "drain the node even if the safety checks fail")
+	cmd.Flags().BoolVar(&maintenanceOpts.reboot, "reboot", false, "reboot the node after running the command")
+	cmd.Flags().DurationVar(&maintenanceOpts.rebootTimeout, "reboot-timeout", 15*time.Minute, "how long to wait for the node to come back after rebooting it")
+	return cmd
+}
+
+type nodeMaintenance struct {
+	out      io.Writer
+	opts     nodeOpts
+	plan     *install.Plan
+	node     install.Node
+	executor install.Executor
+}
+
+func newNodeMaintenance(out io.Writer, opts nodeOpts, host string) (*nodeMaintenance, error) {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return nil, planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	node, err := install.MaintenanceNode(*plan, host)
+	if err != nil {
+		return nil, err
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return nil, err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return nil, err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &nodeMaintenance{
+		out:      out,
+		opts:     opts,
+		plan:     plan,
+		node:     node,
+		executor: executor,
+	}, nil
+}
+
+func (m *nodeMaintenance) drain(ignoreSafetyChecks bool) error {
+	util.PrintHeader(m.out, "Validate Node Drain", '=')
+	kubeClient, err := kubernetesClient(m.out, m.plan, m.opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	glusterClient, err := storageGlusterClient(*m.plan)
+	if err != nil {
+		return err
+	}
+	util.PrettyPrint(m.out, "%s %v", m.node.Host, m.plan.GetRolesForIP(m.node.IP))
+	errs := install.DetectNodeUpgradeSafety(*m.plan, m.node, kubeClient, glusterClient)
+	if len(errs) != 0 {
+		if ignoreSafetyChecks {
+			util.PrintWarn(m.out)
+		} else {
+			util.PrintError(m.out)
+		}
+		fmt.Fprintln(m.out)
+		for _, err := range errs {
+			fmt.Fprintln(m.out, "-", err.Error())
+		}
+		if !ignoreSafetyChecks {
+			fmt.Fprintln(m.out)
+			return errors.New("Unable to safely drain the node. Use --ignore-safety-checks to drain the node anyway.")
+		}
+		util.PrettyPrintWarn(m.out, "\nIgnoring safety checks and continuing with the drain")
+	} else {
+		util.PrintOkln(m.out)
+	}
+	return m.executor.DrainNode(*m.plan, m.node)
+}
+
+func (m *nodeMaintenance) run(command []string, opts nodeMaintenanceOpts) error {
+	if err := m.drain(opts.ignoreSafetyChecks); err != nil {
+		return err
+	}
+	con, err := m.plan.GetSSHConnection(m.node.Host)
+	if err != nil {
+		return err
+	}
+	client, err := ssh.NewClient(con.Node.IP, con.SSHConfig.Port, con.SSHConfig.User, con.SSHConfig.Key)
+	if err != nil {
+		return fmt.Errorf("error creating SSH client: %v", err)
+	}
+	util.PrintHeader(m.out, fmt.Sprintf("Running %q", strings.Join(command, " ")), '=')
+	if err = client.Shell(false, command...); err != nil {
+		return fmt.Errorf("error running command on node %q, the node was left cordoned: %v", m.node.Host, err)
+	}
+	if opts.reboot {
+		util.PrintHeader(m.out, "Rebooting Node", '=')
+		bootID, err := client.Output(false, "cat", bootIDFile)
+		if err != nil {
+			return fmt.Errorf("error getting the boot ID of node %q, the node was left cordoned: %v", m.node.Host, err)
+		}
+		// the connection may be closed by the node before the command returns
+		if out, err := client.Output(false, "sudo", "systemctl", "reboot"); err != nil && !ssh.ConnectionError(err) {
+			return fmt.Errorf("error rebooting node %q, the node was left cordoned: %v: %s", m.node.Host, err, out)
+		}
+		if err = waitForReboot(client, strings.TrimSpace(bootID), opts.rebootTimeout, 10*time.Second); err != nil {
+			return fmt.Errorf("error waiting for node %q to reboot, the node was left cordoned: %v", m.node.Host, err)
+		}
+		util.PrettyPrintOk(m.out, "Node %q is back up", m.node.Host)
+	}
+	if err = m.executor.UncordonNode(*m.plan, m.node); err != nil {
+		return err
+	}
+	fmt.Fprintln(m.out)
+	util.PrintColor(m.out, util.Green, "The maintenance of node %q was completed successfully!\n", m.node.Host)
+	fmt.Fprintln(m.out)
+	return nil
+}
+
+// the boot ID changes every time the node boots
+const bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// waits for the node to come back with a boot ID other than the one it had before
+// rebooting, so that a node that did not go down yet is not mistaken for a rebooted one
+func waitForReboot(client ssh.Client, bootID string, timeout time.Duration, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(interval)
+		current, err := client.Output(false, "cat", bootIDFile)
+		if err == nil && strings.TrimSpace(current) != bootID {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("timed out after %v: %v", timeout, err)
+			}
+			return fmt.Errorf("timed out after %v: the node did not reboot", timeout)
+		}
+	}
+}
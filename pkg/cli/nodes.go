@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type nodesOpts struct {
+	generatedAssetsDir string
+	planFile           string
+}
+
+// NewCmdNodes returns the command for managing the nodes of the cluster
+func NewCmdNodes(out io.Writer) *cobra.Command {
+	opts := nodesOpts{}
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Manage the nodes of your Kubernetes cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	cmd.AddCommand(NewCmdNodesReconcile(out, &opts))
+	return cmd
+}
+
+type nodesReconcileOpts struct {
+	apply        bool
+	verbose      bool
+	outputFormat string
+}
+
+// NewCmdNodesReconcile returns the command for reconciling the labels and taints of the nodes
+func NewCmdNodesReconcile(out io.Writer, opts *nodesOpts) *cobra.Command {
+	reconcileOpts := nodesReconcileOpts{}
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare the labels and taints of the nodes with the plan file, and optionally apply them",
+		Long: `Compare the labels and taints of the nodes with the plan file, and optionally apply them.
+
+A node has drifted when a label or taint of the plan file is missing from the node
+or has a different value, or when a label or taint that was applied from the plan file
+has since been removed from the plan file. Labels and taints that were not applied
+from the plan file, such as the ones set by Kubernetes, are left untouched.
+
+Use --apply to add, update and remove the labels and taints of the nodes that have
+drifted, without running a full installation.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doNodesReconcile(out, *opts, reconcileOpts)
+		},
+	}
+	cmd.Flags().BoolVar(&reconcileOpts.apply, "apply", false, "apply the labels and taints of the plan file to the nodes that have drifted")
+	cmd.Flags().BoolVar(&reconcileOpts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&reconcileOpts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	return cmd
+}
+
+func doNodesReconcile(out io.Writer, opts nodesOpts, reconcileOpts nodesReconcileOpts) error {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	kubeClient, err := kubernetesClient(out, plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	drift, err := install.DetectNodeDrift(*plan, kubeClient)
+	if err != nil {
+		return err
+	}
+	if err = printNodeDrift(out, drift); err != nil {
+		return err
+	}
+	var needsReconcile bool
+	for _, d := range drift {
+		needsReconcile = needsReconcile || d.NeedsReconcile()
+	}
+	if !reconcileOpts.apply {
+		return nil
+	}
+	if !needsReconcile {
+		fmt.Fprintln(out)
+		util.PrettyPrintOk(out, "The labels and taints of the nodes match the plan file")
+		return nil
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             reconcileOpts.outputFormat,
+		Verbose:                  reconcileOpts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	util.PrintHeader(out, "Reconcile Node Labels and Taints", '=')
+	if err = executor.ReconcileNodes(*plan, drift); err != nil {
+		return fmt.Errorf("error reconciling nodes: %v", err)
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The labels and taints of the nodes were reconciled successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+func printNodeDrift(out io.Writer, drift []install.NodeDrift) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Node\tDrift\n")
+	for _, d := range drift {
+		fmt.Fprintf(w, "%s\t%s\n", d.Host, describeNodeDrift(d))
+	}
+	return w.Flush()
+}
+
+func describeNodeDrift(d install.NodeDrift) string {
+	if d.NotFound {
+		return "node is not registered with the cluster"
+	}
+	if !d.HasDrift() {
+		return "none"
+	}
+	changes := []string{}
+	keys := []string{}
+	for k := range d.AddLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		changes = append(changes, fmt.Sprintf("+label %s=%s", k, d.AddLabels[k]))
+	}
+	for _, k := range d.RemoveLabels {
+		changes = append(changes, fmt.Sprintf("-label %s", k))
+	}
+	for _, t := range d.AddTaints {
+		changes = append(changes, fmt.Sprintf("+taint %s=%s:%s", t.Key, t.Value, t.Effect))
+	}
+	for _, t := range d.RemoveTaints {
+		changes = append(changes, fmt.Sprintf("-taint %s=%s:%s", t.Key, t.Value, t.Effect))
+	}
+	return strings.Join(changes, "; ")
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type osUpdateOpts struct {
+	planFile           string
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	role               string
+	maxUnavailable     string
+	ignoreSafetyChecks bool
+	rebootTimeout      time.Duration
+}
+
+// NewCmdOSUpdate returns the command for updating the OS packages of the nodes
+func NewCmdOSUpdate(out io.Writer) *cobra.Command {
+	opts := osUpdateOpts{}
+	cmd := &cobra.Command{
+		Use:   "os-update",
+		Short: "Update the OS packages of the nodes, in batches that keep the cluster available",
+		Long: `Update the OS packages of the nodes, in batches that keep the cluster available.
+
+The packages are updated with yum or apt, except for the Kubernetes, docker and
+GlusterFS packages, which are held at the versions installed by Kismatic.
+
+The nodes are updated in the same batches as an online upgrade, and drained before
+being updated. A node is rebooted when a newer kernel was installed. After each batch,
+Kismatic waits for the etcd clusters, the control plane and the storage volumes to be
+healthy, and uncordons the nodes once they are Ready. The rollout stops at the first
+batch that fails.
+
+Once done, the packages that were changed on every node are listed. The packages installed
+before and after the update are recorded in the generated assets directory.
+`,
+		Example: `  # Update the worker nodes two at a time
+  kismatic os-update --role worker --max-unavailable 2`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doOSUpdate(out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.role, "role", "", "only update the nodes with this role (options \"etcd\"|\"master\"|\"worker\"|\"ingress\"|\"storage\")")
+	cmd.Flags().StringVar(&opts.maxUnavailable, "max-unavailable", "1", "maximum number of worker nodes that are updated at the same time, as a count (e.g. 2) or a percentage of the nodes (e.g. 25%)")
+	cmd.Flags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "update the nodes even if the safety checks fail")
+	cmd.Flags().DurationVar(&opts.rebootTimeout, "reboot-timeout", 15*time.Minute, "how long to wait for a node to come back after rebooting it")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	return cmd
+}
+
+func doOSUpdate(out io.Writer, opts osUpdateOpts) error {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	nodes, err := install.OSUpdateNodes(*plan, opts.role)
+	if err != nil {
+		return err
+	}
+	maxUnavailable, err := install.ParseMaxUnavailable(opts.maxUnavailable, len(nodes))
+	if err != nil {
+		return fmt.Errorf("invalid max-unavailable: %v", err)
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+
+	batches, err := rollingBatches(out, plan, nodes, maxUnavailable, opts.generatedAssetsDir, opts.ignoreSafetyChecks, "update")
+	if err != nil {
+		return err
+	}
+
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	results, err := executor.UpdateOSPackages(*plan, batches, opts.rebootTimeout)
+	printOSUpdateResults(out, results)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return errors.New("The packages pinned by Kismatic were changed on some nodes")
+		}
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The OS packages of the nodes were updated successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+func printOSUpdateResults(out io.Writer, results []install.OSUpdateResult) {
+	if len(results) == 0 {
+		return
+	}
+	util.PrintHeader(out, "Package Changes", '=')
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "HOST\tPACKAGE\tBEFORE\tAFTER")
+	for _, r := range results {
+		if len(r.Changes) == 0 {
+			fmt.Fprintf(w, "%s\t(none)\t\t\n", r.Host)
+		}
+		for _, c := range r.Changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Host, c.Name, orDash(c.Before), orDash(c.After))
+		}
+	}
+	w.Flush()
+	for _, r := range results {
+		if r.Err != nil {
+			util.PrettyPrintErr(out, "%s: %v", r.Host, r.Err)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type rebootOpts struct {
+	planFile           string
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	role               string
+	maxUnavailable     string
+	ignoreSafetyChecks bool
+	timeout            time.Duration
+}
+
+// NewCmdReboot returns the command for rebooting the nodes of the cluster
+func NewCmdReboot(out io.Writer) *cobra.Command {
+	opts := rebootOpts{}
+	cmd := &cobra.Command{
+		Use:   "reboot",
+		Short: "Reboot the nodes of a role, in batches that keep the cluster available",
+		Long: `Reboot the nodes of a role, in batches that keep the cluster available.
+
+The nodes are rebooted in the same batches as an online upgrade:
+  - etcd nodes one at a time, only if all the etcd members are healthy
+  - master nodes one at a time
+  - storage nodes one at a time, once the storage volumes have healed
+  - worker and ingress nodes in batches of up to --max-unavailable nodes that
+    don't host all the replicas of a workload
+
+Kubernetes nodes are drained before being rebooted. After each batch, Kismatic waits
+for SSH, the kubelet, the etcd clusters, the control plane and the storage volumes
+to be healthy, and uncordons the nodes once they are Ready. The rollout stops at the
+first batch that fails.
+
+Before rebooting, the same safety checks as an online upgrade are run.
+`,
+		Example: `  # Reboot the worker nodes two at a time after patching the kernel
+  kismatic reboot --role worker --max-unavailable 2`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			if opts.role == "" {
+				return cmd.Usage()
+			}
+			return doReboot(out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.role, "role", "", "reboot the nodes with this role (options \"etcd\"|\"master\"|\"worker\"|\"ingress\"|\"storage\")")
+	cmd.Flags().StringVar(&opts.maxUnavailable, "max-unavailable", "1", "maximum number of worker nodes that are rebooted at the same time, as a count (e.g. 2) or a percentage of the nodes (e.g. 25%)")
+	cmd.Flags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "reboot the nodes even if the safety checks fail")
+	cmd.Flags().DurationVar(&opts.timeout, "reboot-timeout", 15*time.Minute, "how long to wait for a node to come back after rebooting it")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	return cmd
+}
+
+func doReboot(out io.Writer, opts rebootOpts) error {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	nodes, err := install.NodesWithRole(*plan, opts.role)
+	if err != nil {
+		return err
+	}
+	maxUnavailable, err := install.ParseMaxUnavailable(opts.maxUnavailable, len(nodes))
+	if err != nil {
+		return fmt.Errorf("invalid max-unavailable: %v", err)
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+
+	batches, err := rollingBatches(out, plan, nodes, maxUnavailable, opts.generatedAssetsDir, opts.ignoreSafetyChecks, "reboot")
+	if err != nil {
+		return err
+	}
+
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = executor.RebootNodes(*plan, batches, opts.timeout); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The %s nodes were rebooted successfully!\n", opts.role)
+	fmt.Fprintln(out)
+	return nil
+}
+
+// rollingBatches runs the online upgrade safety checks against the nodes, and
+// returns the batches in which the nodes can be taken out of service
+func rollingBatches(out io.Writer, plan *install.Plan, nodes []install.ListableNode, maxUnavailable int, generatedAssetsDir string, ignoreSafetyChecks bool, action string) ([]install.UpgradeBatch, error) {
+	util.PrintHeader(out, fmt.Sprintf("Validate %s%s", strings.ToUpper(action[:1]), action[1:]), '=')
+	kubeClient, err := kubernetesClient(out, plan, generatedAssetsDir)
+	if err != nil {
+		return nil, err
+	}
+	glusterClient, err := storageGlusterClient(*plan)
+	if err != nil {
+		return nil, err
+	}
+	var unsafe bool
+	for _, n := range nodes {
+		util.PrettyPrint(out, "%s %v", n.Node.Host, n.Roles)
+		errs := install.DetectNodeUpgradeSafety(*plan, n.Node, kubeClient, glusterClient)
+		if len(errs) == 0 {
+			util.PrintOkln(out)
+			continue
+		}
+		unsafe = true
+		if ignoreSafetyChecks {
+			util.PrintWarn(out)
+		} else {
+			util.PrintError(out)
+		}
+		fmt.Fprintln(out)
+		for _, err := range errs {
+			fmt.Fprintln(out, "-", err.Error())
+		}
+	}
+	if unsafe {
+		if !ignoreSafetyChecks {
+			fmt.Fprintln(out)
+			return nil, fmt.Errorf("Unable to safely %s the nodes. Use --ignore-safety-checks to %s the nodes anyway.", action, action)
+		}
+		util.PrettyPrintWarn(out, "\nIgnoring safety checks and continuing with the %s", action)
+	}
+
+	batches, err := install.OnlineUpgradeBatches(nodes, maxUnavailable, kubeClient)
+	if err != nil {
+		return nil, fmt.Errorf("error computing batches: %v", err)
+	}
+	util.PrintHeader(out, "Batches", '=')
+	for i, b := range batches {
+		hosts := []string{}
+		for _, n := range b.Nodes {
+			hosts = append(hosts, n.Node.Host)
+		}
+		fmt.Fprintf(out, "%d. %s\n", i+1, strings.Join(hosts, ", "))
+	}
+	return batches, nil
+}
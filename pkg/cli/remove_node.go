@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type removeNodeOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	force              bool
+	ignoreSafetyChecks bool
+	unreachable        bool
+	dryRun             bool
+}
+
+// NewCmdRemoveNode returns the command for removing a node from the cluster
+func NewCmdRemoveNode(in io.Reader, out io.Writer) *cobra.Command {
+	opts := &removeNodeOpts{}
+	cmd := &cobra.Command{
+		Use:   "remove-node NODE_NAME",
+		Short: "remove a node from an existing Kubernetes cluster",
+		Long: `Remove a node from an existing Kubernetes cluster.
+
+Before removing the node, safety checks are run to verify that the removal will not
+result in data or availability loss. The node is then drained, deleted from Kubernetes
+and reset, its certificates are removed from the generated assets directory, and it is
+removed from the plan file.
+
+If the node is an etcd node, it is removed from the etcd cluster, but only if all the
+remaining members are healthy. After removing an etcd or master node, the API servers
+are reconfigured one at a time.
+
+If the node is a storage node, it is detached from the storage cluster. Volumes that
+have bricks on the node must be moved or deleted before removing the node.
+
+Use --unreachable to remove a node that has failed. The node is neither drained nor reset.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			if !opts.force && !opts.dryRun {
+				ans, err := util.PromptForString(in, out, fmt.Sprintf("Are you sure you want to remove node %q? All data on the node will be lost", args[0]), "N", []string{"N", "y"})
+				if err != nil {
+					return fmt.Errorf("error getting user response: %v", err)
+				}
+				if strings.ToLower(ans) != "y" {
+					os.Exit(0)
+				}
+			}
+			return doRemoveNode(out, args[0], opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "do not prompt")
+	cmd.Flags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "remove the node even if the safety checks fail")
+	cmd.Flags().BoolVar(&opts.unreachable, "unreachable", false, "the node is not reachable, e.g. because it has failed. The node is not drained nor reset")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "simulate the removal, but don't make any changes to the cluster")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFilename)
+	return cmd
+}
+
+func doRemoveNode(out io.Writer, host string, opts *removeNodeOpts) error {
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read plan file: %v", err)
+	}
+	// validate the node before connecting to the cluster
+	remainingPlan, node, roles, err := install.RemoveNodeFromPlan(*plan, host)
+	if err != nil {
+		return err
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	// an unreachable node is not contacted, and is not used to reach the cluster
+	sshPlan := plan
+	if opts.unreachable {
+		sshPlan = &remainingPlan
+	}
+	if err = validateSSHConnectivity(out, sshPlan); err != nil {
+		return err
+	}
+
+	util.PrintHeader(out, "Validate Node Removal", '=')
+	kubeClient, err := kubernetesClient(out, sshPlan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	glusterClient, err := storageGlusterClient(*sshPlan)
+	if err != nil {
+		return err
+	}
+	util.PrettyPrint(out, "%s %v", node.Host, roles)
+	errs := install.DetectNodeRemovalSafety(*plan, node, kubeClient, glusterClient)
+	if len(errs) != 0 {
+		if opts.ignoreSafetyChecks {
+			util.PrintWarn(out)
+		} else {
+			util.PrintError(out)
+		}
+		fmt.Fprintln(out)
+		for _, err := range errs {
+			fmt.Fprintln(out, "-", err.Error())
+		}
+		if !opts.ignoreSafetyChecks {
+			fmt.Fprintln(out)
+			return errors.New("Unable to perform a safe node removal. Use --ignore-safety-checks to remove the node anyway.")
+		}
+		util.PrettyPrintWarn(out, "\nIgnoring safety checks and continuing with the node removal")
+	} else {
+		util.PrintOkln(out)
+	}
+
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+		DryRun:                   opts.dryRun,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	updatedPlan, err := executor.RemoveNode(plan, host, opts.unreachable)
+	if err != nil {
+		return err
+	}
+	if opts.dryRun {
+		return nil
+	}
+	if err := planner.Write(updatedPlan); err != nil {
+		return fmt.Errorf("error updating plan file to remove the node: %v", err)
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The node %q was removed from the cluster successfully!\n", host)
+	fmt.Fprintln(out)
+	return nil
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type secretsOpts struct {
+	generatedAssetsDir string
+	planFile           string
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdSecrets returns the command for managing the encryption of the Kubernetes secrets
+func NewCmdSecrets(out io.Writer) *cobra.Command {
+	opts := secretsOpts{}
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage the encryption of the Kubernetes secrets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.PersistentFlags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
+	cmd.PersistentFlags().StringVarP(&opts.outputFormat, "output", "o", "simple", "output format (options \"simple\"|\"raw\")")
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	cmd.AddCommand(NewCmdSecretsRotateKey(out, &opts))
+	return cmd
+}
+
+// NewCmdSecretsRotateKey returns the command for rotating the key that encrypts the secrets
+func NewCmdSecretsRotateKey(out io.Writer, opts *secretsOpts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Replace the key that encrypts the Kubernetes secrets, and re-encrypt all the secrets",
+		Long: `Replace the key that encrypts the Kubernetes secrets, and re-encrypt all the secrets.
+
+The new key is rolled out to the API servers in three stages, restarting the API
+servers one at a time at every stage:
+  - the new key is added to the encryption configuration
+  - the new key is used to encrypt the secrets, and all the secrets are rewritten
+  - the previous keys are removed from the encryption configuration
+
+The new key uses the encryption provider of the plan file. If the rotation fails,
+it can safely be run again.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doSecretsRotateKey(out, *opts)
+		},
+	}
+	return cmd
+}
+
+func doSecretsRotateKey(out io.Writer, opts secretsOpts) error {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	if !plan.Cluster.EncryptionAtRest.Enabled {
+		return fmt.Errorf("Encryption at rest is not enabled in the plan file %q", opts.planFile)
+	}
+	if err = validatePlan(out, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err = executor.RotateEncryptionKey(*plan); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The encryption key was rotated successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
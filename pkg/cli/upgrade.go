@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/apprenda/kismatic/pkg/data"
 	"github.com/apprenda/kismatic/pkg/install"
@@ -24,7 +27,13 @@ type upgradeOpts struct {
 	restartServices    bool
 	partialAllowed     bool
 	maxParallelWorkers int
+	maxUnavailable     string
 	dryRun             bool
+	canary             int
+	canaryChecks       []string
+	// set when continuing a paused canary upgrade
+	resuming     bool
+	snapshotName string
 }
 
 // NewCmdUpgrade returns the upgrade command
@@ -62,6 +71,10 @@ Nodes in the cluster are upgraded in the following order:
 	// Subcommands
 	cmd.AddCommand(NewCmdUpgradeOffline(in, out, &opts))
 	cmd.AddCommand(NewCmdUpgradeOnline(in, out, &opts))
+	cmd.AddCommand(NewCmdUpgradePlan(out, &opts))
+	cmd.AddCommand(NewCmdUpgradeRollback(in, out, &opts))
+	cmd.AddCommand(NewCmdUpgradeContinue(in, out, &opts))
+	cmd.AddCommand(NewCmdUpgradeAbort(in, out, &opts))
 	return cmd
 }
 
@@ -101,6 +114,18 @@ be printed, and the upgrade will not proceed.
 
 If the node under upgrade is a Kubernetes node, it is cordoned and drained of workloads
 before any changes are applied.
+
+Worker nodes are upgraded in batches of up to --max-unavailable nodes, given as a count
+or as a percentage of the worker nodes in the plan. Nodes are only upgraded together if,
+between them, they don't host all the replicas of a workload, and draining them does not
+evict more pods than allowed by a PodDisruptionBudget. Storage nodes are always upgraded
+one at a time.
+
+When --canary is set, only the etcd nodes, the master nodes and the given number of
+worker nodes are upgraded. The smoke test and the commands given with --canary-check
+are then run to verify the canary, and the upgrade is paused until an operator runs
+"kismatic upgrade continue" or "kismatic upgrade abort". The canary must contain less
+worker nodes than the worker nodes to upgrade.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.online = true
@@ -108,6 +133,9 @@ before any changes are applied.
 		},
 	}
 	cmd.PersistentFlags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "ignore upgrade safety checks and continue with the upgrade")
+	cmd.Flags().StringVar(&opts.maxUnavailable, "max-unavailable", "1", "the maximum number of worker nodes to be upgraded in parallel, as a count or as a percentage of the worker nodes (e.g. \"10%\")")
+	cmd.Flags().IntVar(&opts.canary, "canary", 0, "upgrade the control plane and the given number of worker nodes, verify them, and pause the upgrade")
+	cmd.Flags().StringArrayVar(&opts.canaryChecks, "canary-check", []string{}, "command to run after the smoke test to verify the canary. The KUBECONFIG environment variable points to the generated kubeconfig file. May be repeated")
 	return &cmd
 }
 
@@ -115,6 +143,21 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 	if opts.maxParallelWorkers < 1 {
 		return fmt.Errorf("max-parallel-workers must be greater or equal to 1, got: %d", opts.maxParallelWorkers)
 	}
+	if opts.online {
+		if _, err := install.ParseMaxUnavailable(opts.maxUnavailable, 1); err != nil {
+			return fmt.Errorf("invalid max-unavailable: %v", err)
+		}
+	}
+	if opts.canary < 0 {
+		return fmt.Errorf("canary must be greater or equal to 0, got: %d", opts.canary)
+	}
+	paused, err := install.ReadPausedUpgrade(install.DefaultRunsDirectory)
+	if err != nil {
+		return err
+	}
+	if paused != nil && !opts.resuming {
+		return errors.New(`A canary upgrade is paused. Use "kismatic upgrade continue" or "kismatic upgrade abort" before starting a new upgrade.`)
+	}
 
 	planFile := opts.planFile
 	planner := install.FilePlanner{File: planFile}
@@ -157,23 +200,6 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 		return err
 	}
 
-	// Generate new certs, or use existing ones. Always ensure that the CA exists.
-	if err = executor.GenerateCertificates(plan, true); err != nil {
-		return err
-	}
-
-	util.PrintHeader(out, "Generating Kubeconfig File", '=')
-	isDiff, err := install.RegenerateKubeconfig(plan, opts.generatedAssetsDir)
-	if err != nil {
-		return fmt.Errorf("error generating kubeconfig file: %v", err)
-	}
-
-	if isDiff {
-		util.PrettyPrintWarn(out, "An updated kubeconfig file has been generated in %q", opts.generatedAssetsDir)
-	} else {
-		util.PrettyPrintOk(out, "Found existing kubeconfig file in %q", opts.generatedAssetsDir)
-	}
-
 	// Get the cluster and node versions
 	cv, err := install.ListVersions(plan)
 	if err != nil {
@@ -184,9 +210,7 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 	var toUpgrade []install.ListableNode
 	var toSkip []install.ListableNode
 	for _, n := range cv.Nodes {
-		// run if KET version or component versions are different
-		// don't check component versions if the node has only "etcd" role
-		if install.IsOlderVersion(n.Version) || (!(len(n.Roles) == 1 && n.Roles[0] == "etcd") && plan.Cluster.Version != n.ComponentVersions.Kubernetes) {
+		if install.NodeNeedsUpgrade(*plan, n) {
 			toUpgrade = append(toUpgrade, n)
 		} else {
 			toSkip = append(toSkip, n)
@@ -202,11 +226,73 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 		fmt.Fprintln(out)
 	}
 
+	// Run the safety and preflight checks before touching anything
+	var batches, remaining []install.UpgradeBatch
+	if len(toUpgrade) > 0 {
+		var kubeClient data.ClusterClient
+		toUpgrade, kubeClient, err = validateUpgradeNodes(in, out, *plan, opts, toUpgrade, preflightExec)
+		if err != nil {
+			return err
+		}
+		batches, remaining, err = upgradeBatches(*plan, *opts, toUpgrade, kubeClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Record the state of the cluster once the checks have passed, so that the upgrade can be rolled back.
+	// When continuing a paused upgrade, the snapshot taken before the canary is kept.
+	if !opts.dryRun && !opts.resuming {
+		util.PrintHeader(out, "Recording Pre-Upgrade Versions", '=')
+		snapshot, err := install.CreateUpgradeSnapshot(plan, planFile, opts.generatedAssetsDir, cv)
+		if err != nil {
+			util.PrettyPrintErr(out, "Recording installed versions and archiving generated assets")
+			return fmt.Errorf("error creating upgrade snapshot: %v", err)
+		}
+		util.PrettyPrintOk(out, "Recorded installed versions and archived generated assets in %q", snapshot.Directory)
+		if !snapshot.PreUpgrade {
+			util.PrettyPrintWarn(out, "Some nodes are already at the target version, the snapshot %q is not a pre-upgrade snapshot", snapshot.Name)
+		}
+		opts.snapshotName = snapshot.Name
+		if err = executor.SnapshotNodes(*plan, *snapshot); err != nil {
+			return fmt.Errorf("error creating upgrade snapshot: %v", err)
+		}
+	}
+
+	// Generate new certs, or use existing ones. Always ensure that the CA exists.
+	if err = executor.GenerateCertificates(plan, true); err != nil {
+		return err
+	}
+	if err = install.GenerateEncryptionConfig(plan, opts.generatedAssetsDir); err != nil {
+		return fmt.Errorf("error generating encryption configuration: %v", err)
+	}
+
+	util.PrintHeader(out, "Generating Kubeconfig File", '=')
+	isDiff, err := install.RegenerateKubeconfig(plan, opts.generatedAssetsDir)
+	if err != nil {
+		return fmt.Errorf("error generating kubeconfig file: %v", err)
+	}
+
+	if isDiff {
+		util.PrettyPrintWarn(out, "An updated kubeconfig file has been generated in %q", opts.generatedAssetsDir)
+	} else {
+		util.PrettyPrintOk(out, "Found existing kubeconfig file in %q", opts.generatedAssetsDir)
+	}
+
 	// Print message if there's no work to do
 	if len(toUpgrade) == 0 {
 		fmt.Fprintln(out, "All nodes are at the target version. Skipping node upgrades.")
 	} else {
-		if err = upgradeNodes(in, out, *plan, *opts, toUpgrade, executor, preflightExec); err != nil {
+		paused, err := upgradeNodes(out, *plan, *opts, batches, remaining, executor)
+		if err != nil {
+			return err
+		}
+		if paused {
+			return nil
+		}
+	}
+	if opts.resuming && !opts.dryRun {
+		if err := install.RemovePausedUpgrade(install.DefaultRunsDirectory); err != nil {
 			return err
 		}
 	}
@@ -244,20 +330,40 @@ without the "--partial-ok" flag to perform a full upgrade.
 	return nil
 }
 
-func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOpts, nodesNeedUpgrade []install.ListableNode, executor install.Executor, preflightExec install.PreFlightExecutor) error {
+// storageGlusterClient returns a client that runs gluster commands on the first storage node.
+// The client is not usable when the cluster does not have storage nodes.
+func storageGlusterClient(plan install.Plan) (data.RemoteGlusterCLI, error) {
+	if len(plan.Storage.Nodes) == 0 {
+		return data.RemoteGlusterCLI{}, nil
+	}
+	client, err := plan.GetSSHClient(plan.Storage.Nodes[0].Host)
+	if err != nil {
+		return data.RemoteGlusterCLI{}, fmt.Errorf("error getting SSH client: %v", err)
+	}
+	return data.RemoteGlusterCLI{SSHClient: client}, nil
+}
+
+// validateUpgradeNodes runs the safety checks of an online upgrade and the upgrade
+// preflight checks, and returns the nodes that can be upgraded. The Kubernetes
+// client is only returned when doing an online upgrade.
+func validateUpgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts *upgradeOpts, nodesNeedUpgrade []install.ListableNode, preflightExec install.PreFlightExecutor) ([]install.ListableNode, data.ClusterClient, error) {
 	// Run safety checks if doing an online upgrade
 	unsafeNodes := []install.ListableNode{}
+	var kubeClient data.ClusterClient
 	if opts.online {
 		util.PrintHeader(out, "Validate Online Upgrade", '=')
-		// Use the first master node for running kubectl
-		client, err := plan.GetSSHClient(plan.Master.Nodes[0].Host)
+		var err error
+		kubeClient, err = kubernetesClient(out, &plan, opts.generatedAssetsDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		glusterClient, err := storageGlusterClient(plan)
 		if err != nil {
-			return fmt.Errorf("error getting SSH client: %v", err)
+			return nil, nil, err
 		}
-		kubeClient := data.RemoteKubectl{SSHClient: client}
 		for _, node := range nodesNeedUpgrade {
 			util.PrettyPrint(out, "%s %v", node.Node.Host, node.Roles)
-			errs := install.DetectNodeUpgradeSafety(plan, node.Node, kubeClient)
+			errs := install.DetectNodeUpgradeSafety(plan, node.Node, kubeClient, glusterClient)
 			if len(errs) != 0 {
 				if opts.ignoreSafetyChecks {
 					util.PrintWarn(out)
@@ -302,11 +408,11 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 				fmt.Fprintln(out)
 				ans, err := util.PromptForString(in, out, "Unsafe conditions detected, continue with the upgrade anyway?", "N", []string{"N", "y"})
 				if err != nil {
-					return fmt.Errorf("error getting user response: %v", err)
+					return nil, nil, fmt.Errorf("error getting user response: %v", err)
 				}
 				// if not "y" fail safety checks, otherwise continue with upgrade
 				if strings.ToLower(ans) != "y" {
-					return safetyErr
+					return nil, nil, safetyErr
 				}
 				opts.ignoreSafetyChecks = true
 				util.PrettyPrintWarn(out, "\nIgnoring safety checks and continuing with the upgrade")
@@ -320,7 +426,7 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 		for _, node := range nodesNeedUpgrade {
 			util.PrintHeader(out, fmt.Sprintf("Preflight Checks: %s %s", node.Node.Host, node.Roles), '=')
 			if err := preflightExec.RunUpgradePreFlightCheck(&plan, node); err != nil {
-				// return fmt.Errorf("Upgrade preflight check failed: %v", err)
+				// return false, fmt.Errorf("Upgrade preflight check failed: %v", err)
 				unreadyNodes = append(unreadyNodes, node)
 			}
 		}
@@ -328,7 +434,7 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 
 	// Block upgrade if we found unready nodes, and we are not doing a partial upgrade
 	if len(unreadyNodes) > 0 && !opts.partialAllowed {
-		return errors.New("Errors found during preflight checks")
+		return nil, nil, errors.New("Errors found during preflight checks")
 	}
 
 	// Block the upgrade if partial is allowed but there is an etcd or master node
@@ -337,7 +443,7 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 		for _, n := range unreadyNodes {
 			for _, r := range n.Roles {
 				if r == "master" || r == "etcd" {
-					return errors.New("Errors found during preflight checks")
+					return nil, nil, errors.New("Errors found during preflight checks")
 				}
 			}
 		}
@@ -365,9 +471,129 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 		}
 	}
 
+	return toUpgrade, kubeClient, nil
+}
+
+// upgradeBatches returns the batches in which the nodes are upgraded. When doing
+// a canary upgrade, the batches of the canary are returned along with the
+// batches that are upgraded once the canary is verified.
+func upgradeBatches(plan install.Plan, opts upgradeOpts, toUpgrade []install.ListableNode, kubeClient data.ClusterClient) ([]install.UpgradeBatch, []install.UpgradeBatch, error) {
+	batches := install.UpgradeBatches(toUpgrade, opts.maxParallelWorkers)
+	if !opts.online {
+		return batches, nil, nil
+	}
+	maxUnavailable, err := install.ParseMaxUnavailable(opts.maxUnavailable, len(plan.Worker.Nodes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid max-unavailable: %v", err)
+	}
+	batches, err = install.OnlineUpgradeBatches(toUpgrade, maxUnavailable, kubeClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error computing upgrade batches: %v", err)
+	}
+	if opts.canary > 0 {
+		canary, remaining, err := install.CanaryBatches(batches, opts.canary)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid canary: %v", err)
+		}
+		return canary, remaining, nil
+	}
+	return batches, nil, nil
+}
+
+func upgradeNodes(out io.Writer, plan install.Plan, opts upgradeOpts, batches, remaining []install.UpgradeBatch, executor install.Executor) (bool, error) {
+	// Upgrade the canary first, and pause the upgrade once it has been verified
+	if len(remaining) > 0 {
+		return true, upgradeCanary(out, plan, opts, batches, remaining, executor)
+	}
+
 	// Run the upgrade on the nodes that need it
-	if err := executor.UpgradeNodes(plan, toUpgrade, opts.online, opts.maxParallelWorkers, opts.restartServices); err != nil {
-		return fmt.Errorf("Failed to upgrade nodes: %v", err)
+	if err := executor.UpgradeNodes(plan, batches, opts.online, opts.restartServices); err != nil {
+		return false, fmt.Errorf("Failed to upgrade nodes: %v", err)
+	}
+	return false, nil
+}
+
+// upgradeCanary upgrades the canary nodes, verifies them and saves the state
+// of the upgrade so that it can be continued or aborted later on.
+func upgradeCanary(out io.Writer, plan install.Plan, opts upgradeOpts, canary, remaining []install.UpgradeBatch, executor install.Executor) error {
+	util.PrintHeader(out, "Upgrade: Canary", '=')
+	if err := executor.UpgradeNodes(plan, canary, opts.online, opts.restartServices); err != nil {
+		return fmt.Errorf("Failed to upgrade canary nodes: %v", err)
+	}
+	if opts.dryRun {
+		return nil
+	}
+	state := install.PausedUpgrade{
+		PausedAt:           time.Now(),
+		TargetVersion:      install.KismaticVersion.String(),
+		Snapshot:           opts.snapshotName,
+		MaxUnavailable:     opts.maxUnavailable,
+		IgnoreSafetyChecks: opts.ignoreSafetyChecks,
+		RestartServices:    opts.restartServices,
+		PartialAllowed:     opts.partialAllowed,
+	}
+	for _, b := range canary {
+		for _, n := range b.Nodes {
+			state.CanaryNodes = append(state.CanaryNodes, n.Node.Host)
+		}
+	}
+	for _, b := range remaining {
+		for _, n := range b.Nodes {
+			state.RemainingNodes = append(state.RemainingNodes, n.Node.Host)
+		}
+	}
+
+	verifyErr := verifyCanary(out, plan, opts, executor)
+	if verifyErr != nil {
+		state.VerificationError = verifyErr.Error()
+	}
+	if err := install.WritePausedUpgrade(install.DefaultRunsDirectory, state); err != nil {
+		return err
+	}
+	if verifyErr != nil {
+		util.PrintColor(out, util.Red, `
+The canary failed verification and the upgrade has been paused.
+Use "kismatic upgrade abort --rollback" to roll back the upgrade, or fix the
+issue and use "kismatic upgrade continue" to upgrade the remaining nodes.
+
+`)
+		return verifyErr
+	}
+	util.PrintColor(out, util.Green, `
+The canary was upgraded successfully and the upgrade has been paused.
+Use "kismatic upgrade continue" to upgrade the remaining %d nodes, or
+"kismatic upgrade abort" to stop the upgrade.
+
+`, len(state.RemainingNodes))
+	return nil
+}
+
+// verifyCanary runs the smoke test and the user provided checks against the cluster
+func verifyCanary(out io.Writer, plan install.Plan, opts upgradeOpts, executor install.Executor) error {
+	if plan.NetworkConfigured() {
+		if err := executor.RunSmokeTest(&plan); err != nil {
+			return fmt.Errorf("Smoke test failed: %v", err)
+		}
+	}
+	if len(opts.canaryChecks) == 0 {
+		return nil
+	}
+	util.PrintHeader(out, "Verify Canary", '=')
+	kubeconfig, err := filepath.Abs(filepath.Join(opts.generatedAssetsDir, "kubeconfig"))
+	if err != nil {
+		return fmt.Errorf("error getting kubeconfig path: %v", err)
+	}
+	for _, check := range opts.canaryChecks {
+		util.PrettyPrint(out, "Running %q", check)
+		cmd := exec.Command("sh", "-c", check)
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfig)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			util.PrintError(out)
+			fmt.Fprintf(out, "\n%s\n", output)
+			return fmt.Errorf("Canary check %q failed: %v", check, err)
+		}
+		util.PrintOkln(out)
 	}
 	return nil
 }
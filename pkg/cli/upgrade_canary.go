@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdUpgradeContinue returns the command for continuing a paused canary upgrade
+func NewCmdUpgradeContinue(in io.Reader, out io.Writer, opts *upgradeOpts) *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "continue",
+		Short: "Continue a paused canary upgrade",
+		Long: `Continue a paused canary upgrade.
+
+The nodes that were not upgraded as part of the canary are upgraded using the
+options that were given when the upgrade was started, and the cluster services
+are upgraded once all nodes are at the target version.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doUpgradeContinue(in, out, opts)
+		},
+	}
+	return &cmd
+}
+
+func doUpgradeContinue(in io.Reader, out io.Writer, opts *upgradeOpts) error {
+	paused, err := install.ReadPausedUpgrade(install.DefaultRunsDirectory)
+	if err != nil {
+		return err
+	}
+	if paused == nil {
+		return errors.New("There is no paused upgrade to continue")
+	}
+	if paused.TargetVersion != install.KismaticVersion.String() {
+		return fmt.Errorf("The paused upgrade was started by Kismatic v%s, but this is Kismatic v%s", paused.TargetVersion, install.KismaticVersion)
+	}
+	if paused.VerificationError != "" {
+		util.PrettyPrintWarn(out, "The canary failed verification: %s", paused.VerificationError)
+		ans, err := util.PromptForString(in, out, "Continue with the upgrade anyway?", "N", []string{"N", "y"})
+		if err != nil {
+			return fmt.Errorf("error getting user response: %v", err)
+		}
+		if strings.ToLower(ans) != "y" {
+			return errors.New("Upgrade not continued")
+		}
+	}
+	// The canary nodes are at the target version, so they are skipped
+	opts.online = true
+	opts.resuming = true
+	opts.canary = 0
+	opts.maxUnavailable = paused.MaxUnavailable
+	opts.ignoreSafetyChecks = paused.IgnoreSafetyChecks
+	opts.restartServices = opts.restartServices || paused.RestartServices
+	opts.partialAllowed = opts.partialAllowed || paused.PartialAllowed
+	opts.snapshotName = paused.Snapshot
+	return doUpgrade(in, out, opts)
+}
+
+type upgradeAbortOpts struct {
+	rollback bool
+}
+
+// NewCmdUpgradeAbort returns the command for aborting a paused canary upgrade
+func NewCmdUpgradeAbort(in io.Reader, out io.Writer, opts *upgradeOpts) *cobra.Command {
+	abortOpts := upgradeAbortOpts{}
+	cmd := cobra.Command{
+		Use:   "abort",
+		Short: "Abort a paused canary upgrade",
+		Long: `Abort a paused canary upgrade.
+
+The nodes that were upgraded as part of the canary are left at the target
+version, unless --rollback is set, in which case the whole cluster is rolled back
+to the snapshot that was taken before the upgrade started.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doUpgradeAbort(in, out, *opts, abortOpts)
+		},
+	}
+	cmd.Flags().BoolVar(&abortOpts.rollback, "rollback", false, "roll back the cluster to the snapshot taken before the upgrade started")
+	return &cmd
+}
+
+func doUpgradeAbort(in io.Reader, out io.Writer, opts upgradeOpts, abortOpts upgradeAbortOpts) error {
+	paused, err := install.ReadPausedUpgrade(install.DefaultRunsDirectory)
+	if err != nil {
+		return err
+	}
+	if paused == nil {
+		return errors.New("There is no paused upgrade to abort")
+	}
+	if abortOpts.rollback {
+		if paused.Snapshot == "" {
+			return errors.New("The paused upgrade does not have a snapshot to roll back to")
+		}
+		if err := doUpgradeRollback(in, out, opts, upgradeRollbackOpts{snapshot: paused.Snapshot}, nil); err != nil {
+			return err
+		}
+	}
+	if opts.dryRun {
+		return nil
+	}
+	if err := install.RemovePausedUpgrade(install.DefaultRunsDirectory); err != nil {
+		return err
+	}
+	util.PrettyPrintOk(out, "Aborted the paused upgrade")
+	if !abortOpts.rollback {
+		fmt.Fprintf(out, "\nThe canary nodes %s were left at the target version.\n", strings.Join(paused.CanaryNodes, ", "))
+		if paused.Snapshot != "" {
+			fmt.Fprintf(out, "Use \"kismatic upgrade rollback --snapshot %s [HOST...]\" to roll them back.\n", paused.Snapshot)
+		}
+	}
+	return nil
+}
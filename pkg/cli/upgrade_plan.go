@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type upgradePlanOpts struct {
+	outputFormat   string
+	maxUnavailable string
+}
+
+// NewCmdUpgradePlan returns the command for previewing an upgrade
+func NewCmdUpgradePlan(out io.Writer, opts *upgradeOpts) *cobra.Command {
+	planOpts := upgradePlanOpts{}
+	cmd := cobra.Command{
+		Use:   "plan",
+		Short: "Preview the upgrade of your Kubernetes cluster",
+		Long: `Preview the upgrade of your Kubernetes cluster.
+
+Connects to the cluster and prints the order in which nodes would be upgraded,
+along with their current and target versions, their state and the result of the
+safety checks that are performed during an online upgrade.
+
+Use --max-unavailable to preview how worker nodes would be batched during
+an online upgrade, or --max-parallel-workers to preview how they would be
+batched during an offline upgrade.
+
+No changes are made to the cluster or the generated assets.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doUpgradePlan(out, *opts, planOpts)
+		},
+	}
+	cmd.Flags().StringVarP(&planOpts.outputFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	cmd.Flags().IntVar(&opts.maxParallelWorkers, "max-parallel-workers", 1, "the maximum number of worker nodes to be upgraded in parallel")
+	cmd.Flags().StringVar(&planOpts.maxUnavailable, "max-unavailable", "", "preview an online upgrade with the given maximum number of unavailable worker nodes, as a count or as a percentage of the worker nodes (e.g. \"10%\")")
+	return &cmd
+}
+
+func doUpgradePlan(out io.Writer, opts upgradeOpts, planOpts upgradePlanOpts) error {
+	if planOpts.outputFormat != "simple" && planOpts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", planOpts.outputFormat)
+	}
+	if opts.maxParallelWorkers < 1 {
+		return fmt.Errorf("max-parallel-workers must be greater or equal to 1, got: %d", opts.maxParallelWorkers)
+	}
+	// Keep stdout clean when printing JSON
+	status := out
+	if planOpts.outputFormat == "json" {
+		status = os.Stderr
+	}
+
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	if err = validatePlan(status, plan); err != nil {
+		return err
+	}
+	if err = validateSSHConnectivity(status, plan); err != nil {
+		return err
+	}
+
+	cv, err := install.ListVersions(plan)
+	if err != nil {
+		return fmt.Errorf("error listing cluster versions: %v", err)
+	}
+	kubeClient, err := kubernetesClient(status, plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	glusterClient, err := storageGlusterClient(*plan)
+	if err != nil {
+		return err
+	}
+	var maxUnavailable int
+	if planOpts.maxUnavailable != "" {
+		if maxUnavailable, err = install.ParseMaxUnavailable(planOpts.maxUnavailable, len(plan.Worker.Nodes)); err != nil {
+			return fmt.Errorf("invalid max-unavailable: %v", err)
+		}
+	}
+	up, err := install.BuildUpgradePlan(*plan, cv, opts.maxParallelWorkers, maxUnavailable, kubeClient, glusterClient)
+	if err != nil {
+		return fmt.Errorf("error computing upgrade plan: %v", err)
+	}
+
+	if planOpts.outputFormat == "json" {
+		b, err := json.MarshalIndent(up, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling upgrade plan: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	}
+	return printUpgradePlan(out, up)
+}
+
+func printUpgradePlan(out io.Writer, up *install.UpgradePlan) error {
+	fmt.Fprintf(out, "\nTarget Version: v%s (Kubernetes %s)\n", up.TargetVersion, up.TargetKubernetesVersion)
+	if len(up.Batches) == 0 {
+		fmt.Fprintln(out, "All nodes are at the target version. Skipping node upgrades.")
+	}
+	for i, b := range up.Batches {
+		util.PrintHeader(out, fmt.Sprintf("Step %d: %s", i+1, phaseDescription(b.Phase, len(b.Nodes))), '=')
+		if err := printNodeUpgradePlans(out, b.Nodes, true); err != nil {
+			return err
+		}
+		for _, n := range b.Nodes {
+			if len(n.SafetyErrors) == 0 {
+				continue
+			}
+			fmt.Fprintf(out, "\nOnline upgrade safety checks failed for %q:\n", n.Host)
+			for _, e := range n.SafetyErrors {
+				fmt.Fprintln(out, "-", e)
+			}
+		}
+	}
+	if len(up.Skipped) > 0 {
+		util.PrintHeader(out, "Skipping nodes at the target version", '=')
+		if err := printNodeUpgradePlans(out, up.Skipped, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func phaseDescription(phase string, count int) string {
+	switch phase {
+	case "etcd":
+		return "Etcd node"
+	case "master":
+		return "Master node"
+	case "storage":
+		return "Storage node"
+	}
+	if count == 1 {
+		return "Worker node"
+	}
+	return fmt.Sprintf("%d worker nodes in parallel", count)
+}
+
+// safety checks are only run against the nodes that would be upgraded
+func printNodeUpgradePlans(out io.Writer, nodes []install.NodeUpgradePlan, safetyChecked bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tIP\tRoles\tStatus\tKismatic Version\tKubernetes Version\tSafe\n")
+	for _, n := range nodes {
+		safe := "yes"
+		if !safetyChecked {
+			safe = "-"
+		} else if len(n.SafetyErrors) > 0 {
+			safe = "no"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\tv%s -> v%s\t%s -> %s\t%s\n", n.Host, n.IP, strings.Join(n.Roles, ","), n.Status,
+			n.CurrentVersion, n.TargetVersion, versionOrUnknown(n.CurrentKubernetesVersion), n.TargetKubernetesVersion, safe)
+	}
+	return w.Flush()
+}
+
+func versionOrUnknown(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
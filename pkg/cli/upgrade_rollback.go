@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type upgradeRollbackOpts struct {
+	snapshot        string
+	list            bool
+	skipEtcdRestore bool
+}
+
+// NewCmdUpgradeRollback returns the command for rolling back an upgrade
+func NewCmdUpgradeRollback(in io.Reader, out io.Writer, opts *upgradeOpts) *cobra.Command {
+	rollbackOpts := upgradeRollbackOpts{}
+	cmd := cobra.Command{
+		Use:   "rollback [HOST...]",
+		Short: "Roll back an upgrade of your Kubernetes cluster",
+		Long: `Roll back an upgrade of your Kubernetes cluster.
+
+Before upgrading, Kismatic takes a snapshot of the cluster: the etcd data,
+the package and image versions installed on each node, the configuration
+of each node, the plan file and the generated assets.
+
+When one or more hosts are given, only those nodes are rolled back to the
+package versions and configuration recorded in the snapshot.
+
+When no hosts are given, the whole cluster is rolled back. The plan file and
+generated assets are restored, the control plane is stopped, the etcd cluster is
+restored from the snapshot (unless --skip-etcd-restore is set), and every node is
+rolled back, one node at a time. Any changes made to the cluster after the snapshot
+was taken will be lost.
+
+When no snapshot is given, the most recent snapshot that was taken before the
+upgrade started is used. Snapshots taken when retrying an upgrade that was
+partially applied are not used unless given with --snapshot.
+
+Once the rollback is complete, the versions reported by the nodes are verified
+against the snapshot, and the nodes and the control plane must be healthy.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doUpgradeRollback(in, out, *opts, rollbackOpts, args)
+		},
+	}
+	cmd.Flags().StringVar(&rollbackOpts.snapshot, "snapshot", "", "the name of the snapshot to roll back to. Defaults to the most recent pre-upgrade snapshot")
+	cmd.Flags().BoolVar(&rollbackOpts.list, "list", false, "list the available snapshots")
+	cmd.Flags().BoolVar(&rollbackOpts.skipEtcdRestore, "skip-etcd-restore", false, "do not restore the etcd cluster when rolling back the whole cluster")
+	return &cmd
+}
+
+func doUpgradeRollback(in io.Reader, out io.Writer, opts upgradeOpts, rollbackOpts upgradeRollbackOpts, hosts []string) error {
+	if rollbackOpts.list {
+		return printUpgradeSnapshots(out, opts.generatedAssetsDir)
+	}
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFile}
+	}
+	snapshot, err := install.GetUpgradeSnapshot(opts.generatedAssetsDir, rollbackOpts.snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Roll back the nodes in the same order in which they are upgraded
+	wholeCluster := len(hosts) == 0
+	restoreEtcd := wholeCluster && !rollbackOpts.skipEtcdRestore
+	var selected []install.ListableNode
+	for _, ns := range snapshot.Nodes {
+		if wholeCluster || util.Contains(ns.Host, hosts) {
+			selected = append(selected, install.ListableNode{Node: ns.Node(), Roles: ns.Roles})
+		}
+	}
+	for _, h := range hosts {
+		found := false
+		for _, n := range selected {
+			if n.Node.Host == h {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("node %q was not found in snapshot %q", h, snapshot.Name)
+		}
+	}
+	var nodes []install.NodeSnapshot
+	for _, b := range install.UpgradeBatches(selected, 1) {
+		for _, n := range b.Nodes {
+			for _, ns := range snapshot.Nodes {
+				if ns.Host == n.Node.Host {
+					nodes = append(nodes, ns)
+				}
+			}
+		}
+	}
+
+	util.PrintHeader(out, fmt.Sprintf("Rollback to snapshot %q taken on %s", snapshot.Name, snapshot.CreatedAt.Format("2006-01-02 15:04:05")), '=')
+	if err := printNodeSnapshots(out, nodes); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if wholeCluster {
+		fmt.Fprintf(out, "The plan file %q and the generated assets in %q will be restored.\n", opts.planFile, opts.generatedAssetsDir)
+	}
+	if restoreEtcd {
+		fmt.Fprintln(out, "The etcd cluster will be restored. Changes made to the cluster after the snapshot was taken will be lost.")
+	}
+	if !opts.dryRun {
+		ans, err := util.PromptForString(in, out, "Continue with the rollback?", "N", []string{"N", "y"})
+		if err != nil {
+			return fmt.Errorf("error getting user response: %v", err)
+		}
+		if strings.ToLower(ans) != "y" {
+			return errors.New("Rollback aborted")
+		}
+		if wholeCluster {
+			if err := install.RestoreGeneratedAssets(*snapshot, opts.planFile, opts.generatedAssetsDir); err != nil {
+				return fmt.Errorf("error restoring generated assets: %v", err)
+			}
+			util.PrettyPrintOk(out, "Restored plan file and generated assets")
+		}
+	}
+
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file %q: %v", opts.planFile, err)
+	}
+	if err = validateSSHConnectivity(out, plan); err != nil {
+		return err
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+		DryRun:                   opts.dryRun,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	if err != nil {
+		return err
+	}
+	if err := executor.RollbackNodes(*plan, *snapshot, nodes, restoreEtcd); err != nil {
+		return fmt.Errorf("Failed to roll back nodes: %v", err)
+	}
+	if opts.dryRun {
+		return nil
+	}
+
+	// Verify that the nodes are at the versions recorded in the snapshot, and healthy
+	util.PrintHeader(out, "Verify Rollback", '=')
+	cv, err := install.ListVersions(plan)
+	if err != nil {
+		return fmt.Errorf("error listing cluster versions: %v", err)
+	}
+	rolledBack := install.ClusterVersion{}
+	for _, n := range cv.Nodes {
+		for _, ns := range nodes {
+			if ns.Host == n.Node.Host {
+				rolledBack.Nodes = append(rolledBack.Nodes, n)
+			}
+		}
+	}
+	kubeClient, err := kubernetesClient(out, plan, opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	if errs := install.VerifyRollback(*snapshot, rolledBack, kubeClient); len(errs) > 0 {
+		util.PrintValidationErrors(out, errs)
+		return errors.New("the nodes are not at the versions recorded in the snapshot, or are not healthy")
+	}
+	util.PrettyPrintOk(out, "Nodes are at the versions recorded in the snapshot, and healthy")
+	fmt.Fprintln(out)
+	util.PrintColor(out, util.Green, "The rollback was completed successfully!\n")
+	fmt.Fprintln(out)
+	return nil
+}
+
+func printUpgradeSnapshots(out io.Writer, generatedAssetsDir string) error {
+	snapshots, err := install.ListUpgradeSnapshots(generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Fprintln(out, "No upgrade snapshots found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tCreated\tUpgraded To\tPre-Upgrade\tNodes\n")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\tv%s\t%t\t%d\n", s.Name, s.CreatedAt.Format("2006-01-02 15:04:05"), s.TargetVersion, s.PreUpgrade, len(s.Nodes))
+	}
+	return w.Flush()
+}
+
+func printNodeSnapshots(out io.Writer, nodes []install.NodeSnapshot) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "Name\tIP\tRoles\tKismatic Version\tKubernetes Version\tPackages\n")
+	for _, n := range nodes {
+		var pkgs []string
+		for name, version := range n.Packages {
+			pkgs = append(pkgs, name+"="+version)
+		}
+		sort.Strings(pkgs)
+		fmt.Fprintf(w, "%s\t%s\t%s\tv%s\t%s\t%s\n", n.Host, n.IP, strings.Join(n.Roles, ","), n.KismaticVersion,
+			versionOrUnknown(n.ComponentVersions.Kubernetes), strings.Join(pkgs, ","))
+	}
+	return w.Flush()
+}
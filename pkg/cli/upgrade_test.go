@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUpgradeOnlineCanaryChecksAreNotSplit(t *testing.T) {
+	opts := &upgradeOpts{}
+	cmd := NewCmdUpgradeOnline(&bytes.Buffer{}, &bytes.Buffer{}, opts)
+	args := []string{"--canary-check", "kubectl get pods -l 'app in (web,api)'", "--canary-check", "./check.sh"}
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"kubectl get pods -l 'app in (web,api)'", "./check.sh"}
+	if !reflect.DeepEqual(opts.canaryChecks, expected) {
+		t.Errorf("expected %q, but got %q", expected, opts.canaryChecks)
+	}
+}
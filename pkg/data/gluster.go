@@ -13,6 +13,12 @@ type GlusterClient interface {
 	GetQuota(volume string) (*GlusterVolumeQuotaCliOutput, error)
 }
 
+// GlusterHealthGetter gets the state of the bricks that make up a gluster volume
+type GlusterHealthGetter interface {
+	GetVolumeStatus(volume string) (*GlusterVolumeStatusCliOutput, error)
+	GetHealInfo(volume string) (*GlusterVolumeHealInfoCliOutput, error)
+}
+
 type RemoteGlusterCLI struct {
 	SSHClient ssh.Client
 }
@@ -65,3 +71,49 @@ func UnmarshalVolumeQuota(raw string) (*GlusterVolumeQuotaCliOutput, error) {
 
 	return &glusterVolumeQuota, nil
 }
+
+// GetVolumeStatus returns the status of the processes serving the volume using gluster command on the first storage node
+func (g RemoteGlusterCLI) GetVolumeStatus(volume string) (*GlusterVolumeStatusCliOutput, error) {
+	raw, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster volume status %s --xml", volume))
+	if err != nil {
+		return nil, fmt.Errorf("error getting volume status data for %s: %v", volume, err)
+	}
+
+	return UnmarshalVolumeStatus(raw)
+}
+
+func UnmarshalVolumeStatus(raw string) (*GlusterVolumeStatusCliOutput, error) {
+	var status GlusterVolumeStatusCliOutput
+	err := xml.Unmarshal([]byte(strings.TrimSpace(raw)), &status)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling volume status data: %v", err)
+	}
+	if status.VolumeStatus == nil || status.VolumeStatus.Volumes == nil {
+		return nil, fmt.Errorf("error getting volume status data")
+	}
+
+	return &status, nil
+}
+
+// GetHealInfo returns the entries pending self-heal on each brick of the volume using gluster command on the first storage node
+func (g RemoteGlusterCLI) GetHealInfo(volume string) (*GlusterVolumeHealInfoCliOutput, error) {
+	raw, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster volume heal %s info --xml", volume))
+	if err != nil {
+		return nil, fmt.Errorf("error getting volume heal info for %s: %v", volume, err)
+	}
+
+	return UnmarshalHealInfo(raw)
+}
+
+func UnmarshalHealInfo(raw string) (*GlusterVolumeHealInfoCliOutput, error) {
+	var healInfo GlusterVolumeHealInfoCliOutput
+	err := xml.Unmarshal([]byte(strings.TrimSpace(raw)), &healInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling volume heal info: %v", err)
+	}
+	if healInfo.HealInfo == nil || healInfo.HealInfo.Bricks == nil {
+		return nil, fmt.Errorf("error getting volume heal info")
+	}
+
+	return &healInfo, nil
+}
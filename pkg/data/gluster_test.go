@@ -41,3 +41,100 @@ func TestUnmarshalVolumeQuota(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalVolumeStatus(t *testing.T) {
+	raw := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+<opRet>0</opRet>
+<opErrno>0</opErrno>
+<opErrstr/>
+<volStatus>
+<volumes>
+<volume>
+<volName>storage01</volName>
+<nodeCount>3</nodeCount>
+<node>
+<hostname>storage1</hostname>
+<path>/data/storage01</path>
+<peerid>6a1c8a2c-2d3e-4b0d-9a1f-0c4d1c3a0e11</peerid>
+<status>1</status>
+<port>49152</port>
+<pid>1234</pid>
+</node>
+<node>
+<hostname>storage2</hostname>
+<path>/data/storage01</path>
+<peerid>9b1f0a3e-7c2d-4e5f-8a6b-1d2c3e4f5a6b</peerid>
+<status>0</status>
+<port>N/A</port>
+<pid>-1</pid>
+</node>
+<node>
+<hostname>Self-heal Daemon</hostname>
+<path>localhost</path>
+<peerid>6a1c8a2c-2d3e-4b0d-9a1f-0c4d1c3a0e11</peerid>
+<status>1</status>
+<port>N/A</port>
+<pid>1250</pid>
+</node>
+</volume>
+</volumes>
+</volStatus>
+</cliOutput>`
+	status, err := UnmarshalVolumeStatus(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vols := status.VolumeStatus.Volumes.Volume
+	if len(vols) != 1 || vols[0].Name != "storage01" {
+		t.Fatalf("expected volume storage01, but got %+v", vols)
+	}
+	if len(vols[0].Nodes) != 3 {
+		t.Fatalf("expected 3 nodes, but got %d", len(vols[0].Nodes))
+	}
+	if n := vols[0].Nodes[1]; n.Hostname != "storage2" || n.Path != "/data/storage01" || n.Status != 0 {
+		t.Errorf("unexpected node status: %+v", n)
+	}
+
+	if _, err := UnmarshalVolumeStatus(tests[1]); err == nil {
+		t.Error("expected an error when the volume does not exist")
+	}
+}
+
+func TestUnmarshalHealInfo(t *testing.T) {
+	raw := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+<healInfo>
+<bricks>
+<brick hostUuid="6a1c8a2c-2d3e-4b0d-9a1f-0c4d1c3a0e11">
+<name>storage1:/data/storage01</name>
+<file gfid="ad6d7f3e-0b2c-4bd9-8c2a-5c1b9e2d3f4a">/foo</file>
+<status>Connected</status>
+<numberOfEntries>1</numberOfEntries>
+</brick>
+<brick hostUuid="-">
+<name>storage2:/data/storage01</name>
+<status>Transport endpoint is not connected</status>
+<numberOfEntries>-</numberOfEntries>
+</brick>
+</bricks>
+</healInfo>
+<opRet>0</opRet>
+<opErrno>0</opErrno>
+<opErrstr/>
+</cliOutput>`
+	heal, err := UnmarshalHealInfo(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bricks := heal.HealInfo.Bricks.Brick
+	if len(bricks) != 2 {
+		t.Fatalf("expected 2 bricks, but got %d", len(bricks))
+	}
+	if bricks[0].Name != "storage1:/data/storage01" || bricks[0].NumberOfEntries != "1" {
+		t.Errorf("unexpected heal info for first brick: %+v", bricks[0])
+	}
+	if bricks[1].NumberOfEntries != "-" {
+		t.Errorf("expected disconnected brick to have %q entries, but got %q", "-", bricks[1].NumberOfEntries)
+	}
+}
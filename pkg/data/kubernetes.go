@@ -48,6 +48,31 @@ type StatefulSetGetter interface {
 	GetStatefulSet(namespace, name string) (*StatefulSet, error)
 }
 
+// DeploymentGetter gets a deployment
+type DeploymentGetter interface {
+	GetDeployment(namespace, name string) (*Deployment, error)
+}
+
+// ServiceGetter gets a service
+type ServiceGetter interface {
+	GetService(namespace, name string) (*Service, error)
+}
+
+// JobGetter gets a job
+type JobGetter interface {
+	GetJob(namespace, name string) (*Job, error)
+}
+
+// PodDisruptionBudgetLister lists the pod disruption budgets of all namespaces
+type PodDisruptionBudgetLister interface {
+	ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error)
+}
+
+// NodeLister lists the nodes that are registered with a Kubernetes cluster
+type NodeLister interface {
+	ListNodes() (*NodeList, error)
+}
+
 type KubernetesClient interface {
 	PodLister
 	PVLister
@@ -102,6 +127,27 @@ func UnmarshalPods(raw string) (*PodList, error) {
 	return &pods, nil
 }
 
+// ListNodes returns the nodes that are registered with the cluster
+func (k RemoteKubectl) ListNodes() (*NodeList, error) {
+	raw, err := k.SSHClient.Output(true, "sudo kubectl --kubeconfig /root/.kube/config get nodes -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting node data: %v", err)
+	}
+	return UnmarshalNodes(raw)
+}
+
+func UnmarshalNodes(raw string) (*NodeList, error) {
+	if isNoResourcesResponse(raw) {
+		return nil, nil
+	}
+	var nodes NodeList
+	err := json.Unmarshal([]byte(raw), &nodes)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling node data: %v", err)
+	}
+	return &nodes, nil
+}
+
 // GetDaemonSet returns the DaemonSet with the given namespace and name. If not found,
 // returns an error.
 func (k RemoteKubectl) GetDaemonSet(namespace, name string) (*DaemonSet, error) {
@@ -210,6 +256,81 @@ func (k RemoteKubectl) GetStatefulSet(namespace, name string) (*StatefulSet, err
 	return &s, nil
 }
 
+// GetDeployment returns the deployment with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetDeployment(namespace, name string) (*Deployment, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get deployment --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Deployment: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Deployment %s/%s was not found", namespace, name)
+	}
+	var d Deployment
+	if err := json.Unmarshal([]byte(raw), &d); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Deployment: %v", err)
+	}
+	return &d, nil
+}
+
+// GetService returns the service with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetService(namespace, name string) (*Service, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get service --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Service: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Service %s/%s was not found", namespace, name)
+	}
+	var s Service
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Service: %v", err)
+	}
+	return &s, nil
+}
+
+// GetJob returns the job with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetJob(namespace, name string) (*Job, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get job --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Job: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Job %s/%s was not found", namespace, name)
+	}
+	var j Job
+	if err := json.Unmarshal([]byte(raw), &j); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Job: %v", err)
+	}
+	return &j, nil
+}
+
+// ListPodDisruptionBudgets returns the PodDisruptionBudgets of all namespaces
+func (k RemoteKubectl) ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error) {
+	raw, err := k.SSHClient.Output(true, fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get poddisruptionbudgets.%s.%s --all-namespaces=true -o json", podDisruptionBudgetVersion, podDisruptionBudgetGroup))
+	if err != nil {
+		return nil, fmt.Errorf("error getting PodDisruptionBudget data: %v", err)
+	}
+	return UnmarshalPodDisruptionBudgets(raw)
+}
+
+func UnmarshalPodDisruptionBudgets(raw string) (*PodDisruptionBudgetList, error) {
+	if isNoResourcesResponse(raw) {
+		return nil, nil
+	}
+	var pdbs PodDisruptionBudgetList
+	err := json.Unmarshal([]byte(raw), &pdbs)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling PodDisruptionBudget data: %v", err)
+	}
+	return &pdbs, nil
+}
+
 // kubectl will print this message when no resources are returned
 func isNoResourcesResponse(s string) bool {
 	if strings.Contains(strings.TrimSpace(s), "No resources found") {
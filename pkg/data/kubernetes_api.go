@@ -0,0 +1,413 @@
+package data
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultAPITimeout is the timeout of a single request to the API server
+	DefaultAPITimeout = 30 * time.Second
+	// DefaultAPIPageSize is the number of items requested per page when listing resources
+	DefaultAPIPageSize = 500
+)
+
+// ClusterClient reads the resources of a Kubernetes cluster
+type ClusterClient interface {
+	KubernetesClient
+	NodeLister
+	PersistentVolumeGetter
+	PersistentVolumeClaimGetter
+	DaemonSetGetter
+	ReplicationControllerGetter
+	ReplicaSetGetter
+	StatefulSetGetter
+	DeploymentGetter
+	JobGetter
+	ServiceGetter
+	PodDisruptionBudgetLister
+}
+
+// APIClient is a Kubernetes client that talks to the API server over HTTPS,
+// authenticating with a client certificate.
+type APIClient struct {
+	// Server is the URL of the API server, e.g. https://10.0.0.1:6443
+	Server     string
+	HTTPClient *http.Client
+	// PageSize is the maximum number of items returned by the API server in a
+	// single response when listing resources
+	PageSize int
+}
+
+// NewAPIClient returns a client for the API server at the given URL, using the
+// given PEM encoded CA certificate, client certificate and key
+func NewAPIClient(server string, caPEM, certPEM, keyPEM []byte, timeout time.Duration) (*APIClient, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("error parsing CA certificate")
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing client certificate: %v", err)
+	}
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{cert},
+		},
+		TLSHandshakeTimeout: timeout,
+	}
+	return &APIClient{
+		Server:     strings.TrimSuffix(server, "/"),
+		HTTPClient: &http.Client{Transport: transport, Timeout: timeout},
+		PageSize:   DefaultAPIPageSize,
+	}, nil
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// NewAPIClientFromKubeconfig returns a client that uses the server and the
+// credentials of the current context of the kubeconfig file
+func NewAPIClientFromKubeconfig(file string, timeout time.Duration) (*APIClient, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubeconfig file: %v", err)
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(b, &kc); err != nil {
+		return nil, fmt.Errorf("error unmarshalling kubeconfig file %q: %v", file, err)
+	}
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("context %q was not found in kubeconfig file %q", kc.CurrentContext, file)
+	}
+	var server, ca, cert, key string
+	for _, c := range kc.Clusters {
+		if c.Name == clusterName {
+			server, ca = c.Cluster.Server, c.Cluster.CertificateAuthorityData
+		}
+	}
+	for _, u := range kc.Users {
+		if u.Name == userName {
+			cert, key = u.User.ClientCertificateData, u.User.ClientKeyData
+		}
+	}
+	if server == "" {
+		return nil, fmt.Errorf("cluster %q was not found in kubeconfig file %q", clusterName, file)
+	}
+	decoded := make([][]byte, 3)
+	for i, d := range []string{ca, cert, key} {
+		if decoded[i], err = base64.StdEncoding.DecodeString(d); err != nil {
+			return nil, fmt.Errorf("error decoding credentials in kubeconfig file %q: %v", file, err)
+		}
+	}
+	return NewAPIClient(server, decoded[0], decoded[1], decoded[2], timeout)
+}
+
+// apiStatusErr is returned when the API server responds with an error
+type apiStatusErr struct {
+	code    int
+	message string
+}
+
+func (e apiStatusErr) Error() string {
+	return fmt.Sprintf("API server responded with status %d: %s", e.code, e.message)
+}
+
+// Healthy returns an error if the API server cannot be reached
+func (c *APIClient) Healthy() error {
+	b, err := c.do("/healthz", nil)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(b)) != "ok" {
+		return fmt.Errorf("API server is not healthy: %s", b)
+	}
+	return nil
+}
+
+func (c *APIClient) do(path string, query url.Values) ([]byte, error) {
+	u := c.Server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := c.HTTPClient.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the API server: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from the API server: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		status := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(b, &status) != nil || status.Message == "" {
+			status.Message = http.StatusText(resp.StatusCode)
+		}
+		return nil, apiStatusErr{code: resp.StatusCode, message: status.Message}
+	}
+	return b, nil
+}
+
+// get unmarshals the resource at the given path. The kind and name are used
+// in the error message when the resource does not exist.
+func (c *APIClient) get(path string, kind string, name string, into interface{}) error {
+	b, err := c.do(path, nil)
+	if err != nil {
+		if e, ok := err.(apiStatusErr); ok && e.code == http.StatusNotFound {
+			return fmt.Errorf("%s %s was not found", kind, name)
+		}
+		return fmt.Errorf("error getting %s: %v", kind, err)
+	}
+	if err := json.Unmarshal(b, into); err != nil {
+		return fmt.Errorf("error unmarshalling %s: %v", kind, err)
+	}
+	return nil
+}
+
+// list requests the resources at the given path one page at a time, and
+// calls page with the raw response of each page
+func (c *APIClient) list(path string, kind string, page func(raw []byte) error) error {
+	query := url.Values{}
+	if c.PageSize > 0 {
+		query.Set("limit", strconv.Itoa(c.PageSize))
+	}
+	for {
+		b, err := c.do(path, query)
+		if err != nil {
+			return fmt.Errorf("error listing %s: %v", kind, err)
+		}
+		if err := page(b); err != nil {
+			return fmt.Errorf("error unmarshalling %s: %v", kind, err)
+		}
+		meta := struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+		}{}
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return fmt.Errorf("error unmarshalling %s: %v", kind, err)
+		}
+		if meta.Metadata.Continue == "" {
+			return nil
+		}
+		query.Set("continue", meta.Metadata.Continue)
+	}
+}
+
+// ListPods returns the pods of all namespaces
+func (c *APIClient) ListPods() (*PodList, error) {
+	pods := &PodList{}
+	err := c.list("/api/v1/pods", "pods", func(raw []byte) error {
+		var p PodList
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		pods.Items = append(pods.Items, p.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil
+	}
+	return pods, nil
+}
+
+// ListPersistentVolumes returns the persistent volumes of the cluster
+func (c *APIClient) ListPersistentVolumes() (*PersistentVolumeList, error) {
+	pvs := &PersistentVolumeList{}
+	err := c.list("/api/v1/persistentvolumes", "persistent volumes", func(raw []byte) error {
+		var p PersistentVolumeList
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		pvs.Items = append(pvs.Items, p.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pvs.Items) == 0 {
+		return nil, nil
+	}
+	return pvs, nil
+}
+
+// ListNodes returns the nodes that are registered with the cluster
+func (c *APIClient) ListNodes() (*NodeList, error) {
+	nodes := &NodeList{}
+	err := c.list("/api/v1/nodes", "nodes", func(raw []byte) error {
+		var n NodeList
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return err
+		}
+		nodes.Items = append(nodes.Items, n.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes.Items) == 0 {
+		return nil, nil
+	}
+	return nodes, nil
+}
+
+// ListPodDisruptionBudgets returns the PodDisruptionBudgets of all namespaces
+func (c *APIClient) ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error) {
+	pdbs := &PodDisruptionBudgetList{}
+	err := c.list("/apis/"+podDisruptionBudgetGroup+"/"+podDisruptionBudgetVersion+"/poddisruptionbudgets", "PodDisruptionBudgets", func(raw []byte) error {
+		var p PodDisruptionBudgetList
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		pdbs.Items = append(pdbs.Items, p.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pdbs.Items) == 0 {
+		return nil, nil
+	}
+	return pdbs, nil
+}
+
+// GetPersistentVolume returns the persistent volume with the given name.
+// If not found, returns an error.
+func (c *APIClient) GetPersistentVolume(name string) (*PersistentVolume, error) {
+	var p PersistentVolume
+	if err := c.get("/api/v1/persistentvolumes/"+url.PathEscape(name), "PersistentVolume", name, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPersistentVolumeClaim returns the persistent volume claim with the given name and namespace.
+// If not found, returns an error.
+func (c *APIClient) GetPersistentVolumeClaim(namespace, name string) (*PersistentVolumeClaim, error) {
+	var p PersistentVolumeClaim
+	if err := c.get(namespacedPath("/api/v1", namespace, "persistentvolumeclaims", name), "PersistentVolumeClaim", namespace+"/"+name, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetDaemonSet returns the DaemonSet with the given namespace and name. If not found,
+// returns an error.
+func (c *APIClient) GetDaemonSet(namespace, name string) (*DaemonSet, error) {
+	var d DaemonSet
+	if err := c.get(namespacedPath("/apis/apps/v1", namespace, "daemonsets", name), "DaemonSet", namespace+"/"+name, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetReplicationController returns the ReplicationController with the given name in the given namespace.
+// If not found, returns an error.
+func (c *APIClient) GetReplicationController(namespace, name string) (*ReplicationController, error) {
+	var r ReplicationController
+	if err := c.get(namespacedPath("/api/v1", namespace, "replicationcontrollers", name), "ReplicationController", namespace+"/"+name, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetReplicaSet returns the ReplicaSet with the given name in the given namespace.
+// If not found, returns an error.
+func (c *APIClient) GetReplicaSet(namespace, name string) (*ReplicaSet, error) {
+	var r ReplicaSet
+	if err := c.get(namespacedPath("/apis/apps/v1", namespace, "replicasets", name), "ReplicaSet", namespace+"/"+name, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetStatefulSet returns the stateful set with the given name in the given namespace.
+// If not found, returns an error.
+func (c *APIClient) GetStatefulSet(namespace, name string) (*StatefulSet, error) {
+	var s StatefulSet
+	if err := c.get(namespacedPath("/apis/apps/v1", namespace, "statefulsets", name), "StatefulSet", namespace+"/"+name, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetDeployment returns the deployment with the given name in the given namespace.
+// If not found, returns an error.
+func (c *APIClient) GetDeployment(namespace, name string) (*Deployment, error) {
+	var d Deployment
+	if err := c.get(namespacedPath("/apis/apps/v1", namespace, "deployments", name), "Deployment", namespace+"/"+name, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetJob returns the job with the given name in the given namespace.
+// If not found, returns an error.
+func (c *APIClient) GetJob(namespace, name string) (*Job, error) {
+	var j Job
+	if err := c.get(namespacedPath("/apis/batch/v1", namespace, "jobs", name), "Job", namespace+"/"+name, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// GetService returns the service with the given name in the given namespace.
+// If not found, returns an error.
+func (c *APIClient) GetService(namespace, name string) (*Service, error) {
+	var s Service
+	if err := c.get(namespacedPath("/api/v1", namespace, "services", name), "Service", namespace+"/"+name, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func namespacedPath(prefix, namespace, resource, name string) string {
+	return fmt.Sprintf("%s/namespaces/%s/%s/%s", prefix, url.PathEscape(namespace), resource, url.PathEscape(name))
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshalling key: %v", err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// newFakeAPIServer starts a TLS server that requires a client certificate
+// signed by the returned CA. The returned client certificate is accepted.
+func newFakeAPIServer(t *testing.T, handler http.Handler) (server *httptest.Server, ca *testCert, client *testCert) {
+	ca = newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	serverCert := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kube-apiserver"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client = newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "admin", Organization: []string{"system:masters"}},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	pair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	if err != nil {
+		t.Fatalf("error loading server certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	server = httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	return server, ca, client
+}
+
+func newTestAPIClient(t *testing.T, server *httptest.Server, ca, client *testCert, timeout time.Duration) *APIClient {
+	c, err := NewAPIClient(server.URL, ca.certPEM, client.certPEM, client.keyPEM, timeout)
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+	return c
+}
+
+func TestAPIClientListPodsPaging(t *testing.T) {
+	pages := map[string]string{
+		"":      `{"metadata":{"continue":"page2"},"items":[{"metadata":{"name":"pod1","namespace":"default"}}]}`,
+		"page2": `{"metadata":{"continue":"page3"},"items":[{"metadata":{"name":"pod2","namespace":"default"}}]}`,
+		"page3": `{"metadata":{},"items":[{"metadata":{"name":"pod3","namespace":"kube-system"}}]}`,
+	}
+	var limits []string
+	server, ca, client := newFakeAPIServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		limits = append(limits, r.URL.Query().Get("limit"))
+		page, ok := pages[r.URL.Query().Get("continue")]
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	c := newTestAPIClient(t, server, ca, client, time.Second)
+	c.PageSize = 1
+	pods, err := c.ListPods()
+	if err != nil {
+		t.Fatalf("unexpected error listing pods: %v", err)
+	}
+	if len(pods.Items) != 3 || pods.Items[0].Name != "pod1" || pods.Items[2].Name != "pod3" {
+		t.Errorf("expected the pods of all pages, but got %+v", pods.Items)
+	}
+	for _, l := range limits {
+		if l != "1" {
+			t.Errorf("expected a limit of 1 on every request, but got %v", limits)
+		}
+	}
+}
+
+func TestAPIClientGetDeployment(t *testing.T) {
+	server, ca, client := newFakeAPIServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apis/apps/v1/namespaces/kube-system/deployments/coredns":
+			fmt.Fprint(w, `{"metadata":{"name":"coredns","namespace":"kube-system"},"spec":{"replicas":2,"template":{"spec":{"containers":[{"name":"coredns","image":"coredns:1.6.2"}]}}},"status":{"replicas":2,"availableReplicas":1}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","message":"deployments.apps \"foo\" not found","code":404}`)
+		}
+	}))
+	defer server.Close()
+
+	c := newTestAPIClient(t, server, ca, client, time.Second)
+	d, err := c.GetDeployment("kube-system", "coredns")
+	if err != nil {
+		t.Fatalf("unexpected error getting deployment: %v", err)
+	}
+	if d.Name != "coredns" || *d.Spec.Replicas != 2 || d.Status.AvailableReplicas != 1 || d.Spec.Template.Spec.Containers[0].Image != "coredns:1.6.2" {
+		t.Errorf("unexpected deployment %+v", d)
+	}
+	if _, err := c.GetDeployment("kube-system", "foo"); err == nil || err.Error() != "Deployment kube-system/foo was not found" {
+		t.Errorf("expected a not found error, but got %v", err)
+	}
+}
+
+func TestAPIClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server, ca, client := newFakeAPIServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	c := newTestAPIClient(t, server, ca, client, 100*time.Millisecond)
+	if _, err := c.ListNodes(); err == nil {
+		t.Error("expected an error when the API server does not respond in time")
+	}
+}
+
+func TestAPIClientRequiresClientCertificate(t *testing.T) {
+	server, ca, _ := newFakeAPIServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	// A client certificate that was not signed by the cluster CA
+	other := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "other"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil)
+	c := newTestAPIClient(t, server, ca, other, time.Second)
+	if err := c.Healthy(); err == nil {
+		t.Error("expected an error when using a client certificate that is not signed by the CA")
+	}
+}
+
+func TestNewAPIClientFromKubeconfig(t *testing.T) {
+	server, ca, client := newFakeAPIServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tmpDir, err := ioutil.TempDir("", "ket-kubeconfig-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: %s
+  name: kubernetes
+contexts:
+- context:
+    cluster: kubernetes
+    user: admin
+  name: kubernetes-admin
+current-context: kubernetes-admin
+kind: Config
+preferences: {}
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+    client-key-data: %s
+    token:
+`, base64.StdEncoding.EncodeToString(ca.certPEM), server.URL,
+		base64.StdEncoding.EncodeToString(client.certPEM), base64.StdEncoding.EncodeToString(client.keyPEM))
+	file := filepath.Join(tmpDir, "kubeconfig")
+	if err := ioutil.WriteFile(file, []byte(kubeconfig), 0644); err != nil {
+		t.Fatalf("error writing kubeconfig: %v", err)
+	}
+
+	c, err := NewAPIClientFromKubeconfig(file, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error creating client: %v", err)
+	}
+	if err := c.Healthy(); err != nil {
+		t.Errorf("unexpected error checking API server health: %v", err)
+	}
+}
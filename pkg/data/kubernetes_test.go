@@ -0,0 +1,87 @@
+package data
+
+import "testing"
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "foo", "tier": "web"}
+	tests := []struct {
+		selector *LabelSelector
+		expected bool
+	}{
+		{
+			selector: nil,
+			expected: false,
+		},
+		{
+			selector: &LabelSelector{},
+			expected: true,
+		},
+		{
+			selector: &LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			expected: true,
+		},
+		{
+			selector: &LabelSelector{MatchLabels: map[string]string{"app": "bar"}},
+			expected: false,
+		},
+		{
+			selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "In", Values: []string{"web", "db"}}}},
+			expected: true,
+		},
+		{
+			selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "NotIn", Values: []string{"web"}}}},
+			expected: false,
+		},
+		{
+			selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "Exists"}}},
+			expected: true,
+		},
+		{
+			selector: &LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "DoesNotExist"}}},
+			expected: false,
+		},
+		{
+			selector: &LabelSelector{
+				MatchLabels:      map[string]string{"app": "foo"},
+				MatchExpressions: []LabelSelectorRequirement{{Key: "version", Operator: "Exists"}},
+			},
+			expected: false,
+		},
+	}
+	for i, test := range tests {
+		if got := test.selector.Matches(labels); got != test.expected {
+			t.Errorf("%d: expected %v, but got %v", i, test.expected, got)
+		}
+	}
+}
+
+func TestUnmarshalPodDisruptionBudgets(t *testing.T) {
+	raw := `{
+  "items": [
+    {
+      "metadata": {"name": "foo", "namespace": "bar"},
+      "spec": {"selector": {"matchLabels": {"app": "foo"}}, "minAvailable": 1},
+      "status": {"currentHealthy": 2, "desiredHealthy": 1, "disruptionsAllowed": 1, "expectedPods": 2}
+    }
+  ]
+}`
+	pdbs, err := UnmarshalPodDisruptionBudgets(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pdbs.Items) != 1 {
+		t.Fatalf("expected 1 item, but got %d", len(pdbs.Items))
+	}
+	pdb := pdbs.Items[0]
+	if pdb.Namespace != "bar" || pdb.Status.DisruptionsAllowed != 1 || pdb.Spec.Selector.MatchLabels["app"] != "foo" {
+		t.Errorf("unexpected PDB: %+v", pdb)
+	}
+
+	pdbs, err = UnmarshalPodDisruptionBudgets("No resources found.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pdbs != nil {
+		t.Errorf("expected nil list, but got %v", pdbs)
+	}
+}
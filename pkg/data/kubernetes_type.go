@@ -1,12 +1,21 @@
 package data
 
+import "time"
+
 type PodList struct {
 	Items []Pod `json:"items"`
 }
 
 type Pod struct {
 	ObjectMeta `json:"metadata,omitempty"`
-	Spec       PodSpec `json:"spec,omitempty"`
+	Spec       PodSpec   `json:"spec,omitempty"`
+	Status     PodStatus `json:"status,omitempty"`
+}
+
+type PodStatus struct {
+	// Phase of the pod, one of Pending, Running, Succeeded, Failed or Unknown
+	Phase     string     `json:"phase,omitempty"`
+	StartTime *time.Time `json:"startTime,omitempty"`
 }
 
 type ObjectMeta struct {
@@ -82,6 +91,7 @@ type PersistentVolumeClaimVolumeSource struct {
 // A single application container that you want to run within a pod.
 type Container struct {
 	Name         string        `json:"name"`
+	Image        string        `json:"image,omitempty"`
 	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
 }
 
@@ -177,9 +187,16 @@ type DaemonSetList struct {
 type DaemonSet struct {
 	TypeMeta   `json:",inline"`
 	ObjectMeta `json:"metadata,omitempty"`
+	Spec       DaemonSetSpec   `json:"spec,omitempty"`
 	Status     DaemonSetStatus `json:"status,omitempty"`
 }
 
+// DaemonSetSpec is the specification of a daemon set.
+type DaemonSetSpec struct {
+	// Template describes the pods that will be created.
+	Template PodTemplateSpec `json:"template"`
+}
+
 // DaemonSetStatus represents the current status of a daemon set.
 type DaemonSetStatus struct {
 	// CurrentNumberScheduled is the number of nodes that are running at least 1
@@ -245,3 +262,231 @@ type StatefulSetStatus struct {
 	// Replicas is the number of actual replicas.
 	Replicas int32
 }
+
+// Deployment enables declarative updates for Pods and ReplicaSets.
+type Deployment struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the desired behavior of the Deployment.
+	Spec DeploymentSpec `json:"spec,omitempty"`
+	// Status is the most recently observed status of the Deployment.
+	Status DeploymentStatus `json:"status,omitempty"`
+}
+
+// DeploymentSpec is the specification of the desired behavior of the Deployment.
+type DeploymentSpec struct {
+	// Number of desired pods.
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Template describes the pods that will be created.
+	Template PodTemplateSpec `json:"template"`
+}
+
+// DeploymentStatus is the most recently observed status of the Deployment.
+type DeploymentStatus struct {
+	// Total number of non-terminated pods targeted by this deployment (their labels match the selector).
+	Replicas int32 `json:"replicas,omitempty"`
+	// Total number of available pods (ready for at least minReadySeconds) targeted by this deployment.
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+}
+
+// PodTemplateSpec describes the data a pod should have when created from a template
+type PodTemplateSpec struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       PodSpec `json:"spec,omitempty"`
+}
+
+// Service is a named abstraction of software service (for example, mysql) consisting of local port
+// that the proxy listens on, and the selector that determines which pods will answer requests sent through the proxy.
+type Service struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       ServiceSpec `json:"spec,omitempty"`
+}
+
+// ServiceSpec describes the attributes that a user creates on a service.
+type ServiceSpec struct {
+	// Type determines how the Service is exposed.
+	Type  string        `json:"type,omitempty"`
+	Ports []ServicePort `json:"ports,omitempty"`
+}
+
+// ServicePort contains information on service's port.
+type ServicePort struct {
+	Port     int32 `json:"port"`
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// Job represents the configuration of a single job.
+type Job struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+}
+
+// The API group and version of the PodDisruptionBudgets read from the cluster.
+// policy/v1 is not served by the Kubernetes versions supported by Kismatic.
+const (
+	podDisruptionBudgetGroup   = "policy"
+	podDisruptionBudgetVersion = "v1beta1"
+)
+
+// PodDisruptionBudgetList is a collection of PodDisruptionBudgets.
+type PodDisruptionBudgetList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []PodDisruptionBudget `json:"items"`
+}
+
+// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
+type PodDisruptionBudget struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       PodDisruptionBudgetSpec   `json:"spec,omitempty"`
+	Status     PodDisruptionBudgetStatus `json:"status,omitempty"`
+}
+
+// PodDisruptionBudgetSpec is a description of a PodDisruptionBudget.
+type PodDisruptionBudgetSpec struct {
+	// Label query over pods whose evictions are managed by the disruption budget.
+	Selector *LabelSelector `json:"selector,omitempty"`
+}
+
+// PodDisruptionBudgetStatus represents information about the status of a
+// PodDisruptionBudget. Status may trail the actual state of a system.
+type PodDisruptionBudgetStatus struct {
+	// Number of pod disruptions that are currently allowed.
+	DisruptionsAllowed int32 `json:"disruptionsAllowed"`
+	// current number of healthy pods
+	CurrentHealthy int32 `json:"currentHealthy"`
+	// minimum desired number of healthy pods
+	DesiredHealthy int32 `json:"desiredHealthy"`
+	// total number of pods counted by this disruption budget
+	ExpectedPods int32 `json:"expectedPods"`
+}
+
+// LabelSelector is a label query over a set of resources. The result of matchLabels and
+// matchExpressions are ANDed. An empty label selector matches all objects. A null
+// label selector matches no objects.
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement is a selector that contains values, a key, and an operator that
+// relates the key and values.
+type LabelSelectorRequirement struct {
+	Key string `json:"key"`
+	// Operator represents a key's relationship to a set of values.
+	// Valid operators are In, NotIn, Exists and DoesNotExist.
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Matches returns true if the given labels satisfy the selector
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	if s == nil {
+		return false
+	}
+	for k, v := range s.MatchLabels {
+		if actual, ok := labels[k]; !ok || actual != v {
+			return false
+		}
+	}
+	for _, req := range s.MatchExpressions {
+		actual, exists := labels[req.Key]
+		switch req.Operator {
+		case "In":
+			if !exists || !containsString(req.Values, actual) {
+				return false
+			}
+		case "NotIn":
+			if exists && containsString(req.Values, actual) {
+				return false
+			}
+		case "Exists":
+			if !exists {
+				return false
+			}
+		case "DoesNotExist":
+			if exists {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeList is the whole list of all Nodes which have been registered with master.
+type NodeList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []Node `json:"items"`
+}
+
+// Node is a worker node in Kubernetes.
+type Node struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       NodeSpec   `json:"spec,omitempty"`
+	Status     NodeStatus `json:"status,omitempty"`
+}
+
+// NodeSpec describes the attributes that a node is created with.
+type NodeSpec struct {
+	// Unschedulable controls node schedulability of new pods.
+	Unschedulable bool `json:"unschedulable,omitempty"`
+	// If specified, the node's taints.
+	Taints []Taint `json:"taints,omitempty"`
+}
+
+// Taint is attached to a node, and repels the pods that do not tolerate it.
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// NodeStatus is information about the current status of a node.
+type NodeStatus struct {
+	// Conditions is an array of current observed node conditions.
+	Conditions []NodeCondition `json:"conditions,omitempty"`
+	// NodeInfo is the set of ids/uuids to uniquely identify the node.
+	NodeInfo NodeSystemInfo `json:"nodeInfo,omitempty"`
+}
+
+// NodeCondition contains condition information for a node.
+type NodeCondition struct {
+	// Type of node condition.
+	Type string `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status string `json:"status"`
+	// Human readable message indicating details about last transition.
+	Message string `json:"message,omitempty"`
+}
+
+// NodeSystemInfo is a set of ids/uuids to uniquely identify the node.
+type NodeSystemInfo struct {
+	// Kubelet Version reported by the node.
+	KubeletVersion string `json:"kubeletVersion"`
+}
+
+// Ready returns true if the node is reporting the Ready condition
+func (n Node) Ready() bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
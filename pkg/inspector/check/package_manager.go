@@ -13,6 +13,7 @@ import (
 type PackageManager interface {
 	IsAvailable(PackageQuery) (bool, error)
 	IsInstalled(PackageQuery) (bool, error)
+	ListInstalled() ([]PackageQuery, error)
 }
 
 // NewPackageManager returns a package manager for the given distribution
@@ -45,6 +46,9 @@ func (noopManager) IsAvailable(PackageQuery) (bool, error) {
 func (noopManager) IsInstalled(PackageQuery) (bool, error) {
 	return false, fmt.Errorf("unable to determine if package is installed using noop pkg manager")
 }
+func (noopManager) ListInstalled() ([]PackageQuery, error) {
+	return nil, fmt.Errorf("unable to list installed packages using noop pkg manager")
+}
 func (noopManager) Enforced() bool {
 	return false
 }
@@ -76,6 +80,24 @@ func (m rpmManager) IsInstalled(p PackageQuery) (bool, error) {
 	return m.isPackageListed(p, out), nil
 }
 
+// ListInstalled returns the name and version-release of every installed package
+func (m rpmManager) ListInstalled() ([]PackageQuery, error) {
+	out, err := m.run("rpm", "-qa", "--qf", "%{NAME} %{VERSION}-%{RELEASE}\n")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list installed packages: %v", err)
+	}
+	pkgs := []PackageQuery{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 2 {
+			continue
+		}
+		pkgs = append(pkgs, PackageQuery{Name: f[0], Version: f[1]})
+	}
+	return pkgs, nil
+}
+
 func (m rpmManager) isPackageListed(p PackageQuery, list []byte) bool {
 	s := bufio.NewScanner(bytes.NewReader(list))
 
@@ -123,6 +145,25 @@ func (m debManager) IsAvailable(p PackageQuery) (bool, error) {
 	return true, nil
 }
 
+// ListInstalled returns the name and version of every installed package
+func (m debManager) ListInstalled() ([]PackageQuery, error) {
+	out, err := m.run("dpkg-query", "-W", "-f", "${db:Status-Abbrev} ${Package} ${Version}\n")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list installed packages: %v", err)
+	}
+	pkgs := []PackageQuery{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		// Only list packages in the "ii" state, which are fully installed
+		if len(f) != 3 || f[0] != "ii" {
+			continue
+		}
+		pkgs = append(pkgs, PackageQuery{Name: f[1], Version: f[2]})
+	}
+	return pkgs, nil
+}
+
 func (m debManager) isPackageListed(p PackageQuery) (bool, error) {
 	out, err := m.run("dpkg", "-l", p.Name)
 	if err != nil && strings.Contains(string(out), "no packages found matching") {
@@ -3,6 +3,7 @@ package check
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -13,6 +14,8 @@ type runMock struct {
 	yumErr    error
 	dpkgOut   string
 	dpkgErr   error
+	rpmOut    string
+	dpkgQOut  string
 }
 
 func (m runMock) run(cmd string, args ...string) ([]byte, error) {
@@ -25,6 +28,10 @@ func (m runMock) run(cmd string, args ...string) ([]byte, error) {
 		return []byte(m.yumOut), m.yumErr
 	case "dpkg":
 		return []byte(m.dpkgOut), m.dpkgErr
+	case "rpm":
+		return []byte(m.rpmOut), nil
+	case "dpkg-query":
+		return []byte(m.dpkgQOut), nil
 	}
 }
 
@@ -277,3 +284,39 @@ func TestDebPackageManagerExecError2(t *testing.T) {
 		t.Error("expected an error, but didn't get one")
 	}
 }
+
+func TestRPMPackageManagerListInstalled(t *testing.T) {
+	mock := runMock{
+		rpmOut: `kernel 3.10.0-862.el7
+kubelet 1.10.5-0
+garbage
+`,
+	}
+	m := rpmManager{run: mock.run}
+	pkgs, err := m.ListInstalled()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []PackageQuery{{"kernel", "3.10.0-862.el7"}, {"kubelet", "1.10.5-0"}}
+	if !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("expected %v, but got %v", expected, pkgs)
+	}
+}
+
+func TestDebPackageManagerListInstalled(t *testing.T) {
+	mock := runMock{
+		dpkgQOut: `ii  kubelet 1.10.5-00
+rc  linux-image-4.4.0-21-generic 4.4.0-21.37
+ii  linux-image-4.4.0-130-generic 4.4.0-130.156
+`,
+	}
+	m := debManager{run: mock.run}
+	pkgs, err := m.ListInstalled()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []PackageQuery{{"kubelet", "1.10.5-00"}, {"linux-image-4.4.0-130-generic", "4.4.0-130.156"}}
+	if !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("expected %v, but got %v", expected, pkgs)
+	}
+}
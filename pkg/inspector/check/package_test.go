@@ -15,6 +15,10 @@ func (m stubPkgManager) IsAvailable(q PackageQuery) (bool, error) {
 	return m.available, nil
 }
 
+func (m stubPkgManager) ListInstalled() ([]PackageQuery, error) {
+	return nil, nil
+}
+
 func TestPackageCheck(t *testing.T) {
 	tests := []struct {
 		packageName                string
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/inspector/check"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdPackages returns the "packages" command
+func NewCmdPackages(out io.Writer) *cobra.Command {
+	var outputType string
+	cmd := &cobra.Command{
+		Use:   "packages",
+		Short: "List the packages installed on the local host",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputType(outputType); err != nil {
+				return err
+			}
+			distro, err := check.DetectDistro()
+			if err != nil {
+				return fmt.Errorf("error listing packages: %v", err)
+			}
+			pkgMgr, err := check.NewPackageManager(distro)
+			if err != nil {
+				return err
+			}
+			pkgs, err := pkgMgr.ListInstalled()
+			if err != nil {
+				return err
+			}
+			return printPackages(out, pkgs, outputType)
+		},
+	}
+	cmd.Flags().StringVarP(&outputType, "output", "o", "table", "set the result output type. Options are 'json', 'table'")
+	return cmd
+}
+
+func printPackages(out io.Writer, pkgs []check.PackageQuery, outputType string) error {
+	if outputType == "json" {
+		if err := json.NewEncoder(out).Encode(pkgs); err != nil {
+			return fmt.Errorf("error marshaling packages as JSON: %v", err)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 1, 8, 4, '\t', 0)
+	fmt.Fprintf(w, "PACKAGE\tVERSION\n")
+	for _, p := range pkgs {
+		fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Version)
+	}
+	w.Flush()
+	return nil
+}
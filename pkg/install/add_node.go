@@ -8,15 +8,17 @@ import (
 )
 
 var errMissingClusterCA = errors.New("The Certificate Authority's private key and certificate used to install " +
-	"the cluster are required for adding worker nodes.")
+	"the cluster are required for adding nodes.")
 
-// AddNode adds a worker node to the original cluster described in the plan.
+// AddNode adds a node to the original cluster described in the plan.
 // If successful, the updated plan is returned.
 func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []string, restartServices bool) (*Plan, error) {
 	if err := checkAddNodePrereqs(ae.pki, newNode); err != nil {
 		return nil, err
 	}
 	updatedPlan := AddNodeToPlan(*originalPlan, newNode, roles)
+	addEtcd := util.Contains("etcd", roles)
+	addMaster := util.Contains("master", roles)
 
 	// Generate node certificates
 	util.PrintHeader(ae.stdout, "Generating Certificate For New Node", '=')
@@ -27,6 +29,19 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 	if err = ae.pki.GenerateNodeCertificate(&updatedPlan, newNode, ca); err != nil {
 		return nil, fmt.Errorf("error generating certificate for new node: %v", err)
 	}
+	// The API server certificates include the load balanced address of the
+	// master nodes, which might have changed in the plan file
+	var certsRegenerated bool
+	if addMaster {
+		util.PrintHeader(ae.stdout, "Regenerating Certificates For Master Nodes", '=')
+		for _, n := range originalPlan.Master.Nodes {
+			regenerated, err := ae.pki.RegenerateNodeCertificate(&updatedPlan, n, ca)
+			if err != nil {
+				return nil, fmt.Errorf("error regenerating certificate for master node %q: %v", n.Host, err)
+			}
+			certsRegenerated = certsRegenerated || regenerated
+		}
+	}
 
 	// Run the playbook to add the node
 	inventory := buildInventoryFromPlan(&updatedPlan)
@@ -54,10 +69,20 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 	if restartServices {
 		cc.EnableRestart()
 	}
+	// etcd and master nodes are set up using the same playbook as the installation.
+	// A new etcd node joins the existing etcd clusters as a new member. The playbook
+	// is limited to the new node, so the units of the existing members are left as they
+	// are. They are updated one member at a time by the next installation run.
+	playbook := "kubernetes-node.yaml"
+	if addEtcd || addMaster {
+		playbook = "kubernetes.yaml"
+	}
+	cc.NewNode = newNode.Host
+	cc.EtcdMemberAdd = addEtcd
 	util.PrintHeader(ae.stdout, "Adding New Node to Cluster", '=')
 	t := task{
 		name:           "add-node",
-		playbook:       "kubernetes-node.yaml",
+		playbook:       playbook,
 		plan:           updatedPlan,
 		inventory:      inventory,
 		clusterCatalog: *cc,
@@ -68,9 +93,23 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 		return nil, fmt.Errorf("error running playbook: %v", err)
 	}
 
+	// The API servers need to know about the new etcd member, and the
+	// kubeconfig files about a new load balanced address
+	if addEtcd || addMaster {
+		var kubeconfigChanged bool
+		if addMaster {
+			kubeconfigChanged, err = RegenerateKubeconfig(&updatedPlan, ae.options.GeneratedAssetsDirectory)
+			if err != nil {
+				return nil, fmt.Errorf("error generating kubeconfig file: %v", err)
+			}
+		}
+		if err = ae.reconfigureControlPlane(updatedPlan, certsRegenerated, kubeconfigChanged); err != nil {
+			return nil, err
+		}
+	}
+
 	// Verify that the node registered with API server
 	util.PrintHeader(ae.stdout, "Running New Node Smoke Test", '=')
-	cc.NewNode = newNode.Host
 	t = task{
 		name:           "add-node-smoke-test",
 		playbook:       "_node-smoke-test.yaml",
@@ -85,7 +124,7 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 	}
 
 	// Allow access to new node to any storage volumes defined
-	if len(originalPlan.Storage.Nodes) > 0 {
+	if len(originalPlan.Storage.Nodes) > 0 && containsAny([]string{"master", "worker", "ingress", "storage"}, roles) {
 		util.PrintHeader(ae.stdout, "Updating Allowed IPs On Storage Volumes", '=')
 		t = task{
 			name:           "add-node-update-volumes",
@@ -102,7 +141,40 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 	return &updatedPlan, nil
 }
 
+// reconfigureControlPlane rolls out the etcd members and certificates of the plan
+// to the API servers, one master node at a time. When the kubeconfig has changed,
+// the kubeconfig files are updated on all nodes.
+func (ae *ansibleExecutor) reconfigureControlPlane(plan Plan, restartAPIServers bool, updateKubeconfig bool) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return fmt.Errorf("failed to generate ansible vars: %v", err)
+	}
+	cc.ForceAPIServerRestart = restartAPIServers
+	cc.UpdateKubeconfig = updateKubeconfig
+	util.PrintHeader(ae.stdout, "Reconfiguring Control Plane", '=')
+	t := task{
+		name:           "reconfigure-control-plane",
+		playbook:       "reconfigure-control-plane.yaml",
+		plan:           plan,
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
+	}
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error reconfiguring control plane: %v", err)
+	}
+	return nil
+}
+
 func AddNodeToPlan(plan Plan, node Node, roles []string) Plan {
+	if util.Contains("etcd", roles) {
+		plan.Etcd.ExpectedCount++
+		plan.Etcd.Nodes = append(plan.Etcd.Nodes, node)
+	}
+	if util.Contains("master", roles) {
+		plan.Master.ExpectedCount++
+		plan.Master.Nodes = append(plan.Master.Nodes, node)
+	}
 	if util.Contains("worker", roles) {
 		plan.Worker.ExpectedCount++
 		plan.Worker.Nodes = append(plan.Worker.Nodes, node)
@@ -497,6 +497,59 @@ func TestAddNodeHostsFilesDNSEnabled(t *testing.T) {
 	}
 }
 
+func TestAddEtcdNodeReconfiguresControlPlane(t *testing.T) {
+	fakeRunner := fakeRunner{}
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t)},
+		stdout:              ioutil.Discard,
+		consoleOutputFormat: ansible.RawFormat,
+		pki: &fakePKI{
+			caExists: true,
+		},
+		runnerExplainerFactory: func(explain.AnsibleEventExplainer, io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return &fakeRunner, &explain.AnsibleEventStreamExplainer{}, nil
+		},
+		certsDir: mustGetTempDir(t),
+	}
+	originalPlan := &Plan{
+		Etcd: NodeGroup{
+			ExpectedCount: 1,
+			Nodes:         []Node{{Host: "etcd01"}},
+		},
+		Master: MasterNodeGroup{
+			Nodes: []Node{{InternalIP: "10.10.2.20"}},
+		},
+		Cluster: Cluster{
+			Version: "v1.10.5",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+	}
+	newNode := Node{
+		Host: "etcd02",
+	}
+	updatedPlan, err := e.AddNode(originalPlan, newNode, []string{"etcd"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error while adding etcd node: %v", err)
+	}
+	if updatedPlan.Etcd.ExpectedCount != 2 || len(updatedPlan.Etcd.Nodes) != 2 {
+		t.Errorf("the updated plan does not include the new etcd node")
+	}
+	if !fakeRunner.incomingCatalog.EtcdMemberAdd || fakeRunner.incomingCatalog.NewNode != "etcd02" {
+		t.Errorf("the new node was not added as an etcd member")
+	}
+	found := false
+	for _, p := range fakeRunner.allNodesPlaybooks {
+		if p == "reconfigure-control-plane.yaml" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the control plane was not reconfigured. The following plays ran: %v", fakeRunner.allNodesPlaybooks)
+	}
+}
+
 //// Fakes for testing
 type fakePKI struct {
 	caExists                    bool
@@ -509,10 +562,14 @@ type fakePKI struct {
 
 func (f *fakePKI) CertificateAuthorityExists() (bool, error)     { return f.caExists, f.err }
 func (f *fakePKI) NodeCertificateExists(node Node) (bool, error) { return f.nodeCertExists, f.err }
+func (f *fakePKI) RemoveNodeCertificates(node Node) error        { return f.err }
 func (f *fakePKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) error {
 	f.generateNodeCertCalled = true
 	return f.err
 }
+func (f *fakePKI) RegenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) (bool, error) {
+	return false, f.err
+}
 func (f *fakePKI) GetClusterCA() (*tls.CA, error) { return nil, f.err }
 func (f *fakePKI) GenerateClusterCA(p *Plan) (*tls.CA, error) {
 	f.generateCACalled = true
@@ -526,9 +583,13 @@ func (f *fakePKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 func (f *fakePKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, proxyClientCA *tls.CA) error {
 	return f.err
 }
-func (f *fakePKI) GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
+func (f *fakePKI) GenerateCertificate(name string, certs CertsConfig, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
 	return false, f.err
 }
+func (f *fakePKI) RotateCertificates(p *Plan, components []string, clusterCA *tls.CA, proxyClientCA *tls.CA) ([]string, error) {
+	return nil, f.err
+}
+func (f *fakePKI) PrepareCARotationPhase(p *Plan, phase string) error { return f.err }
 
 type fakeRunner struct {
 	eventChan         chan ansible.Event
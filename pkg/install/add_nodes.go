@@ -0,0 +1,305 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// NewNode is a node that is to be added to the cluster, along with its roles
+type NewNode struct {
+	Node  `yaml:",inline"`
+	Roles []string
+}
+
+// NewNodesFile is the file that describes the nodes to be added to the cluster
+type NewNodesFile struct {
+	Nodes []NewNode
+}
+
+// AddNodeResult is the outcome of adding a node to the cluster.
+// If the node could not be added, Err is set.
+type AddNodeResult struct {
+	Node  Node
+	Roles []string
+	Err   error
+}
+
+// roles that can be added in bulk. etcd members must be added one at a time,
+// and the control plane must be reconfigured after adding a master node.
+var bulkAddNodeRoles = []string{"worker", "ingress", "storage"}
+
+// ReadNewNodesFile reads the nodes to be added to the cluster from the file.
+// Nodes without roles are added as workers.
+func ReadNewNodesFile(file string) ([]NewNode, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading nodes file: %v", err)
+	}
+	nf := NewNodesFile{}
+	if err = yaml.Unmarshal(b, &nf); err != nil {
+		return nil, fmt.Errorf("error unmarshalling nodes file: %v", err)
+	}
+	if len(nf.Nodes) == 0 {
+		return nil, fmt.Errorf("no nodes were found in the nodes file %q", file)
+	}
+	for i := range nf.Nodes {
+		if len(nf.Nodes[i].Roles) == 0 {
+			nf.Nodes[i].Roles = []string{"worker"}
+		}
+	}
+	return nf.Nodes, nil
+}
+
+// ValidateNewNodes validates the nodes that are to be added to the cluster
+// in a single run. The nodes must be valid, unique, and only have roles that
+// can be added in bulk.
+func ValidateNewNodes(nodes []NewNode) (bool, []error) {
+	errs := []error{}
+	list := []Node{}
+	for _, n := range nodes {
+		if _, nodeErrs := ValidateNode(&n.Node); nodeErrs != nil {
+			for _, err := range nodeErrs {
+				errs = append(errs, fmt.Errorf("node %q: %v", n.Host, err))
+			}
+		}
+		for _, r := range n.Roles {
+			if !util.Contains(r, bulkAddNodeRoles) {
+				errs = append(errs, fmt.Errorf("node %q: role %q cannot be added from a file, options %v", n.Host, r, bulkAddNodeRoles))
+			}
+		}
+		list = append(list, n.Node)
+	}
+	if _, listErrs := ValidateNodes(list); listErrs != nil {
+		errs = append(errs, listErrs...)
+	}
+	if len(errs) > 0 {
+		return false, errs
+	}
+	return true, nil
+}
+
+// AddNodesToPlan returns a copy of the plan that includes the new nodes
+func AddNodesToPlan(plan Plan, nodes []NewNode) Plan {
+	for _, n := range nodes {
+		plan = AddNodeToPlan(plan, n.Node, n.Roles)
+	}
+	return plan
+}
+
+// RunNewNodesPreFlightCheck runs the preflight checks against the new nodes.
+// The checks run on all nodes in parallel, in a single ansible run. If the checks
+// fail, the error is returned along with the hosts that failed them.
+func (ae *ansibleExecutor) RunNewNodesPreFlightCheck(p Plan, nodes []NewNode) ([]string, error) {
+	p = AddNodesToPlan(p, nodes)
+	cc, err := ae.buildClusterCatalog(&p)
+	if err != nil {
+		return nil, err
+	}
+	inventory := buildInventoryFromPlan(&p)
+	limit := newNodeHosts(nodes)
+	t := task{
+		name:           "copy-inspector",
+		playbook:       "copy-inspector.yaml",
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		explainer:      ae.preflightExplainer(),
+		plan:           p,
+		limit:          limit,
+	}
+	if err = ae.execute(t); err != nil {
+		return nil, err
+	}
+	recorder := &hostFailureRecorder{explainer: ae.preflightExplainer()}
+	t = task{
+		name:           "add-nodes-preflight",
+		playbook:       "preflight.yaml",
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		explainer:      recorder,
+		plan:           p,
+		limit:          limit,
+	}
+	if err = ae.execute(t); err != nil {
+		return recorder.failedHosts(), err
+	}
+	return nil, nil
+}
+
+// AddNodes adds the nodes to the original cluster described in the plan, in a
+// single ansible run limited to the new nodes. The result of adding each node is
+// returned, along with the plan updated to include the nodes that were added.
+// An error is returned if no node could be added.
+func (ae *ansibleExecutor) AddNodes(originalPlan *Plan, nodes []NewNode, restartServices bool) (*Plan, []AddNodeResult, error) {
+	for _, n := range nodes {
+		if err := checkAddNodePrereqs(ae.pki, n.Node); err != nil {
+			return nil, nil, err
+		}
+	}
+	updatedPlan := AddNodesToPlan(*originalPlan, nodes)
+
+	// Generate node certificates
+	util.PrintHeader(ae.stdout, "Generating Certificates For New Nodes", '=')
+	ca, err := ae.pki.GetClusterCA()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, n := range nodes {
+		if err = ae.pki.GenerateNodeCertificate(&updatedPlan, n.Node, ca); err != nil {
+			return nil, nil, fmt.Errorf("error generating certificate for new node %q: %v", n.Host, err)
+		}
+	}
+
+	inventory := buildInventoryFromPlan(&updatedPlan)
+	cc, err := ae.buildClusterCatalog(&updatedPlan)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ansible vars: %v", err)
+	}
+
+	// We need to run ansible against all hosts to update the hosts files
+	if updatedPlan.Cluster.Networking.UpdateHostsFiles {
+		util.PrintHeader(ae.stdout, "Updating Hosts Files On All Nodes", '=')
+		t := task{
+			name:           "add-nodes-update-hosts",
+			playbook:       "hosts.yaml",
+			plan:           updatedPlan,
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			explainer:      ae.defaultExplainer(),
+		}
+		if err = ae.execute(t); err != nil {
+			return nil, nil, fmt.Errorf("error updating hosts files on all nodes: %v", err)
+		}
+	}
+
+	if restartServices {
+		cc.EnableRestart()
+	}
+	util.PrintHeader(ae.stdout, "Adding New Nodes to Cluster", '=')
+	recorder := &hostFailureRecorder{explainer: ae.defaultExplainer()}
+	t := task{
+		name:           "add-nodes",
+		playbook:       "kubernetes-node.yaml",
+		plan:           updatedPlan,
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		explainer:      recorder,
+		limit:          newNodeHosts(nodes),
+	}
+	if err = ae.execute(t); err != nil {
+		// The playbook stops on all nodes when it fails on any of them
+		results := []AddNodeResult{}
+		failed := recorder.failedHosts()
+		for _, n := range nodes {
+			res := AddNodeResult{Node: n.Node, Roles: n.Roles, Err: fmt.Errorf("stopped because adding another node failed")}
+			if util.Contains(n.Host, failed) {
+				res.Err = err
+			}
+			results = append(results, res)
+		}
+		return nil, results, fmt.Errorf("error running playbook: %v", err)
+	}
+
+	// Verify that each node registered with API server
+	util.PrintHeader(ae.stdout, "Running New Node Smoke Tests", '=')
+	results := []AddNodeResult{}
+	added := []NewNode{}
+	for _, n := range nodes {
+		cc.NewNode = n.Host
+		t = task{
+			name:           "add-nodes-smoke-test",
+			playbook:       "_node-smoke-test.yaml",
+			plan:           updatedPlan,
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			explainer:      ae.defaultExplainer(),
+			limit:          []string{n.Host},
+		}
+		res := AddNodeResult{Node: n.Node, Roles: n.Roles}
+		if err = ae.execute(t); err != nil {
+			res.Err = fmt.Errorf("error running node smoke test: %v", err)
+		} else {
+			added = append(added, n)
+		}
+		results = append(results, res)
+	}
+	if len(added) == 0 {
+		return nil, results, fmt.Errorf("none of the new nodes passed the smoke test")
+	}
+
+	// Only the nodes that were added successfully are kept in the plan
+	updatedPlan = AddNodesToPlan(*originalPlan, added)
+	inventory = buildInventoryFromPlan(&updatedPlan)
+
+	// Allow access to new nodes to any storage volumes defined
+	if len(originalPlan.Storage.Nodes) > 0 {
+		util.PrintHeader(ae.stdout, "Updating Allowed IPs On Storage Volumes", '=')
+		for _, n := range added {
+			cc.NewNode = n.Host
+			t = task{
+				name:           "add-nodes-update-volumes",
+				playbook:       "_volume-update-allowed.yaml",
+				plan:           updatedPlan,
+				inventory:      inventory,
+				clusterCatalog: *cc,
+				explainer:      ae.defaultExplainer(),
+			}
+			if err = ae.execute(t); err != nil {
+				return &updatedPlan, results, fmt.Errorf("error adding new node %q to volume allow list: %v", n.Host, err)
+			}
+		}
+	}
+	return &updatedPlan, results, nil
+}
+
+func newNodeHosts(nodes []NewNode) []string {
+	hosts := []string{}
+	for _, n := range nodes {
+		hosts = append(hosts, n.Host)
+	}
+	return hosts
+}
+
+// hostFailureRecorder explains the events using the underlying explainer,
+// and records the hosts on which a task failed or that were unreachable.
+type hostFailureRecorder struct {
+	explainer explain.AnsibleEventExplainer
+	mu        sync.Mutex
+	failed    []string
+}
+
+func (r *hostFailureRecorder) ExplainEvent(e ansible.Event) {
+	r.explainer.ExplainEvent(e)
+	var host string
+	switch event := e.(type) {
+	case *ansible.RunnerFailedEvent:
+		if !event.IgnoreErrors {
+			host = event.Host
+		}
+	case *ansible.RunnerItemFailedEvent:
+		if !event.IgnoreErrors {
+			host = event.Host
+		}
+	case *ansible.RunnerUnreachableEvent:
+		host = event.Host
+	}
+	if host == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !util.Contains(host, r.failed) {
+		r.failed = append(r.failed, host)
+	}
+}
+
+func (r *hostFailureRecorder) failedHosts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.failed...)
+}
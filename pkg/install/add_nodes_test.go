@@ -0,0 +1,194 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+const testNewNodesFile = `nodes:
+- host: worker05
+  ip: 10.0.0.5
+  internalip: 192.168.0.5
+  roles: [worker, ingress]
+  labels:
+    team: web
+  taints:
+  - key: dedicated
+    value: web
+    effect: NoSchedule
+- host: worker06
+  ip: 10.0.0.6
+`
+
+func TestReadNewNodesFile(t *testing.T) {
+	dir := mustGetTempDir(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "nodes.yaml")
+	if err := ioutil.WriteFile(file, []byte(testNewNodesFile), 0644); err != nil {
+		t.Fatalf("error writing nodes file: %v", err)
+	}
+	nodes, err := ReadNewNodesFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error reading nodes file: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, but got %d", len(nodes))
+	}
+	n := nodes[0]
+	if n.Host != "worker05" || n.IP != "10.0.0.5" || n.InternalIP != "192.168.0.5" {
+		t.Errorf("unexpected node %+v", n.Node)
+	}
+	if len(n.Roles) != 2 || n.Roles[0] != "worker" || n.Roles[1] != "ingress" {
+		t.Errorf("expected the worker and ingress roles, but got %v", n.Roles)
+	}
+	if n.Labels["team"] != "web" {
+		t.Errorf("expected the team label, but got %v", n.Labels)
+	}
+	if len(n.Taints) != 1 || n.Taints[0] != (Taint{Key: "dedicated", Value: "web", Effect: "NoSchedule"}) {
+		t.Errorf("expected the dedicated taint, but got %v", n.Taints)
+	}
+	if len(nodes[1].Roles) != 1 || nodes[1].Roles[0] != "worker" {
+		t.Errorf("expected the node without roles to be a worker, but got %v", nodes[1].Roles)
+	}
+}
+
+func TestValidateNewNodes(t *testing.T) {
+	valid := []NewNode{
+		{Node: Node{Host: "worker05", IP: "10.0.0.5"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "worker06", IP: "10.0.0.6"}, Roles: []string{"ingress", "storage"}},
+	}
+	if ok, errs := ValidateNewNodes(valid); !ok || errs != nil {
+		t.Errorf("expected the nodes to be valid without errors, but got %v", errs)
+	}
+	tests := [][]NewNode{
+		// etcd members are added one at a time
+		{{Node: Node{Host: "etcd04", IP: "10.0.0.5"}, Roles: []string{"etcd"}}},
+		// duplicate IP
+		{
+			{Node: Node{Host: "worker05", IP: "10.0.0.5"}, Roles: []string{"worker"}},
+			{Node: Node{Host: "worker06", IP: "10.0.0.5"}, Roles: []string{"worker"}},
+		},
+		// missing IP
+		{{Node: Node{Host: "worker05"}, Roles: []string{"worker"}}},
+	}
+	for i, nodes := range tests {
+		if ok, errs := ValidateNewNodes(nodes); ok || len(errs) == 0 {
+			t.Errorf("test %d: expected the nodes to be invalid with errors", i)
+		}
+	}
+}
+
+func addNodesTestPlan() *Plan {
+	return &Plan{
+		Master: MasterNodeGroup{
+			Nodes: []Node{{InternalIP: "10.10.2.20"}},
+		},
+		Worker: NodeGroup{
+			ExpectedCount: 1,
+			Nodes:         []Node{{Host: "existingWorker"}},
+		},
+		Cluster: Cluster{
+			Version: "v1.10.5",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+	}
+}
+
+func TestAddNodesPlanIsUpdated(t *testing.T) {
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t)},
+		stdout:              ioutil.Discard,
+		consoleOutputFormat: ansible.RawFormat,
+		pki: &fakePKI{
+			caExists: true,
+		},
+		runnerExplainerFactory: fakeRunnerExplainer(nil),
+		certsDir:               mustGetTempDir(t),
+	}
+	originalPlan := addNodesTestPlan()
+	nodes := []NewNode{
+		{Node: Node{Host: "worker05"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "worker06"}, Roles: []string{"worker", "ingress"}},
+	}
+	updatedPlan, results, err := e.AddNodes(originalPlan, nodes, false)
+	if err != nil {
+		t.Fatalf("unexpected error while adding nodes: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, but got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("unexpected error adding node %q: %v", r.Node.Host, r.Err)
+		}
+	}
+	if updatedPlan.Worker.ExpectedCount != 3 || len(updatedPlan.Worker.Nodes) != 3 {
+		t.Errorf("expected 3 workers, but got %+v", updatedPlan.Worker)
+	}
+	if updatedPlan.Ingress.ExpectedCount != 1 || len(updatedPlan.Ingress.Nodes) != 1 {
+		t.Errorf("expected 1 ingress node, but got %+v", updatedPlan.Ingress)
+	}
+	if len(originalPlan.Worker.Nodes) != 1 {
+		t.Errorf("the original plan was modified")
+	}
+}
+
+func TestAddNodesPlanNotUpdatedAfterFailure(t *testing.T) {
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t)},
+		stdout:              ioutil.Discard,
+		consoleOutputFormat: ansible.RawFormat,
+		pki: &fakePKI{
+			caExists: true,
+		},
+		runnerExplainerFactory: fakeRunnerExplainer(errors.New("exec error")),
+		certsDir:               mustGetTempDir(t),
+	}
+	nodes := []NewNode{
+		{Node: Node{Host: "worker05"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "worker06"}, Roles: []string{"worker"}},
+	}
+	updatedPlan, results, err := e.AddNodes(addNodesTestPlan(), nodes, false)
+	if err == nil {
+		t.Errorf("expected an error, but didn't get one")
+	}
+	if updatedPlan != nil {
+		t.Error("plan was updated, even though adding the nodes failed")
+	}
+	if len(results) != 2 || results[0].Err == nil || results[1].Err == nil {
+		t.Errorf("expected all nodes to have failed, but got %+v", results)
+	}
+}
+
+func TestHostFailureRecorder(t *testing.T) {
+	r := &hostFailureRecorder{explainer: &noopExplainer{}}
+	events := []ansible.Event{
+		&ansible.RunnerOKEvent{},
+		&ansible.RunnerFailedEvent{},
+		&ansible.RunnerUnreachableEvent{},
+	}
+	events[1].(*ansible.RunnerFailedEvent).Host = "worker05"
+	events[2].(*ansible.RunnerUnreachableEvent).Host = "worker06"
+	ignored := &ansible.RunnerFailedEvent{}
+	ignored.Host = "worker07"
+	ignored.IgnoreErrors = true
+	events = append(events, ignored, events[1])
+	for _, e := range events {
+		r.ExplainEvent(e)
+	}
+	failed := r.failedHosts()
+	if len(failed) != 2 || failed[0] != "worker05" || failed[1] != "worker06" {
+		t.Errorf("expected worker05 and worker06 to have failed, but got %v", failed)
+	}
+}
+
+type noopExplainer struct{}
+
+func (noopExplainer) ExplainEvent(ansible.Event) {}
@@ -0,0 +1,240 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+// The add-ons that can be applied independently of an installation
+const (
+	AddOnDNS           = "dns"
+	AddOnCNI           = "cni"
+	AddOnHeapster      = "heapster"
+	AddOnMetricsServer = "metrics-server"
+	AddOnDashboard     = "dashboard"
+	AddOnHelm          = "helm"
+	AddOnRescheduler   = "rescheduler"
+	AddOnIngress       = "ingress"
+)
+
+// AddOnNames returns the names of the add-ons, in the order in which they are deployed
+func AddOnNames() []string {
+	return []string{AddOnCNI, AddOnRescheduler, AddOnDNS, AddOnHeapster, AddOnMetricsServer, AddOnDashboard, AddOnHelm, AddOnIngress}
+}
+
+// AddOnStatus is the state of an add-on as seen by the cluster, compared to the plan
+type AddOnStatus struct {
+	Name string `json:"name"`
+	// Enabled is true when the add-on is enabled in the plan
+	Enabled bool `json:"enabled"`
+	// Deployed is true when the add-on was found on the cluster
+	Deployed bool `json:"deployed"`
+	// Version is the tag of the image that is deployed
+	Version string `json:"version,omitempty"`
+	// Drift contains the differences between the plan and the cluster
+	Drift []string `json:"drift,omitempty"`
+	// Note is set when the add-on is not managed by Kismatic
+	Note string `json:"note,omitempty"`
+}
+
+type addOnStatusClient interface {
+	data.DeploymentGetter
+	data.DaemonSetGetter
+	data.ServiceGetter
+	data.PodLister
+}
+
+// ValidateAddOnNames returns an error if any of the names is not a known add-on,
+// or if the add-on is disabled in the plan.
+func ValidateAddOnNames(p Plan, names []string) error {
+	for _, n := range names {
+		enabled, known := addOnEnabled(p, n)
+		if !known {
+			return fmt.Errorf("unknown add-on %q, must be one of %s", n, strings.Join(AddOnNames(), ", "))
+		}
+		if !enabled {
+			return fmt.Errorf("add-on %q is disabled in the plan file", n)
+		}
+	}
+	return nil
+}
+
+// EnabledAddOns returns the names of the add-ons that are enabled in the plan
+func EnabledAddOns(p Plan) []string {
+	enabled := []string{}
+	for _, n := range AddOnNames() {
+		if e, _ := addOnEnabled(p, n); e {
+			enabled = append(enabled, n)
+		}
+	}
+	return enabled
+}
+
+func addOnEnabled(p Plan, name string) (enabled bool, known bool) {
+	switch name {
+	case AddOnDNS:
+		return !p.AddOns.DNS.Disable, true
+	case AddOnCNI:
+		return p.AddOns.CNI != nil && !p.AddOns.CNI.Disable, true
+	case AddOnHeapster:
+		return p.AddOns.HeapsterMonitoring != nil && !p.AddOns.HeapsterMonitoring.Disable, true
+	case AddOnMetricsServer:
+		return !p.AddOns.MetricsServer.Disable, true
+	case AddOnDashboard:
+		return !p.AddOns.Dashboard.Disable, true
+	case AddOnHelm:
+		return !p.AddOns.PackageManager.Disable, true
+	case AddOnRescheduler:
+		return !p.AddOns.Rescheduler.Disable, true
+	case AddOnIngress:
+		return len(p.Ingress.Nodes) > 0, true
+	}
+	return false, false
+}
+
+// GetAddOnStatuses returns the status of every add-on, comparing the
+// objects deployed on the cluster against the plan
+func GetAddOnStatuses(p Plan, client addOnStatusClient) []AddOnStatus {
+	statuses := []AddOnStatus{}
+	for _, n := range AddOnNames() {
+		s := AddOnStatus{Name: n}
+		s.Enabled, _ = addOnEnabled(p, n)
+		switch n {
+		case AddOnDNS:
+			name := "kube-dns"
+			if p.AddOns.DNS.Provider == dnsProviderCoredns {
+				name = "coredns"
+			}
+			deploymentStatus(&s, client, "kube-system", name, p.AddOns.DNS.Options.Replicas)
+		case AddOnCNI:
+			if p.AddOns.CNI == nil {
+				break
+			}
+			switch p.AddOns.CNI.Provider {
+			case cniProviderCalico:
+				daemonSetStatus(&s, client, "kube-system", "calico-node")
+			case cniProviderWeave:
+				daemonSetStatus(&s, client, "kube-system", "weave-net")
+			case cniProviderContiv:
+				daemonSetStatus(&s, client, "kube-system", "contiv-netplugin")
+			default:
+				s.Note = fmt.Sprintf("the %q CNI provider is not managed by Kismatic", p.AddOns.CNI.Provider)
+			}
+		case AddOnHeapster:
+			replicas := 0
+			serviceType := ""
+			if p.AddOns.HeapsterMonitoring != nil {
+				replicas = p.AddOns.HeapsterMonitoring.Options.Heapster.Replicas
+				serviceType = p.AddOns.HeapsterMonitoring.Options.Heapster.ServiceType
+			}
+			deploymentStatus(&s, client, "kube-system", "heapster", replicas)
+			serviceStatus(&s, client, "kube-system", "heapster", serviceType, "")
+		case AddOnMetricsServer:
+			deploymentStatus(&s, client, "kube-system", "metrics-server", 0)
+		case AddOnDashboard:
+			deploymentStatus(&s, client, "kubernetes-dashboard", "kubernetes-dashboard", 0)
+			serviceStatus(&s, client, "kubernetes-dashboard", "kubernetes-dashboard", p.AddOns.Dashboard.Options.ServiceType, p.AddOns.Dashboard.Options.NodePort)
+		case AddOnHelm:
+			namespace := p.AddOns.PackageManager.Options.Helm.Namespace
+			if namespace == "" {
+				namespace = "kube-system"
+			}
+			deploymentStatus(&s, client, namespace, "tiller-deploy", 0)
+		case AddOnRescheduler:
+			reschedulerStatus(&s, client)
+		case AddOnIngress:
+			daemonSetStatus(&s, client, "kube-system", "ingress")
+		}
+		if s.Enabled && !s.Deployed && s.Note == "" {
+			s.Drift = append(s.Drift, "enabled in the plan file, but not deployed")
+		}
+		if !s.Enabled && s.Deployed {
+			s.Drift = []string{"disabled in the plan file, but deployed"}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// deploymentStatus records the version of the deployment and whether the
+// number of replicas matches the plan. The replicas are not compared when
+// expectedReplicas is 0.
+func deploymentStatus(s *AddOnStatus, client data.DeploymentGetter, namespace, name string, expectedReplicas int) {
+	d, err := client.GetDeployment(namespace, name)
+	if err != nil || d == nil {
+		return
+	}
+	s.Deployed = true
+	s.Version = imageVersion(d.Spec.Template.Spec.Containers)
+	if expectedReplicas > 0 && d.Spec.Replicas != nil && int(*d.Spec.Replicas) != expectedReplicas {
+		s.Drift = append(s.Drift, fmt.Sprintf("%s has %d replicas, the plan file has %d", name, *d.Spec.Replicas, expectedReplicas))
+	}
+}
+
+func daemonSetStatus(s *AddOnStatus, client data.DaemonSetGetter, namespace, name string) {
+	ds, err := client.GetDaemonSet(namespace, name)
+	if err != nil || ds == nil {
+		return
+	}
+	s.Deployed = true
+	s.Version = imageVersion(ds.Spec.Template.Spec.Containers)
+	if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+		s.Drift = append(s.Drift, fmt.Sprintf("%s is ready on %d of %d nodes", name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled))
+	}
+}
+
+// serviceStatus records whether the type and node port of the service match the plan
+func serviceStatus(s *AddOnStatus, client data.ServiceGetter, namespace, name string, expectedType string, expectedNodePort string) {
+	if !s.Deployed || expectedType == "" {
+		return
+	}
+	svc, err := client.GetService(namespace, name)
+	if err != nil || svc == nil {
+		s.Drift = append(s.Drift, fmt.Sprintf("service %s was not found", name))
+		return
+	}
+	if svc.Spec.Type != expectedType {
+		s.Drift = append(s.Drift, fmt.Sprintf("service %s is of type %s, the plan file has %s", name, svc.Spec.Type, expectedType))
+		return
+	}
+	if expectedType == "NodePort" && expectedNodePort != "" {
+		for _, p := range svc.Spec.Ports {
+			if fmt.Sprintf("%d", p.NodePort) != expectedNodePort {
+				s.Drift = append(s.Drift, fmt.Sprintf("service %s uses node port %d, the plan file has %s", name, p.NodePort, expectedNodePort))
+			}
+		}
+	}
+}
+
+// The rescheduler runs as a static pod on the first master node
+func reschedulerStatus(s *AddOnStatus, client data.PodLister) {
+	pods, err := client.ListPods()
+	if err != nil || pods == nil {
+		return
+	}
+	for _, p := range pods.Items {
+		if p.Namespace == "kube-system" && p.Labels["k8s-app"] == "rescheduler" {
+			s.Deployed = true
+			s.Version = imageVersion(p.Spec.Containers)
+			return
+		}
+	}
+}
+
+// imageVersion returns the tag of the first container's image
+func imageVersion(containers []data.Container) string {
+	if len(containers) == 0 {
+		return ""
+	}
+	image := containers[0].Image
+	// Registries may include a port, so only look at the last path segment
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i >= 0 {
+		return image[i+1:]
+	}
+	return "latest"
+}
@@ -0,0 +1,120 @@
+package install
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeAddOnClient struct {
+	deployments map[string]*data.Deployment
+	daemonSets  map[string]*data.DaemonSet
+	services    map[string]*data.Service
+	pods        *data.PodList
+}
+
+func (f fakeAddOnClient) GetDeployment(namespace, name string) (*data.Deployment, error) {
+	if d, ok := f.deployments[namespace+"/"+name]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("Deployment %s/%s was not found", namespace, name)
+}
+
+func (f fakeAddOnClient) GetDaemonSet(namespace, name string) (*data.DaemonSet, error) {
+	if ds, ok := f.daemonSets[namespace+"/"+name]; ok {
+		return ds, nil
+	}
+	return nil, fmt.Errorf("DaemonSet %s/%s was not found", namespace, name)
+}
+
+func (f fakeAddOnClient) GetService(namespace, name string) (*data.Service, error) {
+	if s, ok := f.services[namespace+"/"+name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("Service %s/%s was not found", namespace, name)
+}
+
+func (f fakeAddOnClient) ListPods() (*data.PodList, error) {
+	return f.pods, nil
+}
+
+func deploymentWithImage(image string, replicas int32) *data.Deployment {
+	d := &data.Deployment{}
+	d.Spec.Replicas = &replicas
+	d.Spec.Template.Spec.Containers = []data.Container{{Name: "main", Image: image}}
+	return d
+}
+
+func TestGetAddOnStatuses(t *testing.T) {
+	p := Plan{}
+	p.AddOns.CNI = &CNI{Provider: cniProviderCalico}
+	p.AddOns.DNS.Provider = dnsProviderCoredns
+	p.AddOns.DNS.Options.Replicas = 3
+	p.AddOns.HeapsterMonitoring = &HeapsterMonitoring{Disable: true}
+	p.AddOns.Dashboard.Options.ServiceType = "NodePort"
+	p.AddOns.Rescheduler.Disable = true
+
+	calico := &data.DaemonSet{}
+	calico.Spec.Template.Spec.Containers = []data.Container{{Image: "quay.io/calico/node:v3.10.1"}}
+	calico.Status.DesiredNumberScheduled = 3
+	calico.Status.NumberReady = 3
+	client := fakeAddOnClient{
+		deployments: map[string]*data.Deployment{
+			"kube-system/coredns":                       deploymentWithImage("k8s.gcr.io/coredns:1.6.2", 2),
+			"kube-system/heapster":                      deploymentWithImage("registry:5000/heapster-amd64:v1.5.4", 2),
+			"kubernetes-dashboard/kubernetes-dashboard": deploymentWithImage("kubernetesui/dashboard:v2.0.0-beta4", 1),
+			"kube-system/tiller-deploy":                 deploymentWithImage("gcr.io/kubernetes-helm/tiller", 1),
+		},
+		daemonSets: map[string]*data.DaemonSet{"kube-system/calico-node": calico},
+		services: map[string]*data.Service{
+			"kubernetes-dashboard/kubernetes-dashboard": {Spec: data.ServiceSpec{Type: "ClusterIP"}},
+		},
+		pods: &data.PodList{},
+	}
+
+	expected := []AddOnStatus{
+		{Name: AddOnCNI, Enabled: true, Deployed: true, Version: "v3.10.1"},
+		{Name: AddOnRescheduler},
+		{Name: AddOnDNS, Enabled: true, Deployed: true, Version: "1.6.2", Drift: []string{"coredns has 2 replicas, the plan file has 3"}},
+		{Name: AddOnHeapster, Deployed: true, Version: "v1.5.4", Drift: []string{"disabled in the plan file, but deployed"}},
+		{Name: AddOnMetricsServer, Enabled: true, Drift: []string{"enabled in the plan file, but not deployed"}},
+		{Name: AddOnDashboard, Enabled: true, Deployed: true, Version: "v2.0.0-beta4", Drift: []string{"service kubernetes-dashboard is of type ClusterIP, the plan file has NodePort"}},
+		{Name: AddOnHelm, Enabled: true, Deployed: true, Version: "latest"},
+		{Name: AddOnIngress},
+	}
+	statuses := GetAddOnStatuses(p, client)
+	if len(statuses) != len(expected) {
+		t.Fatalf("expected %d statuses, but got %d", len(expected), len(statuses))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(statuses[i], expected[i]) {
+			t.Errorf("expected %+v, but got %+v", expected[i], statuses[i])
+		}
+	}
+}
+
+func TestValidateAddOnNames(t *testing.T) {
+	p := Plan{}
+	p.AddOns.CNI = &CNI{Provider: cniProviderCalico}
+	p.AddOns.Dashboard.Disable = true
+	tests := []struct {
+		names     []string
+		expectErr bool
+	}{
+		{names: []string{"dns", "cni"}},
+		{names: []string{"dashboard"}, expectErr: true},
+		{names: []string{"ingress"}, expectErr: true},
+		{names: []string{"foo"}, expectErr: true},
+	}
+	for _, test := range tests {
+		err := ValidateAddOnNames(p, test.names)
+		if err != nil && !test.expectErr {
+			t.Errorf("%v: unexpected error: %v", test.names, err)
+		}
+		if err == nil && test.expectErr {
+			t.Errorf("%v: expected an error, but got none", test.names)
+		}
+	}
+}
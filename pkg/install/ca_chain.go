@@ -0,0 +1,220 @@
+package install
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+const caChainFilename = "ca-chain.pem"
+
+// configuredCA is an existing CA that is set in the plan file
+type configuredCA struct {
+	cert  []byte
+	key   []byte
+	chain []byte
+}
+
+// reads the CA that is set in the plan file. The certificates that follow the CA
+// certificate in its file are added to the chain.
+func readConfiguredCA(c CertsConfig) (*configuredCA, error) {
+	certs, err := ioutil.ReadFile(c.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificate: %v", err)
+	}
+	key, err := ioutil.ReadFile(c.CAKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA key: %v", err)
+	}
+	block, rest := pem.Decode(certs)
+	if block == nil {
+		return nil, fmt.Errorf("CA certificate %q is not in PEM format", c.CACert)
+	}
+	ca := &configuredCA{
+		cert: pem.EncodeToMemory(block),
+		key:  key,
+	}
+	if rest = bytes.TrimSpace(rest); len(rest) > 0 {
+		ca.chain = append(rest, '\n')
+	}
+	if c.CAChain != "" {
+		chain, err := ioutil.ReadFile(c.CAChain)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA chain: %v", err)
+		}
+		if chain = bytes.TrimSpace(chain); len(chain) > 0 {
+			ca.chain = append(append(ca.chain, chain...), '\n')
+		}
+	}
+	return ca, nil
+}
+
+// verifyCAChain returns an error if the key does not belong to the CA certificate,
+// or if the CA certificate is not valid for the chain
+func verifyCAChain(certPEM, keyPEM, chainPEM []byte) error {
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return fmt.Errorf("error parsing CA certificate: %v", err)
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return fmt.Errorf("error parsing CA key: %v", err)
+	}
+	if !publicKeysEqual(key.Public(), cert.PublicKey) {
+		return fmt.Errorf("CA key does not match CA certificate %q", cert.Subject.CommonName)
+	}
+	return verifyCACert(cert, chainPEM)
+}
+
+// verifyCACert returns an error if the certificate cannot sign certificates, or if
+// it is not a root CA and does not chain up to a root CA of the chain
+func verifyCACert(cert *x509.Certificate, chainPEM []byte) error {
+	if !cert.IsCA {
+		return fmt.Errorf("certificate %q is not a CA certificate", cert.Subject.CommonName)
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("CA certificate %q cannot sign certificates", cert.Subject.CommonName)
+	}
+	if isSelfSigned(cert) {
+		return nil
+	}
+	chain := []*x509.Certificate{}
+	if len(bytes.TrimSpace(chainPEM)) > 0 {
+		var err error
+		if chain, err = helpers.ParseCertificatesPEM(chainPEM); err != nil {
+			return fmt.Errorf("error parsing CA chain: %v", err)
+		}
+	}
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	hasRoot := false
+	for _, c := range chain {
+		if isSelfSigned(c) {
+			roots.AddCert(c)
+			hasRoot = true
+			continue
+		}
+		intermediates.AddCert(c)
+	}
+	if !hasRoot {
+		return fmt.Errorf("CA certificate %q is an intermediate CA, but the chain does not include the root CA", cert.Subject.CommonName)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("CA certificate %q does not chain up to the root CA: %v", cert.Subject.CommonName, err)
+	}
+	return nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func publicKeysEqual(a crypto.PublicKey, b crypto.PublicKey) bool {
+	ab, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bb, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ab, bb)
+}
+
+// importConfiguredCA writes the CA of the plan file to the directory under the
+// given name, after verifying its chain
+func importConfiguredCA(p *Plan, dir string, name string) (*tls.CA, error) {
+	ca, err := readConfiguredCA(p.Cluster.Certificates)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyCAChain(ca.cert, ca.key, ca.chain); err != nil {
+		return nil, err
+	}
+	if err = tls.WriteCert(ca.key, ca.cert, name, dir); err != nil {
+		return nil, fmt.Errorf("error writing CA files: %v", err)
+	}
+	return &tls.CA{Cert: ca.cert, Key: ca.key}, nil
+}
+
+// writeCAChain writes the full chain of the cluster CA, that is trusted by the
+// nodes and the kubeconfig, when the CA of the plan file is an intermediate CA.
+// The chain is only written once the CA of the plan file is the cluster CA.
+func writeCAChain(p *Plan, dir string) error {
+	file := filepath.Join(dir, caChainFilename)
+	ca := &configuredCA{}
+	if p.Cluster.Certificates.CACert != "" {
+		var err error
+		if ca, err = readConfiguredCA(p.Cluster.Certificates); err != nil {
+			return err
+		}
+	}
+	cert, err := ioutil.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return fmt.Errorf("error reading CA certificate: %v", err)
+	}
+	if len(ca.cert) > 0 && !bytes.Equal(bytes.TrimSpace(cert), bytes.TrimSpace(ca.cert)) {
+		// the CA of the plan file replaces the cluster CA during a CA rotation
+		return nil
+	}
+	if len(ca.chain) == 0 {
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing CA chain %q: %v", file, err)
+		}
+		return nil
+	}
+	chain := append(append(bytes.TrimSpace(cert), '\n'), ca.chain...)
+	if err := ioutil.WriteFile(file, chain, 0644); err != nil {
+		return fmt.Errorf("error writing CA chain to %q: %v", file, err)
+	}
+	return nil
+}
+
+// ValidateClusterCA validates the CA of the plan file, and verifies that it is the
+// CA of the generated certificates
+func (lp *LocalPKI) ValidateClusterCA(p *Plan) error {
+	if p.Cluster.Certificates.CACert == "" {
+		return nil
+	}
+	ca, err := readConfiguredCA(p.Cluster.Certificates)
+	if err != nil {
+		return err
+	}
+	if err = verifyCAChain(ca.cert, ca.key, ca.chain); err != nil {
+		return err
+	}
+	exists, err := tls.CertKeyPairExists("ca", lp.GeneratedCertsDirectory)
+	if err != nil || !exists {
+		return err
+	}
+	current, err := ioutil.ReadFile(filepath.Join(lp.GeneratedCertsDirectory, "ca.pem"))
+	if err != nil {
+		return fmt.Errorf("error reading CA certificate: %v", err)
+	}
+	if bytes.Equal(bytes.TrimSpace(current), bytes.TrimSpace(ca.cert)) {
+		return nil
+	}
+	state, err := ReadCARotationState(filepath.Dir(lp.GeneratedCertsDirectory))
+	if err != nil {
+		return err
+	}
+	// the CA of the plan file replaces the current CA during a CA rotation
+	if state.Phase != "" {
+		return nil
+	}
+	return errors.New("The CA certificate of the plan file is not the CA of the generated certificates. Use \"kismatic certificates rotate-ca\" to replace the CA")
+}
@@ -0,0 +1,203 @@
+package install
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// creates a CA signed by the parent, or a self-signed CA when the parent is nil
+func newTestCA(t *testing.T, name string, parent *testCA) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+	return &testCA{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestVerifyCAChain(t *testing.T) {
+	root := newTestCA(t, "root", nil)
+	intermediate := newTestCA(t, "intermediate", root)
+	issuing := newTestCA(t, "issuing", intermediate)
+	other := newTestCA(t, "other", nil)
+
+	tests := []struct {
+		name  string
+		cert  []byte
+		key   []byte
+		chain []byte
+		valid bool
+	}{
+		{
+			name:  "self-signed CA",
+			cert:  root.certPEM,
+			key:   root.keyPEM,
+			valid: true,
+		},
+		{
+			name:  "intermediate CA with root",
+			cert:  intermediate.certPEM,
+			key:   intermediate.keyPEM,
+			chain: root.certPEM,
+			valid: true,
+		},
+		{
+			name:  "intermediate CA with intermediate and root",
+			cert:  issuing.certPEM,
+			key:   issuing.keyPEM,
+			chain: append(append([]byte{}, intermediate.certPEM...), root.certPEM...),
+			valid: true,
+		},
+		{
+			name: "intermediate CA without chain",
+			cert: intermediate.certPEM,
+			key:  intermediate.keyPEM,
+		},
+		{
+			name:  "intermediate CA missing an intermediate",
+			cert:  issuing.certPEM,
+			key:   issuing.keyPEM,
+			chain: root.certPEM,
+		},
+		{
+			name:  "intermediate CA with another root",
+			cert:  intermediate.certPEM,
+			key:   intermediate.keyPEM,
+			chain: other.certPEM,
+		},
+		{
+			name: "key does not match",
+			cert: root.certPEM,
+			key:  other.keyPEM,
+		},
+	}
+	for _, test := range tests {
+		err := verifyCAChain(test.cert, test.key, test.chain)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestGenerateClusterCAUsesConfiguredIntermediateCA(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	configDir, err := ioutil.TempDir("", "ca-config")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(configDir)
+	root := newTestCA(t, "root", nil)
+	intermediate := newTestCA(t, "intermediate", root)
+	files := map[string][]byte{
+		"intermediate.pem":     intermediate.certPEM,
+		"intermediate-key.pem": intermediate.keyPEM,
+		"root.pem":             root.certPEM,
+	}
+	for name, d := range files {
+		if err = ioutil.WriteFile(filepath.Join(configDir, name), d, 0600); err != nil {
+			t.Fatalf("error writing %q: %v", name, err)
+		}
+	}
+	p := getPlan()
+	p.Cluster.Certificates.CACert = filepath.Join(configDir, "intermediate.pem")
+	p.Cluster.Certificates.CAKey = filepath.Join(configDir, "intermediate-key.pem")
+	p.Cluster.Certificates.CAChain = filepath.Join(configDir, "root.pem")
+
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(ca.Cert, intermediate.certPEM) {
+		t.Errorf("expected the configured CA to be the cluster CA")
+	}
+	if _, err = pki.GenerateCertificate("leaf", CertsConfig{Expiry: "1h"}, "leaf", nil, nil, ca, false); err != nil {
+		t.Fatalf("error generating certificate: %v", err)
+	}
+	leaf, err := tls.ReadCert("leaf", pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	if err = leaf.CheckSignatureFrom(intermediate.cert); err != nil {
+		t.Errorf("expected the certificate to be signed by the configured CA: %v", err)
+	}
+
+	chain, err := ioutil.ReadFile(filepath.Join(pki.GeneratedCertsDirectory, caChainFilename))
+	if err != nil {
+		t.Fatalf("error reading CA chain: %v", err)
+	}
+	expected := append(append([]byte{}, intermediate.certPEM...), root.certPEM...)
+	if !bytes.Equal(bytes.TrimSpace(chain), bytes.TrimSpace(expected)) {
+		t.Errorf("expected the CA chain to contain the intermediate and root CAs, got:\n%s", chain)
+	}
+	if clusterCATrustFile(pki.GeneratedCertsDirectory) != filepath.Join(pki.GeneratedCertsDirectory, caChainFilename) {
+		t.Errorf("expected the CA chain to be trusted, got %q", clusterCATrustFile(pki.GeneratedCertsDirectory))
+	}
+	if err = pki.ValidateClusterCA(p); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	// another CA in the plan file is not the CA of the generated certificates
+	other := newTestCA(t, "other", nil)
+	if err = ioutil.WriteFile(p.Cluster.Certificates.CACert, other.certPEM, 0600); err != nil {
+		t.Fatalf("error writing CA certificate: %v", err)
+	}
+	if err = ioutil.WriteFile(p.Cluster.Certificates.CAKey, other.keyPEM, 0600); err != nil {
+		t.Fatalf("error writing CA key: %v", err)
+	}
+	if err = pki.ValidateClusterCA(p); err == nil {
+		t.Errorf("expected a validation error when the CA of the plan file changed")
+	}
+}
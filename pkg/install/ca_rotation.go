@@ -0,0 +1,354 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The phases of a cluster CA rotation, in the order they are run
+const (
+	CARotationPhaseTrust   = "trust"
+	CARotationPhaseReissue = "reissue"
+	CARotationPhaseRemove  = "remove-old-ca"
+)
+
+const (
+	caRotationStateFilename = "ca-rotation.yaml"
+	caBundleFilename        = "ca-bundle.pem"
+	caNextName              = "ca-next"
+	caPreviousName          = "ca-previous"
+	serviceAccountMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	mirrorPodAnnotation     = "kubernetes.io/config.mirror"
+)
+
+// CARotationPhases returns the phases of a cluster CA rotation
+func CARotationPhases() []string {
+	return []string{CARotationPhaseTrust, CARotationPhaseReissue, CARotationPhaseRemove}
+}
+
+// CARotationState is the progress of a cluster CA rotation
+type CARotationState struct {
+	// Phase is the last phase that was started
+	Phase string `yaml:"phase"`
+	// Completed is set once the phase has been rolled out and verified
+	Completed bool `yaml:"completed"`
+	// TrustedAt is when the nodes started trusting both the old and new CAs
+	TrustedAt time.Time `yaml:"trusted_at,omitempty"`
+}
+
+// NextPhase returns the phase that is run next. A phase that was started but
+// did not complete is run again. An empty string is returned when no rotation
+// is in progress.
+func (s CARotationState) NextPhase() string {
+	if s.Phase == "" {
+		return CARotationPhaseTrust
+	}
+	if !s.Completed {
+		return s.Phase
+	}
+	phases := CARotationPhases()
+	for i, p := range phases {
+		if p == s.Phase && i+1 < len(phases) {
+			return phases[i+1]
+		}
+	}
+	return ""
+}
+
+// ReadCARotationState returns the progress of the cluster CA rotation. The zero
+// state is returned when no rotation was started.
+func ReadCARotationState(generatedAssetsDir string) (CARotationState, error) {
+	state := CARotationState{}
+	file := filepath.Join(generatedAssetsDir, "keys", caRotationStateFilename)
+	d, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("error reading CA rotation state: %v", err)
+	}
+	if err = yaml.Unmarshal(d, &state); err != nil {
+		return state, fmt.Errorf("error unmarshaling CA rotation state %q: %v", file, err)
+	}
+	return state, nil
+}
+
+func writeCARotationState(generatedAssetsDir string, state CARotationState) error {
+	d, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error marshaling CA rotation state: %v", err)
+	}
+	file := filepath.Join(generatedAssetsDir, "keys", caRotationStateFilename)
+	if err = ioutil.WriteFile(file, d, 0600); err != nil {
+		return fmt.Errorf("error writing CA rotation state to %q: %v", file, err)
+	}
+	return nil
+}
+
+// clusterCATrustFile returns the file that the nodes and the kubeconfig trust
+// as the cluster CA. During a CA rotation, it is the bundle of the old and new CAs.
+// When the cluster CA is an intermediate CA, it is the full chain of the CA.
+func clusterCATrustFile(certsDir string) string {
+	for _, f := range []string{caBundleFilename, caChainFilename} {
+		if _, err := os.Stat(filepath.Join(certsDir, f)); err == nil {
+			return filepath.Join(certsDir, f)
+		}
+	}
+	return filepath.Join(certsDir, "ca.pem")
+}
+
+// StaleCAPods returns the pods that mount a service account token, and that started
+// before the nodes trusted the new CA. These pods only trust the old CA, and are not
+// able to reach the API server once its certificate is issued by the new CA.
+func StaleCAPods(pods *data.PodList, trustedAt time.Time) []data.Pod {
+	stale := []data.Pod{}
+	for _, p := range pods.Items {
+		if _, ok := p.Annotations[mirrorPodAnnotation]; ok {
+			// static pods don't use service account tokens
+			continue
+		}
+		if p.Status.StartTime == nil || !p.Status.StartTime.Before(trustedAt) {
+			continue
+		}
+		mountsToken := false
+		for _, c := range p.Spec.Containers {
+			for _, m := range c.VolumeMounts {
+				mountsToken = mountsToken || m.MountPath == serviceAccountMountPath
+			}
+		}
+		if mountsToken {
+			stale = append(stale, p)
+		}
+	}
+	return stale
+}
+
+// PrepareCARotationPhase updates the certificates of the generated assets directory
+// for the phase of the cluster CA rotation. Every phase can safely be prepared again.
+//   - trust: a new CA is generated, and bundled with the current CA
+//   - reissue: the new CA replaces the current CA, and the certificates signed by
+//     the cluster CA are reissued. The service account key pair is kept, so that
+//     the service account tokens remain valid.
+//   - remove-old-ca: the previous CA and the bundle are removed
+func (lp *LocalPKI) PrepareCARotationPhase(p *Plan, phase string) error {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	dir := lp.GeneratedCertsDirectory
+	switch phase {
+	case CARotationPhaseTrust:
+		exists, err := tls.CertKeyPairExists(caNextName, dir)
+		if err != nil {
+			return fmt.Errorf("error verifying new CA certificate/key: %v", err)
+		}
+		configured := p.Cluster.Certificates.CACert != ""
+		if !exists && configured {
+			// the CA of the plan file is the new CA
+			ca, err := readConfiguredCA(p.Cluster.Certificates)
+			if err != nil {
+				return err
+			}
+			current, err := ioutil.ReadFile(filepath.Join(dir, "ca.pem"))
+			if err != nil {
+				return fmt.Errorf("error reading CA certificate: %v", err)
+			}
+			if bytes.Equal(bytes.TrimSpace(current), bytes.TrimSpace(ca.cert)) {
+				return errors.New("the CA of the plan file is the current cluster CA, set the new CA in the plan file to rotate the CA")
+			}
+			util.PrettyPrintOk(lp.Log, "Using new cluster Certificate Authority %q", p.Cluster.Certificates.CACert)
+			if _, err = importConfiguredCA(p, dir, caNextName); err != nil {
+				return err
+			}
+		}
+		if !exists && !configured {
+			util.PrettyPrintOk(lp.Log, "Generating new cluster Certificate Authority")
+			key, cert, err := tls.NewCACert(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.CAKeyAlgorithm, p.Cluster.Certificates.Subject.tlsSubject())
+			if err != nil {
+				return fmt.Errorf("failed to create new CA Cert: %v", err)
+			}
+			if err = tls.WriteCert(key, cert, caNextName, dir); err != nil {
+				return fmt.Errorf("error writing new CA files: %v", err)
+			}
+		}
+		// the current CA is trusted along with its chain
+		current := filepath.Join(dir, "ca.pem")
+		if _, err := os.Stat(filepath.Join(dir, caChainFilename)); err == nil {
+			current = filepath.Join(dir, caChainFilename)
+		}
+		files := [][]byte{}
+		for _, f := range []string{current, filepath.Join(dir, caNextName+".pem")} {
+			cert, err := ioutil.ReadFile(f)
+			if err != nil {
+				return fmt.Errorf("error reading certificate %q: %v", f, err)
+			}
+			files = append(files, cert)
+		}
+		if configured {
+			ca, err := readConfiguredCA(p.Cluster.Certificates)
+			if err != nil {
+				return err
+			}
+			files = append(files, ca.chain)
+		}
+		return writeCABundle(dir, files...)
+	case CARotationPhaseReissue:
+		exists, err := tls.CertKeyPairExists(caNextName, dir)
+		if err != nil {
+			return fmt.Errorf("error verifying new CA certificate/key: %v", err)
+		}
+		// the new CA was already swapped in if the phase is run again
+		if exists {
+			if err = renameCertKeyPair(dir, "ca", caPreviousName); err != nil {
+				return err
+			}
+			if err = renameCertKeyPair(dir, caNextName, "ca"); err != nil {
+				return err
+			}
+		}
+		// the chain of the new CA is trusted once the old CA is removed
+		if err = writeCAChain(p, dir); err != nil {
+			return err
+		}
+		clusterCA, err := lp.GetClusterCA()
+		if err != nil {
+			return err
+		}
+		proxyClientCA, err := lp.GetProxyClientCA()
+		if err != nil {
+			return err
+		}
+		specs, err := p.certSpecs(clusterCA, proxyClientCA)
+		if err != nil {
+			return fmt.Errorf("error building certificate specs: %v", err)
+		}
+		for _, s := range specs {
+			if s.ca != clusterCA || s.filename == serviceAccountCertFilename {
+				continue
+			}
+			if err := generateCert(dir, s, p.Cluster.Certificates); err != nil {
+				return err
+			}
+			util.PrettyPrintOk(lp.Log, "Reissued %s certificate", s.description)
+		}
+		return nil
+	case CARotationPhaseRemove:
+		for _, f := range []string{caBundleFilename, caPreviousName + ".pem", caPreviousName + "-key.pem"} {
+			if err := os.Remove(filepath.Join(dir, f)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing %q: %v", f, err)
+			}
+		}
+		util.PrettyPrintOk(lp.Log, "Removed the previous cluster Certificate Authority")
+		return nil
+	default:
+		return fmt.Errorf("%q is not a CA rotation phase", phase)
+	}
+}
+
+// writes the bundle of the certificates, in order
+func writeCABundle(dir string, certs ...[]byte) error {
+	bundle := []byte{}
+	for _, c := range certs {
+		if c = bytes.TrimSpace(c); len(c) > 0 {
+			bundle = append(append(bundle, c...), '\n')
+		}
+	}
+	file := filepath.Join(dir, caBundleFilename)
+	if err := ioutil.WriteFile(file, bundle, 0644); err != nil {
+		return fmt.Errorf("error writing CA bundle to %q: %v", file, err)
+	}
+	return nil
+}
+
+func renameCertKeyPair(dir, from, to string) error {
+	for _, suffix := range []string{".pem", "-key.pem"} {
+		if err := os.Rename(filepath.Join(dir, from+suffix), filepath.Join(dir, to+suffix)); err != nil {
+			return fmt.Errorf("error renaming %q to %q: %v", from+suffix, to+suffix, err)
+		}
+	}
+	return nil
+}
+
+// RotateClusterCA runs the next phase of the cluster CA rotation. The certificates
+// are rolled out one node at a time, restarting all the components, and the
+// rotation is verified against the cluster once all the nodes are done.
+// A phase that fails can be run again.
+func (ae *ansibleExecutor) RotateClusterCA(plan Plan) error {
+	state, err := ReadCARotationState(ae.options.GeneratedAssetsDirectory)
+	if err != nil {
+		return err
+	}
+	phase := state.NextPhase()
+	if phase == "" {
+		return errors.New("the cluster CA rotation is already complete")
+	}
+	state.Phase = phase
+	state.Completed = false
+	if err = writeCARotationState(ae.options.GeneratedAssetsDirectory, state); err != nil {
+		return err
+	}
+
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Rotate Cluster CA: %s", phase), '=')
+	if err = ae.pki.PrepareCARotationPhase(&plan, phase); err != nil {
+		return fmt.Errorf("error preparing the %s phase: %v", phase, err)
+	}
+	if _, err = RegenerateKubeconfig(&plan, ae.options.GeneratedAssetsDirectory); err != nil {
+		return fmt.Errorf("error regenerating kubeconfig: %v", err)
+	}
+
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	// every component reads the CA and its certificates when it starts
+	cc.CertificateRotationRestarts = certificateRotationRestarts(CertificateComponents())
+	cc.CARotationPhase = phase
+	nodes := plan.GetUniqueNodes()
+	for i, n := range nodes {
+		util.PrintHeader(ae.stdout, fmt.Sprintf("Deploy Certificates (%d/%d): %s", i+1, len(nodes), n.Host), '=')
+		t := task{
+			name:           "certificates-rotate-ca",
+			playbook:       "certificates-rotate.yaml",
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			plan:           plan,
+			explainer:      ae.defaultExplainer(),
+			limit:          []string{n.Host},
+		}
+		if err = ae.execute(t); err != nil {
+			return fmt.Errorf("error deploying certificates to node %s, stopping the rollout: %v", n.Host, err)
+		}
+	}
+
+	util.PrintHeader(ae.stdout, "Verify Cluster CA Rotation", '=')
+	t := task{
+		name:           "certificates-rotate-ca-verify",
+		playbook:       "ca-rotation-verify.yaml",
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error verifying the %s phase: %v", phase, err)
+	}
+
+	if phase == CARotationPhaseTrust {
+		state.TrustedAt = time.Now().UTC()
+	}
+	if phase == CARotationPhaseRemove {
+		return os.Remove(filepath.Join(ae.options.GeneratedAssetsDirectory, "keys", caRotationStateFilename))
+	}
+	state.Completed = true
+	return writeCARotationState(ae.options.GeneratedAssetsDirectory, state)
+}
@@ -0,0 +1,176 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+func TestCARotationStateNextPhase(t *testing.T) {
+	tests := []struct {
+		state    CARotationState
+		expected string
+	}{
+		{
+			state:    CARotationState{},
+			expected: CARotationPhaseTrust,
+		},
+		{
+			state:    CARotationState{Phase: CARotationPhaseTrust},
+			expected: CARotationPhaseTrust,
+		},
+		{
+			state:    CARotationState{Phase: CARotationPhaseTrust, Completed: true},
+			expected: CARotationPhaseReissue,
+		},
+		{
+			state:    CARotationState{Phase: CARotationPhaseReissue, Completed: true},
+			expected: CARotationPhaseRemove,
+		},
+		{
+			state:    CARotationState{Phase: CARotationPhaseRemove, Completed: true},
+			expected: "",
+		},
+	}
+	for _, test := range tests {
+		if next := test.state.NextPhase(); next != test.expected {
+			t.Errorf("state %+v: expected next phase %q, got %q", test.state, test.expected, next)
+		}
+	}
+}
+
+func TestPrepareCARotationPhases(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	dir := pki.GeneratedCertsDirectory
+	p := getPlan()
+	p.Cluster.Certificates.CAExpiry = "2h"
+	oldCA, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, oldCA, proxyClientCA); err != nil {
+		t.Fatalf("error generating certificates for test: %v", err)
+	}
+	oldCACert, err := tls.ReadCert("ca", dir)
+	if err != nil {
+		t.Fatalf("error reading CA: %v", err)
+	}
+	serviceAccount, err := ioutil.ReadFile(filepath.Join(dir, "service-account-key.pem"))
+	if err != nil {
+		t.Fatalf("error reading service account key: %v", err)
+	}
+
+	// trust: the bundle contains both CAs, the current CA is unchanged
+	for i := 0; i < 2; i++ {
+		if err = pki.PrepareCARotationPhase(p, CARotationPhaseTrust); err != nil {
+			t.Fatalf("unexpected error preparing the trust phase: %v", err)
+		}
+	}
+	newCACert, err := tls.ReadCert(caNextName, dir)
+	if err != nil {
+		t.Fatalf("error reading new CA: %v", err)
+	}
+	bundle, err := ioutil.ReadFile(filepath.Join(dir, caBundleFilename))
+	if err != nil {
+		t.Fatalf("error reading CA bundle: %v", err)
+	}
+	certs, err := helpers.ParseCertificatesPEM(bundle)
+	if err != nil {
+		t.Fatalf("error parsing CA bundle: %v", err)
+	}
+	if len(certs) != 2 || !certs[0].Equal(oldCACert) || !certs[1].Equal(newCACert) {
+		t.Errorf("expected the CA bundle to contain the old and new CAs")
+	}
+	if clusterCATrustFile(dir) != filepath.Join(dir, caBundleFilename) {
+		t.Errorf("expected the CA bundle to be trusted during the rotation, got %q", clusterCATrustFile(dir))
+	}
+
+	// reissue: the certificates are signed by the new CA, except for the service account
+	for i := 0; i < 2; i++ {
+		if err = pki.PrepareCARotationPhase(p, CARotationPhaseReissue); err != nil {
+			t.Fatalf("unexpected error preparing the reissue phase: %v", err)
+		}
+	}
+	caCert, err := tls.ReadCert("ca", dir)
+	if err != nil {
+		t.Fatalf("error reading CA: %v", err)
+	}
+	if !caCert.Equal(newCACert) {
+		t.Errorf("expected the new CA to replace the cluster CA")
+	}
+	for _, name := range []string{"admin", "etcd01-etcd", "master01-apiserver", "worker01-kubelet", "etcd-client", "kube-scheduler"} {
+		cert, err := tls.ReadCert(name, dir)
+		if err != nil {
+			t.Fatalf("error reading certificate %q: %v", name, err)
+		}
+		if err = cert.CheckSignatureFrom(newCACert); err != nil {
+			t.Errorf("expected certificate %q to be signed by the new CA: %v", name, err)
+		}
+	}
+	key, err := ioutil.ReadFile(filepath.Join(dir, "service-account-key.pem"))
+	if err != nil {
+		t.Fatalf("error reading service account key: %v", err)
+	}
+	if string(key) != string(serviceAccount) {
+		t.Errorf("expected the service account key to be kept")
+	}
+
+	// remove-old-ca: the previous CA and the bundle are removed
+	if err = pki.PrepareCARotationPhase(p, CARotationPhaseRemove); err != nil {
+		t.Fatalf("unexpected error preparing the remove-old-ca phase: %v", err)
+	}
+	for _, f := range []string{caBundleFilename, "ca-previous.pem", "ca-previous-key.pem"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("expected %q to be removed", f)
+		}
+	}
+	if clusterCATrustFile(dir) != filepath.Join(dir, "ca.pem") {
+		t.Errorf("expected the cluster CA to be trusted after the rotation, got %q", clusterCATrustFile(dir))
+	}
+}
+
+func TestStaleCAPods(t *testing.T) {
+	trustedAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	before := trustedAt.Add(-time.Hour)
+	after := trustedAt.Add(time.Hour)
+	tokenMount := []data.Container{{VolumeMounts: []data.VolumeMount{{Name: "token", MountPath: serviceAccountMountPath}}}}
+	pods := &data.PodList{
+		Items: []data.Pod{
+			{
+				ObjectMeta: data.ObjectMeta{Name: "stale"},
+				Spec:       data.PodSpec{Containers: tokenMount},
+				Status:     data.PodStatus{StartTime: &before},
+			},
+			{
+				ObjectMeta: data.ObjectMeta{Name: "restarted"},
+				Spec:       data.PodSpec{Containers: tokenMount},
+				Status:     data.PodStatus{StartTime: &after},
+			},
+			{
+				ObjectMeta: data.ObjectMeta{Name: "no-token"},
+				Spec:       data.PodSpec{Containers: []data.Container{{Name: "app"}}},
+				Status:     data.PodStatus{StartTime: &before},
+			},
+			{
+				ObjectMeta: data.ObjectMeta{Name: "static", Annotations: map[string]string{mirrorPodAnnotation: "abc"}},
+				Spec:       data.PodSpec{Containers: tokenMount},
+				Status:     data.PodStatus{StartTime: &before},
+			},
+		},
+	}
+	stale := StaleCAPods(pods, trustedAt)
+	if len(stale) != 1 || stale[0].Name != "stale" {
+		t.Errorf("expected only the stale pod, got %v", stale)
+	}
+}
@@ -0,0 +1,197 @@
+package install
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// CertificateInfo describes a certificate of the generated assets directory
+type CertificateInfo struct {
+	Name        string    `json:"name"`
+	Subject     string    `json:"subject"`
+	SANs        []string  `json:"sans,omitempty"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"notAfter"`
+	Fingerprint string    `json:"fingerprint"`
+	// Expiring is set when the certificate expires within the threshold, or has expired
+	Expiring bool `json:"expiring"`
+	Expired  bool `json:"expired"`
+	// Deployed are the copies of the certificate on the nodes, when they were checked
+	Deployed []DeployedCertificate `json:"deployed,omitempty"`
+}
+
+// DeployedCertificate is the copy of a certificate that is deployed on a node
+type DeployedCertificate struct {
+	Host string `json:"host"`
+	Path string `json:"path"`
+	// Match is set when the node has the same certificate as the generated assets directory
+	Match       bool      `json:"match"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	NotAfter    time.Time `json:"notAfter,omitempty"`
+	// Error is set when the certificate could not be read from the node
+	Error string `json:"error,omitempty"`
+}
+
+// MismatchedCertificates returns the copies of the certificates on the nodes that are
+// missing, or that are not the certificate of the generated assets directory
+func MismatchedCertificates(certs []CertificateInfo) []DeployedCertificate {
+	mismatched := []DeployedCertificate{}
+	for _, c := range certs {
+		for _, d := range c.Deployed {
+			if !d.Match {
+				mismatched = append(mismatched, d)
+			}
+		}
+	}
+	return mismatched
+}
+
+// ListCertificates reads every certificate in the directory, and flags the
+// certificates that expire within the threshold
+func ListCertificates(dir string, threshold time.Duration, now time.Time) ([]CertificateInfo, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing certificates in %q: %v", dir, err)
+	}
+	sort.Strings(files)
+	certs := []CertificateInfo{}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".pem")
+		// the CA bundle and chain contain more than one certificate
+		if strings.HasSuffix(name, "-key") || filepath.Base(f) == caBundleFilename || filepath.Base(f) == caChainFilename {
+			continue
+		}
+		cert, err := tls.ReadCert(name, dir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate %q: %v", f, err)
+		}
+		info := CertificateInfo{
+			Name:        name,
+			Subject:     cert.Subject.String(),
+			SANs:        certificateSANs(cert),
+			Issuer:      cert.Issuer.String(),
+			NotAfter:    cert.NotAfter,
+			Fingerprint: certificateFingerprint(cert),
+			Expired:     !now.Before(cert.NotAfter),
+		}
+		info.Expiring = !now.Add(threshold).Before(cert.NotAfter)
+		certs = append(certs, info)
+	}
+	return certs, nil
+}
+
+func certificateSANs(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+func certificateFingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+// deployedCertificatePath is the location of a certificate of the generated
+// assets directory on a node
+type deployedCertificatePath struct {
+	name string
+	path string
+}
+
+// returns where the certificates of the generated assets directory are deployed on
+// the node. The paths are the ones set in ansible/group_vars/all.yaml
+func deployedCertificatePaths(node Node, roles []string) []deployedCertificatePath {
+	paths := []deployedCertificatePath{}
+	if util.Contains("etcd", roles) {
+		paths = append(paths,
+			deployedCertificatePath{"ca", etcdK8sVars.certificate("ca.pem")},
+			deployedCertificatePath{node.Host + "-etcd", etcdK8sVars.certificate("etcd.pem")},
+			deployedCertificatePath{"etcd-client", etcdK8sVars.certificate("etcd-client.pem")},
+		)
+	}
+	k8sNode := false
+	for _, r := range []string{"master", "worker", "ingress", "storage"} {
+		k8sNode = k8sNode || util.Contains(r, roles)
+	}
+	if !k8sNode {
+		return paths
+	}
+	pki := "/etc/kubernetes/pki/"
+	paths = append(paths,
+		deployedCertificatePath{"ca", pki + "ca.pem"},
+		deployedCertificatePath{"proxy-client-ca", pki + "proxy-client-ca.pem"},
+		deployedCertificatePath{adminCertFilename, pki + "admin.pem"},
+		deployedCertificatePath{node.Host + "-kubelet", pki + "kubelet.pem"},
+		deployedCertificatePath{"etcd-client", pki + "etcd-client.pem"},
+	)
+	if util.Contains("master", roles) {
+		paths = append(paths,
+			deployedCertificatePath{node.Host + "-apiserver", pki + "api-server.pem"},
+			deployedCertificatePath{schedulerCertFilenamePrefix, pki + "scheduler.pem"},
+			deployedCertificatePath{controllerManagerCertFilenamePrefix, pki + "controller-manager.pem"},
+			deployedCertificatePath{kubeAPIServerKubeletClientClientFilename, pki + "apiserver-kubelet-client.pem"},
+			deployedCertificatePath{proxyClientCertFilename, pki + "proxy-client.pem"},
+			deployedCertificatePath{serviceAccountCertFilename, pki + "service-account.pem"},
+		)
+	}
+	return paths
+}
+
+// CheckDeployedCertificates compares the certificates with the copies that are
+// deployed on the nodes of the plan, over SSH
+func CheckDeployedCertificates(plan *Plan, certs []CertificateInfo) []CertificateInfo {
+	return checkDeployedCertificates(*plan, certs, func(host string, cmd string) (string, error) {
+		client, err := plan.GetSSHClient(host)
+		if err != nil {
+			return "", err
+		}
+		return client.Output(false, cmd)
+	})
+}
+
+func checkDeployedCertificates(plan Plan, certs []CertificateInfo, run remoteCommandRunner) []CertificateInfo {
+	byName := map[string]int{}
+	for i, c := range certs {
+		byName[c.Name] = i
+	}
+	for _, n := range plan.GetUniqueNodes() {
+		for _, p := range deployedCertificatePaths(n, plan.GetRolesForIP(n.IP)) {
+			i, ok := byName[p.name]
+			if !ok {
+				// the certificate is not in the generated assets directory
+				continue
+			}
+			d := DeployedCertificate{Host: n.Host, Path: p.path}
+			out, err := run(n.Host, "sudo cat "+p.path)
+			if err != nil {
+				d.Error = strings.TrimSpace(out)
+				if d.Error == "" {
+					d.Error = err.Error()
+				}
+				certs[i].Deployed = append(certs[i].Deployed, d)
+				continue
+			}
+			cert, err := helpers.ParseCertificatePEM([]byte(strings.TrimSpace(out)))
+			if err != nil {
+				d.Error = fmt.Sprintf("error parsing certificate: %v", err)
+				certs[i].Deployed = append(certs[i].Deployed, d)
+				continue
+			}
+			d.Fingerprint = certificateFingerprint(cert)
+			d.NotAfter = cert.NotAfter
+			d.Match = d.Fingerprint == certs[i].Fingerprint
+			certs[i].Deployed = append(certs[i].Deployed, d)
+		}
+	}
+	return certs
+}
@@ -0,0 +1,308 @@
+package install
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+const certificateRequestExt = ".csr"
+
+// the names of the extended key usages, as used by cfssl
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageServerAuth: "server auth",
+	x509.ExtKeyUsageClientAuth: "client auth",
+}
+
+// returns true if the certificate of the CA exists without its key, i.e. the
+// certificates of the CA are signed outside of kismatic
+func externalCAExists(name, dir string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, name+"-key.pem")); err == nil || !os.IsNotExist(err) {
+		return false, err
+	}
+	_, err := os.Stat(filepath.Join(dir, name+".pem"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// reads the certificate and key of the CA. Only the certificate is read when
+// the key is not available.
+func readCA(name, dir string) (*tls.CA, error) {
+	external, err := externalCAExists(name, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !external {
+		key, cert, err := tls.ReadCACert(name, dir)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.CA{Cert: cert, Key: key}, nil
+	}
+	cert, err := ioutil.ReadFile(filepath.Join(dir, name+".pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate: %v", err)
+	}
+	return &tls.CA{Cert: cert}, nil
+}
+
+// ExportCertificateRequests writes a certificate signing request to the directory
+// for each certificate of the cluster that does not exist yet. The private keys
+// are kept in the generated certificates directory, and are reused when the
+// requests are exported again. Returns the names of the exported requests.
+func (lp *LocalPKI) ExportCertificateRequests(p *Plan, dir string) ([]string, error) {
+	manifest, err := p.certSpecs(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating directory %q: %v", dir, err)
+	}
+	exported := []string{}
+	for _, s := range manifest {
+		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+		req, err := certificateRequest(s, p.Cluster.Certificates)
+		if err != nil {
+			return nil, err
+		}
+		key, err := lp.requestKey(s, req)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := helpers.ParsePrivateKeyPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key for %q: %v", s.description, err)
+		}
+		request, err := csr.Generate(priv, &req)
+		if err != nil {
+			return nil, fmt.Errorf("error generating certificate request for %q: %v", s.description, err)
+		}
+		name := s.filename + certificateRequestExt
+		if err = ioutil.WriteFile(filepath.Join(dir, name), request, 0644); err != nil {
+			return nil, fmt.Errorf("error writing certificate request for %q: %v", s.description, err)
+		}
+		exported = append(exported, name)
+	}
+	return exported, nil
+}
+
+// returns the private key of the certificate, generating it if it does not exist
+func (lp *LocalPKI) requestKey(s certificateSpec, req csr.CertificateRequest) ([]byte, error) {
+	file := filepath.Join(lp.GeneratedCertsDirectory, s.filename+"-key.pem")
+	key, err := ioutil.ReadFile(file)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading key for %q: %v", s.description, err)
+	}
+	_, key, err = csr.ParseRequest(&req)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key for %q: %v", s.description, err)
+	}
+	if err = os.MkdirAll(lp.GeneratedCertsDirectory, 0744); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(file, key, 0600); err != nil {
+		return nil, fmt.Errorf("error writing key for %q: %v", s.description, err)
+	}
+	return key, nil
+}
+
+// ImportCertificates validates the certificates of the directory that were signed
+// for the exported requests, and places them in the generated certificates
+// directory. The directory contains the certificate of the cluster CA, ca.pem,
+// optionally followed by its chain, and the certificate of the proxy-client CA,
+// proxy-client-ca.pem. The CA certificates can be omitted once imported.
+// Nothing is imported if any certificate is not valid.
+// Returns the names of the imported certificates.
+func (lp *LocalPKI) ImportCertificates(p *Plan, dir string) ([]string, []error) {
+	clusterCA, chain, err := lp.importedCA(dir, "ca")
+	if err != nil {
+		return nil, []error{err}
+	}
+	proxyClientCA, _, err := lp.importedCA(dir, "proxy-client-ca")
+	if err != nil {
+		return nil, []error{err}
+	}
+	// the API server trusts any certificate of the proxy-client CA to authenticate users
+	if bytes.Equal(bytes.TrimSpace(clusterCA.Cert), bytes.TrimSpace(proxyClientCA.Cert)) {
+		return nil, []error{errors.New("The proxy-client CA must not be the cluster CA")}
+	}
+	manifest, err := p.certSpecs(clusterCA, proxyClientCA)
+	if err != nil {
+		return nil, []error{err}
+	}
+	certs := map[string][]byte{}
+	errs := []error{}
+	for _, s := range manifest {
+		certFile := filepath.Join(dir, s.filename+".pem")
+		if _, err := os.Stat(certFile); os.IsNotExist(err) {
+			exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+			if err != nil {
+				errs = append(errs, err)
+			} else if !exists {
+				errs = append(errs, fmt.Errorf("Certificate %q for %s is missing", filepath.Base(certFile), s.description))
+			}
+			continue
+		}
+		cert, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reading certificate %q: %v", certFile, err))
+			continue
+		}
+		if certErrs := lp.validateImportedCert(s, cert, dir, p.Cluster.Certificates.KeyAlgorithm); len(certErrs) > 0 {
+			errs = append(errs, certErrs...)
+			continue
+		}
+		certs[s.filename] = cert
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	imported := []string{}
+	for _, ca := range []struct {
+		name string
+		ca   *tls.CA
+	}{{"ca", clusterCA}, {"proxy-client-ca", proxyClientCA}} {
+		if err := ioutil.WriteFile(filepath.Join(lp.GeneratedCertsDirectory, ca.name+".pem"), ca.ca.Cert, 0644); err != nil {
+			return nil, []error{fmt.Errorf("error writing CA certificate %q: %v", ca.name, err)}
+		}
+	}
+	if len(chain) > 0 {
+		file := filepath.Join(lp.GeneratedCertsDirectory, caChainFilename)
+		if err := ioutil.WriteFile(file, append(append(bytes.TrimSpace(clusterCA.Cert), '\n'), chain...), 0644); err != nil {
+			return nil, []error{fmt.Errorf("error writing CA chain to %q: %v", file, err)}
+		}
+	}
+	for _, s := range manifest {
+		cert, ok := certs[s.filename]
+		if !ok {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(lp.GeneratedCertsDirectory, s.filename+".pem"), cert, 0644); err != nil {
+			return nil, []error{fmt.Errorf("error writing certificate for %q: %v", s.description, err)}
+		}
+		imported = append(imported, s.filename+".pem")
+	}
+	return imported, nil
+}
+
+// returns the CA certificate of the directory, or the imported one. The CA
+// certificate of the directory must match the imported one, if any.
+func (lp *LocalPKI) importedCA(dir string, name string) (*tls.CA, []byte, error) {
+	current, err := ioutil.ReadFile(filepath.Join(lp.GeneratedCertsDirectory, name+".pem"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("error reading CA certificate %q: %v", name, err)
+	}
+	certs, err := ioutil.ReadFile(filepath.Join(dir, name+".pem"))
+	if os.IsNotExist(err) {
+		if current == nil {
+			return nil, nil, fmt.Errorf("CA certificate %q is missing", name+".pem")
+		}
+		return &tls.CA{Cert: current}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CA certificate %q: %v", name, err)
+	}
+	block, rest := pem.Decode(certs)
+	if block == nil {
+		return nil, nil, fmt.Errorf("CA certificate %q is not in PEM format", name+".pem")
+	}
+	cert := pem.EncodeToMemory(block)
+	if current != nil && !bytes.Equal(bytes.TrimSpace(current), bytes.TrimSpace(cert)) {
+		return nil, nil, fmt.Errorf("CA certificate %q is not the CA of the generated certificates. Use \"kismatic certificates rotate-ca\" to replace the CA", name+".pem")
+	}
+	parsed, err := helpers.ParseCertificatePEM(cert)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing CA certificate %q: %v", name+".pem", err)
+	}
+	var chain []byte
+	if rest = bytes.TrimSpace(rest); len(rest) > 0 {
+		chain = append(rest, '\n')
+	}
+	if err = verifyCACert(parsed, chain); err != nil {
+		return nil, nil, err
+	}
+	return &tls.CA{Cert: cert}, chain, nil
+}
+
+// validates the signed certificate against its spec, its private key and its CA
+func (lp *LocalPKI) validateImportedCert(s certificateSpec, certPEM []byte, dir string, keyAlgorithm string) []error {
+	name := s.filename + ".pem"
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return []error{fmt.Errorf("error parsing certificate %q: %v", name, err)}
+	}
+	errs, err := tls.CertValid(s.commonName, s.subjectAlternateNames, s.organizations, s.filename, dir)
+	if err != nil {
+		return []error{err}
+	}
+	if err = lp.warnKeyAlgorithm(keyAlgorithm, s, dir); err != nil {
+		errs = append(errs, err)
+	}
+	key, err := ioutil.ReadFile(filepath.Join(lp.GeneratedCertsDirectory, s.filename+"-key.pem"))
+	if err != nil {
+		return append(errs, fmt.Errorf("Certificate %q: the private key was not found, export the certificate request again: %v", name, err))
+	}
+	priv, err := helpers.ParsePrivateKeyPEM(key)
+	if err != nil {
+		return append(errs, fmt.Errorf("Certificate %q: error parsing private key: %v", name, err))
+	}
+	if !publicKeysEqual(priv.Public(), cert.PublicKey) {
+		errs = append(errs, fmt.Errorf("Certificate %q: the certificate was not signed for the exported request", name))
+	}
+	ca, err := helpers.ParseCertificatePEM(s.ca.Cert)
+	if err != nil {
+		return append(errs, fmt.Errorf("error parsing CA certificate: %v", err))
+	}
+	if err = cert.CheckSignatureFrom(ca); err != nil {
+		errs = append(errs, fmt.Errorf("Certificate %q: the certificate was not signed by CA %q: %v", name, ca.Subject.CommonName, err))
+	}
+	if missing := missingExtKeyUsages(cert, s.usages); len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("Certificate %q: the certificate cannot be used for %s", name, strings.Join(missing, " and ")))
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		errs = append(errs, fmt.Errorf("Certificate %q: the certificate is not valid between %s and %s", name, cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339)))
+	}
+	return errs
+}
+
+// returns the names of the extended key usages that the certificate does not have
+func missingExtKeyUsages(cert *x509.Certificate, usages []x509.ExtKeyUsage) []string {
+	has := map[x509.ExtKeyUsage]bool{}
+	for _, u := range cert.ExtKeyUsage {
+		has[u] = true
+	}
+	missing := []string{}
+	if has[x509.ExtKeyUsageAny] {
+		return missing
+	}
+	for _, u := range usages {
+		if !has[u] {
+			missing = append(missing, extKeyUsageNames[u])
+		}
+	}
+	return missing
+}
@@ -0,0 +1,179 @@
+package install
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+// signs the certificate request of the file with the CA, and writes the
+// certificate next to it
+func signTestRequest(t *testing.T, file string, ca *testCA) {
+	signTestRequestWithUsages(t, file, ca, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth})
+}
+
+func signTestRequestWithUsages(t *testing.T, file string, ca *testCA, usages []x509.ExtKeyUsage) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading certificate request: %v", err)
+	}
+	block, _ := pem.Decode(d)
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing certificate request: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      req.Subject,
+		DNSNames:     req.DNSNames,
+		IPAddresses:  req.IPAddresses,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  usages,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, req.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("error signing certificate request: %v", err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err = ioutil.WriteFile(strings.TrimSuffix(file, certificateRequestExt)+".pem", cert, 0644); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+}
+
+func TestExportAndImportCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	dir, err := ioutil.TempDir("", "csrs")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	p := getPlan()
+
+	exported, err := pki.ExportCertificateRequests(p, dir)
+	if err != nil {
+		t.Fatalf("unexpected error exporting requests: %v", err)
+	}
+	manifest, err := p.certSpecs(nil, nil)
+	if err != nil {
+		t.Fatalf("error getting cert specs: %v", err)
+	}
+	if len(exported) != len(manifest) {
+		t.Errorf("expected %d requests, got %d", len(manifest), len(exported))
+	}
+	key, err := ioutil.ReadFile(filepath.Join(pki.GeneratedCertsDirectory, "admin-key.pem"))
+	if err != nil {
+		t.Fatalf("expected the private key to be kept: %v", err)
+	}
+	// the keys are reused when exporting again
+	if _, err = pki.ExportCertificateRequests(p, dir); err != nil {
+		t.Fatalf("unexpected error exporting requests again: %v", err)
+	}
+	again, err := ioutil.ReadFile(filepath.Join(pki.GeneratedCertsDirectory, "admin-key.pem"))
+	if err != nil || string(again) != string(key) {
+		t.Errorf("expected the private key to be reused")
+	}
+
+	root := newTestCA(t, "root", nil)
+	clusterCA := newTestCA(t, "cluster", root)
+	proxyClientCA := newTestCA(t, "proxy-client", nil)
+	for _, name := range exported {
+		ca := clusterCA
+		if name == proxyClientCertFilename+certificateRequestExt {
+			ca = proxyClientCA
+		}
+		signTestRequest(t, filepath.Join(dir, name), ca)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "ca.pem"), append(append([]byte{}, clusterCA.certPEM...), root.certPEM...), 0644); err != nil {
+		t.Fatalf("error writing cluster CA: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "proxy-client-ca.pem"), proxyClientCA.certPEM, 0644); err != nil {
+		t.Fatalf("error writing proxy-client CA: %v", err)
+	}
+
+	// a certificate signed by the wrong CA is not imported
+	signTestRequest(t, filepath.Join(dir, "admin.csr"), proxyClientCA)
+	if _, errs := pki.ImportCertificates(p, dir); len(errs) != 1 {
+		t.Fatalf("expected one validation error, got %v", errs)
+	}
+	if exists, _ := tls.CertKeyPairExists("worker01-kubelet", pki.GeneratedCertsDirectory); exists {
+		t.Errorf("expected no certificate to be imported")
+	}
+	signTestRequest(t, filepath.Join(dir, "admin.csr"), clusterCA)
+
+	// a server certificate that cannot be used for server authentication is not imported
+	apiServer := filepath.Join(dir, "master01-apiserver.csr")
+	signTestRequestWithUsages(t, apiServer, clusterCA, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	if _, errs := pki.ImportCertificates(p, dir); len(errs) != 1 || !strings.Contains(errs[0].Error(), "server auth") {
+		t.Fatalf("expected an error about the server auth usage, got %v", errs)
+	}
+	signTestRequest(t, apiServer, clusterCA)
+
+	imported, errs := pki.ImportCertificates(p, dir)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors importing certificates: %v", errs)
+	}
+	if len(imported) != len(manifest) {
+		t.Errorf("expected %d certificates to be imported, got %d", len(manifest), len(imported))
+	}
+	if clusterCATrustFile(pki.GeneratedCertsDirectory) != filepath.Join(pki.GeneratedCertsDirectory, caChainFilename) {
+		t.Errorf("expected the CA chain to be trusted")
+	}
+
+	// the cluster is installed without the keys of the CAs
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error getting cluster CA: %v", err)
+	}
+	if len(ca.Key) != 0 || string(ca.Cert) != string(clusterCA.certPEM) {
+		t.Errorf("expected the imported cluster CA, without key")
+	}
+	proxyCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error getting proxy-client CA: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyCA); err != nil {
+		t.Errorf("unexpected error with the imported certificates: %v", err)
+	}
+	if exists, _ := pki.CertificateAuthorityExists(); !exists {
+		t.Errorf("expected the imported CA to exist")
+	}
+
+	// certificates that were not imported cannot be generated
+	os.Remove(filepath.Join(pki.GeneratedCertsDirectory, "admin.pem"))
+	err = pki.GenerateClusterCertificates(p, ca, proxyCA)
+	if err == nil || !strings.Contains(err.Error(), "export-csrs") {
+		t.Errorf("expected an error pointing to export-csrs, got %v", err)
+	}
+}
+
+func TestImportCertificatesRejectsClusterCAAsProxyClientCA(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	dir, err := ioutil.TempDir("", "csrs")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCA(t, "cluster", nil)
+	if err = ioutil.WriteFile(filepath.Join(dir, "ca.pem"), ca.certPEM, 0644); err != nil {
+		t.Fatalf("error writing cluster CA: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "proxy-client-ca.pem"), ca.certPEM, 0644); err != nil {
+		t.Fatalf("error writing proxy-client CA: %v", err)
+	}
+	if _, errs := pki.ImportCertificates(getPlan(), dir); len(errs) != 1 {
+		t.Errorf("expected an error, got %v", errs)
+	}
+}
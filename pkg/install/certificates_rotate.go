@@ -0,0 +1,182 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+const (
+	certificateComponentEtcd              = "etcd"
+	certificateComponentAPIServer         = "kube-apiserver"
+	certificateComponentControllerManager = "kube-controller-manager"
+	certificateComponentScheduler         = "kube-scheduler"
+	certificateComponentKubelet           = "kubelet"
+	certificateComponentAdmin             = "admin"
+)
+
+// CertificateComponents returns the components whose certificates can be rotated,
+// in the order they are restarted. The service account and CA certificates are
+// not rotated with the other certificates.
+func CertificateComponents() []string {
+	return []string{
+		certificateComponentEtcd,
+		certificateComponentAPIServer,
+		certificateComponentControllerManager,
+		certificateComponentScheduler,
+		certificateComponentKubelet,
+		certificateComponentAdmin,
+	}
+}
+
+// ValidateCertificateComponents returns an error if one of the components
+// is not a component whose certificates can be rotated
+func ValidateCertificateComponents(components []string) error {
+	if len(components) == 0 {
+		return fmt.Errorf("at least one component must be selected")
+	}
+	for _, c := range components {
+		if !util.Contains(c, CertificateComponents()) {
+			return fmt.Errorf("%q is not a valid component, options are %v", c, CertificateComponents())
+		}
+	}
+	return nil
+}
+
+// returns the component that uses the certificate, or an empty string if the
+// certificate is not rotated with a component
+func certificateComponent(spec certificateSpec) string {
+	switch {
+	case spec.filename == "etcd-client" || strings.HasSuffix(spec.filename, "-etcd"):
+		return certificateComponentEtcd
+	case strings.HasSuffix(spec.filename, "-apiserver"),
+		spec.filename == kubeAPIServerKubeletClientClientFilename,
+		spec.filename == proxyClientCertFilename:
+		return certificateComponentAPIServer
+	case spec.filename == controllerManagerCertFilenamePrefix:
+		return certificateComponentControllerManager
+	case spec.filename == schedulerCertFilenamePrefix:
+		return certificateComponentScheduler
+	case strings.HasSuffix(spec.filename, "-kubelet"):
+		return certificateComponentKubelet
+	case spec.filename == adminCertFilename:
+		return certificateComponentAdmin
+	default:
+		return ""
+	}
+}
+
+// rotatedCertificateSpecs returns the specs of the certificates used by the components
+func rotatedCertificateSpecs(p Plan, components []string, clusterCA *tls.CA, proxyClientCA *tls.CA) ([]certificateSpec, error) {
+	specs, err := p.certSpecs(clusterCA, proxyClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("error building certificate specs: %v", err)
+	}
+	rotated := []certificateSpec{}
+	for _, s := range specs {
+		if util.Contains(certificateComponent(s), components) {
+			rotated = append(rotated, s)
+		}
+	}
+	return rotated, nil
+}
+
+// RotateCertificates regenerates the certificates used by the components, signed
+// by the existing certificate authorities, and returns the names of the certificates
+// that were regenerated
+func (lp *LocalPKI) RotateCertificates(p *Plan, components []string, clusterCA *tls.CA, proxyClientCA *tls.CA) ([]string, error) {
+	if lp.Log == nil {
+		lp.Log = ioutil.Discard
+	}
+	specs, err := rotatedCertificateSpecs(*p, components, clusterCA, proxyClientCA)
+	if err != nil {
+		return nil, err
+	}
+	rotated := []string{}
+	for _, s := range specs {
+		if err := generateCert(lp.GeneratedCertsDirectory, s, p.Cluster.Certificates); err != nil {
+			return rotated, err
+		}
+		util.PrettyPrintOk(lp.Log, "Rotated %s certificate", s.description)
+		rotated = append(rotated, s.filename)
+	}
+	return rotated, nil
+}
+
+// certificateRotationRestarts returns the services that are restarted to load the
+// certificates of the components. The API server also uses the etcd client certificate.
+func certificateRotationRestarts(components []string) []string {
+	restarts := []string{}
+	for _, c := range CertificateComponents() {
+		if !util.Contains(c, components) {
+			continue
+		}
+		switch c {
+		case certificateComponentAdmin:
+			// the admin certificate is only used by kubectl
+		case certificateComponentEtcd:
+			restarts = append(restarts, certificateComponentEtcd)
+			if !util.Contains(certificateComponentAPIServer, components) {
+				restarts = append(restarts, certificateComponentAPIServer)
+			}
+		default:
+			restarts = append(restarts, c)
+		}
+	}
+	return restarts
+}
+
+// RotateCertificates regenerates the certificates of the components from the
+// existing CA, and deploys them to the nodes one at a time. The components are
+// restarted in order, and the cluster must be healthy before moving to the next node.
+func (ae *ansibleExecutor) RotateCertificates(plan Plan, components []string) error {
+	if err := ValidateCertificateComponents(components); err != nil {
+		return err
+	}
+	clusterCA, err := ae.pki.GetClusterCA()
+	if err != nil {
+		return err
+	}
+	proxyClientCA, err := ae.pki.GetProxyClientCA()
+	if err != nil {
+		return err
+	}
+	util.PrintHeader(ae.stdout, "Rotate Certificates", '=')
+	if _, err = ae.pki.RotateCertificates(&plan, components, clusterCA, proxyClientCA); err != nil {
+		return fmt.Errorf("error rotating certificates: %v", err)
+	}
+	if util.Contains(certificateComponentAdmin, components) {
+		if _, err = RegenerateKubeconfig(&plan, ae.options.GeneratedAssetsDirectory); err != nil {
+			return fmt.Errorf("error regenerating kubeconfig: %v", err)
+		}
+		util.PrettyPrintOk(ae.stdout, "Regenerated the admin kubeconfig")
+	}
+
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.CertificateRotationRestarts = certificateRotationRestarts(components)
+	// the unique nodes are listed etcd nodes first, then master nodes
+	nodes := plan.GetUniqueNodes()
+	for i, n := range nodes {
+		util.PrintHeader(ae.stdout, fmt.Sprintf("Deploy Certificates (%d/%d): %s", i+1, len(nodes), n.Host), '=')
+		t := task{
+			name:           "certificates-rotate",
+			playbook:       "certificates-rotate.yaml",
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			plan:           plan,
+			explainer:      ae.defaultExplainer(),
+			limit:          []string{n.Host},
+		}
+		if err = ae.execute(t); err != nil {
+			return fmt.Errorf("error deploying certificates to node %s, stopping the rollout: %v", n.Host, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package install
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("error generating certificates for test: %v", err)
+	}
+	before, err := ListCertificates(pki.GeneratedCertsDirectory, time.Hour, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rotated, err := pki.RotateCertificates(p, []string{"kubelet", "kube-scheduler"}, ca, proxyClientCA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"master01-kubelet", "worker01-kubelet", "ingress01-kubelet", "storage01-kubelet", "kube-scheduler"}
+	for _, e := range expected {
+		found := false
+		for _, r := range rotated {
+			found = found || r == e
+		}
+		if !found {
+			t.Errorf("expected certificate %q to be rotated, rotated %v", e, rotated)
+		}
+	}
+
+	after, err := ListCertificates(pki.GeneratedCertsDirectory, time.Hour, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("expected %d certificates, got %d", len(before), len(after))
+	}
+	for i, c := range after {
+		changed := c.Fingerprint != before[i].Fingerprint
+		isRotated := strings.HasSuffix(c.Name, "-kubelet") || c.Name == "kube-scheduler"
+		if changed != isRotated {
+			t.Errorf("certificate %q: expected rotated to be %v, got %v", c.Name, isRotated, changed)
+		}
+		if isRotated && c.Issuer != before[i].Issuer {
+			t.Errorf("certificate %q: expected to be issued by %q, got %q", c.Name, before[i].Issuer, c.Issuer)
+		}
+	}
+}
+
+func TestCertificateRotationRestarts(t *testing.T) {
+	tests := []struct {
+		components []string
+		expected   []string
+	}{
+		{
+			components: []string{"admin"},
+			expected:   []string{},
+		},
+		{
+			components: []string{"kubelet", "etcd"},
+			expected:   []string{"etcd", "kube-apiserver", "kubelet"},
+		},
+		{
+			components: CertificateComponents(),
+			expected:   []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kubelet"},
+		},
+	}
+	for _, test := range tests {
+		restarts := certificateRotationRestarts(test.components)
+		if !reflect.DeepEqual(restarts, test.expected) {
+			t.Errorf("components %v: expected restarts %v, got %v", test.components, test.expected, restarts)
+		}
+	}
+}
+
+func TestValidateCertificateComponents(t *testing.T) {
+	if err := ValidateCertificateComponents([]string{"etcd", "kubelet"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateCertificateComponents([]string{"service-account"}); err == nil {
+		t.Errorf("expected an error for an invalid component")
+	}
+	if err := ValidateCertificateComponents(nil); err == nil {
+		t.Errorf("expected an error when no component is selected")
+	}
+}
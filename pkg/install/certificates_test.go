@@ -0,0 +1,118 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	ca, err := pki.GenerateClusterCA(getPlan())
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	if _, err := pki.GenerateCertificate("short-lived", CertsConfig{Expiry: "1h"}, "short-lived", []string{"foo.example.com", "10.0.0.1"}, nil, ca, false); err != nil {
+		t.Fatalf("error generating certificate: %v", err)
+	}
+	if _, err := pki.GenerateCertificate("long-lived", CertsConfig{Expiry: "1000h"}, "long-lived", nil, nil, ca, false); err != nil {
+		t.Fatalf("error generating certificate: %v", err)
+	}
+
+	certs, err := ListCertificates(pki.GeneratedCertsDirectory, 24*time.Hour, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byName := map[string]CertificateInfo{}
+	for _, c := range certs {
+		byName[c.Name] = c
+	}
+	if len(certs) != 3 {
+		t.Fatalf("expected the CA and 2 certificates, but got %v", certs)
+	}
+	shortLived := byName["short-lived"]
+	if !shortLived.Expiring || shortLived.Expired {
+		t.Errorf("expected short-lived certificate to be expiring, but got %+v", shortLived)
+	}
+	if shortLived.Subject == "" || shortLived.Issuer == "" || shortLived.Fingerprint == "" {
+		t.Errorf("expected the subject, issuer and fingerprint to be set, but got %+v", shortLived)
+	}
+	if len(shortLived.SANs) != 2 {
+		t.Errorf("expected 2 SANs, but got %v", shortLived.SANs)
+	}
+	if byName["long-lived"].Expiring {
+		t.Errorf("expected long-lived certificate not to be expiring")
+	}
+
+	// all the certificates have expired in a year
+	certs, err = ListCertificates(pki.GeneratedCertsDirectory, 0, time.Now().Add(24*365*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range certs {
+		if c.Name != "ca" && !c.Expired {
+			t.Errorf("expected certificate %q to have expired", c.Name)
+		}
+	}
+}
+
+func TestCheckDeployedCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	dir := pki.GeneratedCertsDirectory
+	ca, err := pki.GenerateClusterCA(getPlan())
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	for _, name := range []string{"etcd01-etcd", "etcd-client"} {
+		if _, err := pki.GenerateCertificate(name, CertsConfig{Expiry: "1000h"}, name, nil, nil, ca, false); err != nil {
+			t.Fatalf("error generating certificate: %v", err)
+		}
+	}
+	plan := Plan{
+		Etcd: NodeGroup{Nodes: []Node{{Host: "etcd01", IP: "10.0.0.1"}}},
+	}
+	certs, err := ListCertificates(dir, 0, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := map[string]string{
+		"/etc/etcd_k8s/ca.pem":   "ca.pem",
+		"/etc/etcd_k8s/etcd.pem": "etcd-client.pem", // the wrong certificate was deployed
+	}
+	run := func(host string, cmd string) (string, error) {
+		for path, file := range files {
+			if cmd == "sudo cat "+path {
+				b, err := ioutil.ReadFile(filepath.Join(dir, file))
+				return string(b), err
+			}
+		}
+		return "cat: No such file or directory", errors.New("exit status 1")
+	}
+	certs = checkDeployedCertificates(plan, certs, run)
+
+	deployed := map[string]DeployedCertificate{}
+	for _, c := range certs {
+		for _, d := range c.Deployed {
+			deployed[d.Path] = d
+		}
+	}
+	if len(deployed) != 3 {
+		t.Fatalf("expected 3 deployed certificates, but got %v", deployed)
+	}
+	if d := deployed["/etc/etcd_k8s/ca.pem"]; !d.Match || d.Error != "" {
+		t.Errorf("expected the CA to match, but got %+v", d)
+	}
+	if d := deployed["/etc/etcd_k8s/etcd.pem"]; d.Match || d.Error != "" {
+		t.Errorf("expected the etcd certificate not to match, but got %+v", d)
+	}
+	if d := deployed["/etc/etcd_k8s/etcd-client.pem"]; d.Match || d.Error == "" {
+		t.Errorf("expected the missing etcd client certificate to have an error, but got %+v", d)
+	}
+	if len(MismatchedCertificates(certs)) != 2 {
+		t.Errorf("expected 2 mismatched certificates, but got %v", MismatchedCertificates(certs))
+	}
+}
@@ -0,0 +1,248 @@
+package install
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	encryptionConfigFilename     = "encryption-config.yaml"
+	encryptionProviderAESCBC     = "aescbc"
+	encryptionProviderSecretbox  = "secretbox"
+	encryptionProviderConfigFlag = "encryption-provider-config"
+	encryptionKeyNameTimeFormat  = "20060102150405"
+	// both aescbc and secretbox use 32 byte keys
+	encryptionKeyLength = 32
+)
+
+func encryptionProviders() []string {
+	return []string{encryptionProviderAESCBC, encryptionProviderSecretbox}
+}
+
+// the EncryptionConfiguration consumed by the API server
+type encryptionConfig struct {
+	APIVersion string                     `yaml:"apiVersion"`
+	Kind       string                     `yaml:"kind"`
+	Resources  []encryptionResourceConfig `yaml:"resources"`
+}
+
+type encryptionResourceConfig struct {
+	Resources []string             `yaml:"resources"`
+	Providers []encryptionProvider `yaml:"providers"`
+}
+
+type encryptionProvider struct {
+	AESCBC    *encryptionKeys `yaml:"aescbc,omitempty"`
+	Secretbox *encryptionKeys `yaml:"secretbox,omitempty"`
+	Identity  *struct{}       `yaml:"identity,omitempty"`
+}
+
+type encryptionKeys struct {
+	Keys []encryptionKey `yaml:"keys"`
+}
+
+type encryptionKey struct {
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
+}
+
+// encryptionProviderKey is a key, along with the provider it is used by
+type encryptionProviderKey struct {
+	provider string
+	key      encryptionKey
+}
+
+// EncryptionConfigFile returns the path of the encryption configuration
+// of the API servers in the generated assets directory
+func EncryptionConfigFile(generatedAssetsDir string) string {
+	return filepath.Join(generatedAssetsDir, "keys", encryptionConfigFilename)
+}
+
+// GenerateEncryptionConfig writes the encryption configuration of the API servers
+// to the generated assets directory, when encryption at rest is enabled.
+// The keys of an existing configuration are never replaced. When encryption at rest
+// is disabled, the existing keys are kept so that the secrets that are encrypted
+// can still be read, but the new secrets are stored unencrypted.
+func GenerateEncryptionConfig(p *Plan, generatedAssetsDir string) error {
+	file := EncryptionConfigFile(generatedAssetsDir)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		if !p.Cluster.EncryptionAtRest.Enabled {
+			return nil
+		}
+		key, err := newEncryptionKey(p.Cluster.EncryptionAtRest.Provider)
+		if err != nil {
+			return err
+		}
+		return writeEncryptionConfig(file, []encryptionProviderKey{key}, false)
+	}
+	keys, err := readEncryptionConfig(file)
+	if err != nil {
+		return err
+	}
+	return writeEncryptionConfig(file, keys, !p.Cluster.EncryptionAtRest.Enabled)
+}
+
+// encryptionKeyRotation returns the keys that are rolled out to the API servers,
+// one stage after the other, to replace the current keys with the new key.
+// The new key is first added as a decryption key, so that all the API servers
+// can read the secrets it encrypts before any of them starts using it. It is then
+// made the encryption key, and the previous keys are removed once all the
+// secrets have been rewritten.
+func encryptionKeyRotation(current []encryptionProviderKey, newKey encryptionProviderKey) [][]encryptionProviderKey {
+	added := append(append([]encryptionProviderKey{}, current...), newKey)
+	promoted := append([]encryptionProviderKey{newKey}, current...)
+	return [][]encryptionProviderKey{added, promoted, {newKey}}
+}
+
+func newEncryptionKey(provider string) (encryptionProviderKey, error) {
+	if provider == "" {
+		provider = encryptionProviderAESCBC
+	}
+	secret := make([]byte, encryptionKeyLength)
+	if _, err := rand.Read(secret); err != nil {
+		return encryptionProviderKey{}, fmt.Errorf("error generating encryption key: %v", err)
+	}
+	return encryptionProviderKey{
+		provider: provider,
+		key: encryptionKey{
+			Name:   "key-" + time.Now().UTC().Format(encryptionKeyNameTimeFormat),
+			Secret: base64.StdEncoding.EncodeToString(secret),
+		},
+	}, nil
+}
+
+// builds the encryption configuration with the given keys. The first key
+// encrypts the secrets, unless identityFirst is set, in which case the secrets
+// are stored unencrypted.
+func buildEncryptionConfig(keys []encryptionProviderKey, identityFirst bool) encryptionConfig {
+	providers := []encryptionProvider{}
+	if identityFirst {
+		providers = append(providers, encryptionProvider{Identity: &struct{}{}})
+	}
+	for i := 0; i < len(keys); {
+		// consecutive keys of the same provider are listed under the same provider
+		group := encryptionKeys{}
+		provider := keys[i].provider
+		for ; i < len(keys) && keys[i].provider == provider; i++ {
+			group.Keys = append(group.Keys, keys[i].key)
+		}
+		switch provider {
+		case encryptionProviderSecretbox:
+			providers = append(providers, encryptionProvider{Secretbox: &group})
+		default:
+			providers = append(providers, encryptionProvider{AESCBC: &group})
+		}
+	}
+	// secrets that were stored before encryption was enabled are still readable
+	if !identityFirst {
+		providers = append(providers, encryptionProvider{Identity: &struct{}{}})
+	}
+	return encryptionConfig{
+		APIVersion: "apiserver.config.k8s.io/v1",
+		Kind:       "EncryptionConfiguration",
+		Resources: []encryptionResourceConfig{
+			{
+				Resources: []string{"secrets"},
+				Providers: providers,
+			},
+		},
+	}
+}
+
+func writeEncryptionConfig(file string, keys []encryptionProviderKey, identityFirst bool) error {
+	d, err := yaml.Marshal(buildEncryptionConfig(keys, identityFirst))
+	if err != nil {
+		return fmt.Errorf("error marshaling encryption configuration: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("error creating directory for the encryption configuration: %v", err)
+	}
+	if err := ioutil.WriteFile(file, d, 0600); err != nil {
+		return fmt.Errorf("error writing encryption configuration to %q: %v", file, err)
+	}
+	return nil
+}
+
+// returns the keys of the encryption configuration, in the order they are
+// tried by the API server
+func readEncryptionConfig(file string) ([]encryptionProviderKey, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption configuration: %v", err)
+	}
+	config := encryptionConfig{}
+	if err := yaml.Unmarshal(d, &config); err != nil {
+		return nil, fmt.Errorf("error unmarshaling encryption configuration %q: %v", file, err)
+	}
+	keys := []encryptionProviderKey{}
+	for _, r := range config.Resources {
+		for _, p := range r.Providers {
+			if p.AESCBC != nil {
+				for _, k := range p.AESCBC.Keys {
+					keys = append(keys, encryptionProviderKey{provider: encryptionProviderAESCBC, key: k})
+				}
+			}
+			if p.Secretbox != nil {
+				for _, k := range p.Secretbox.Keys {
+					keys = append(keys, encryptionProviderKey{provider: encryptionProviderSecretbox, key: k})
+				}
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the encryption configuration %q does not contain any keys", file)
+	}
+	return keys, nil
+}
+
+// RotateEncryptionKey replaces the key that encrypts the secrets with a new key,
+// and rewrites all the secrets with the new key
+func (ae *ansibleExecutor) RotateEncryptionKey(plan Plan) error {
+	if !plan.Cluster.EncryptionAtRest.Enabled {
+		return errors.New("encryption at rest is not enabled in the plan file")
+	}
+	file := EncryptionConfigFile(ae.options.GeneratedAssetsDirectory)
+	current, err := readEncryptionConfig(file)
+	if err != nil {
+		return err
+	}
+	newKey, err := newEncryptionKey(plan.Cluster.EncryptionAtRest.Provider)
+	if err != nil {
+		return err
+	}
+	stages := encryptionKeyRotation(current, newKey)
+	headers := []string{"Add Encryption Key", "Encrypt Secrets With New Key", "Remove Previous Encryption Keys"}
+	for i, keys := range stages {
+		if err := writeEncryptionConfig(file, keys, false); err != nil {
+			return err
+		}
+		cc, err := ae.buildClusterCatalog(&plan)
+		if err != nil {
+			return err
+		}
+		// the secrets are rewritten once all the API servers encrypt with the new key
+		cc.RewriteSecrets = i == 1
+		t := task{
+			name:           "secrets-rotate-key",
+			playbook:       "secrets-encryption-config.yaml",
+			plan:           plan,
+			inventory:      buildInventoryFromPlan(&plan),
+			clusterCatalog: *cc,
+			explainer:      ae.defaultExplainer(),
+		}
+		util.PrintHeader(ae.stdout, headers[i], '=')
+		if err := ae.execute(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateEncryptionConfig(t *testing.T) {
+	dir := mustGetTempDir(t)
+	defer os.RemoveAll(dir)
+	file := EncryptionConfigFile(dir)
+	p := &Plan{}
+
+	// encryption is disabled, and was never enabled
+	if err := GenerateEncryptionConfig(p, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("expected no encryption configuration to be generated, but got %v", err)
+	}
+
+	p.Cluster.EncryptionAtRest = EncryptionAtRest{Enabled: true, Provider: "secretbox"}
+	if err := GenerateEncryptionConfig(p, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := readEncryptionConfig(file)
+	if err != nil {
+		t.Fatalf("error reading the encryption configuration: %v", err)
+	}
+	if len(keys) != 1 || keys[0].provider != "secretbox" || keys[0].key.Secret == "" {
+		t.Fatalf("expected a secretbox key, but got %v", keys)
+	}
+
+	// the existing key is kept
+	if err := GenerateEncryptionConfig(p, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	regenerated, err := readEncryptionConfig(file)
+	if err != nil {
+		t.Fatalf("error reading the encryption configuration: %v", err)
+	}
+	if !reflect.DeepEqual(keys, regenerated) {
+		t.Errorf("expected the keys %v to be kept, but got %v", keys, regenerated)
+	}
+
+	// the key is kept for reading the secrets once encryption is disabled
+	p.Cluster.EncryptionAtRest.Enabled = false
+	if err := GenerateEncryptionConfig(p, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading the encryption configuration: %v", err)
+	}
+	expected := `apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+- resources:
+  - secrets
+  providers:
+  - identity: {}
+  - secretbox:
+      keys:
+      - name: ` + keys[0].key.Name + `
+        secret: ` + keys[0].key.Secret + `
+`
+	if string(d) != expected {
+		t.Errorf("expected encryption configuration:\n%s\nbut got:\n%s", expected, string(d))
+	}
+}
+
+func TestBuildEncryptionConfigGroupsKeysByProvider(t *testing.T) {
+	dir := mustGetTempDir(t)
+	defer os.RemoveAll(dir)
+	file := EncryptionConfigFile(dir)
+	keys := []encryptionProviderKey{
+		{provider: "aescbc", key: encryptionKey{Name: "key-3", Secret: "c"}},
+		{provider: "secretbox", key: encryptionKey{Name: "key-2", Secret: "b"}},
+		{provider: "secretbox", key: encryptionKey{Name: "key-1", Secret: "a"}},
+	}
+	config := buildEncryptionConfig(keys, false)
+	providers := config.Resources[0].Providers
+	if len(providers) != 3 {
+		t.Fatalf("expected 3 providers, but got %d", len(providers))
+	}
+	if providers[0].AESCBC == nil || len(providers[0].AESCBC.Keys) != 1 {
+		t.Errorf("expected the first provider to be aescbc with one key, but got %+v", providers[0])
+	}
+	if providers[1].Secretbox == nil || len(providers[1].Secretbox.Keys) != 2 {
+		t.Errorf("expected the second provider to be secretbox with two keys, but got %+v", providers[1])
+	}
+	if providers[2].Identity == nil {
+		t.Errorf("expected the last provider to be identity, but got %+v", providers[2])
+	}
+
+	if err := writeEncryptionConfig(file, keys, false); err != nil {
+		t.Fatalf("error writing the encryption configuration: %v", err)
+	}
+	read, err := readEncryptionConfig(file)
+	if err != nil {
+		t.Fatalf("error reading the encryption configuration: %v", err)
+	}
+	if !reflect.DeepEqual(keys, read) {
+		t.Errorf("expected keys %v, but got %v", keys, read)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	old := encryptionProviderKey{provider: "aescbc", key: encryptionKey{Name: "key-1", Secret: "a"}}
+	newKey := encryptionProviderKey{provider: "aescbc", key: encryptionKey{Name: "key-2", Secret: "b"}}
+	stages := encryptionKeyRotation([]encryptionProviderKey{old}, newKey)
+	expected := [][]encryptionProviderKey{
+		{old, newKey},
+		{newKey, old},
+		{newKey},
+	}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Errorf("expected stages %v, but got %v", expected, stages)
+	}
+}
@@ -0,0 +1,398 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	etcdBackupsDirName       = "etcd-backups"
+	etcdBackupFile           = "backup.yaml"
+	etcdBackupChecksumFile   = "etcd-snapshot.db.sha256"
+	etcdBackupMemberFile     = "member"
+	etcdBackupNameTimeFormat = "2006-01-02-15-04-05"
+	// EtcdBackupSnapshotFile is the name of the etcd snapshot in the backup directory
+	EtcdBackupSnapshotFile = "etcd-snapshot.db"
+)
+
+// EtcdBackup is a v3 snapshot of the Kubernetes etcd cluster, stored locally
+type EtcdBackup struct {
+	Name              string    `yaml:"name"`
+	CreatedAt         time.Time `yaml:"createdAt"`
+	ClusterName       string    `yaml:"clusterName"`
+	KismaticVersion   string    `yaml:"kismaticVersion"`
+	KubernetesVersion string    `yaml:"kubernetesVersion"`
+	// Member is the etcd node the snapshot was taken from
+	Member string `yaml:"member"`
+	SHA256 string `yaml:"sha256"`
+	Size   int64  `yaml:"size"`
+	// Directory where the backup is stored
+	Directory string `yaml:"-"`
+}
+
+// EtcdBackupRetention determines the backups that are kept. Backups beyond
+// the Keep most recent ones, or older than MaxAge, are removed. A zero value
+// disables the corresponding limit.
+type EtcdBackupRetention struct {
+	Keep   int
+	MaxAge time.Duration
+}
+
+// SnapshotFile returns the path to the etcd snapshot of the backup
+func (b EtcdBackup) SnapshotFile() string {
+	return filepath.Join(b.Directory, EtcdBackupSnapshotFile)
+}
+
+// EtcdBackupsDir returns the default directory where etcd backups are stored
+func EtcdBackupsDir(generatedAssetsDir string) string {
+	return filepath.Join(generatedAssetsDir, etcdBackupsDirName)
+}
+
+// NewEtcdBackup creates the directory of a new backup of the cluster. The snapshot
+// is taken by the executor, and the backup is completed with FinalizeEtcdBackup.
+func NewEtcdBackup(plan Plan, backupsDir string) (*EtcdBackup, error) {
+	now := time.Now()
+	b := &EtcdBackup{
+		Name:              now.Format(etcdBackupNameTimeFormat),
+		CreatedAt:         now,
+		ClusterName:       plan.Cluster.Name,
+		KismaticVersion:   KismaticVersion.String(),
+		KubernetesVersion: plan.Cluster.Version,
+	}
+	dir, err := filepath.Abs(filepath.Join(backupsDir, b.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine absolute path to %s: %v", backupsDir, err)
+	}
+	b.Directory = dir
+	if err := os.MkdirAll(b.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("error creating backup directory: %v", err)
+	}
+	return b, nil
+}
+
+// FinalizeEtcdBackup verifies the checksum of the snapshot that was fetched from
+// the etcd member, and writes the metadata of the backup
+func FinalizeEtcdBackup(b *EtcdBackup) error {
+	sum, size, err := sha256File(b.SnapshotFile())
+	if err != nil {
+		return err
+	}
+	// the checksum file is in the "sha256sum" format
+	checksum, err := ioutil.ReadFile(filepath.Join(b.Directory, etcdBackupChecksumFile))
+	if err != nil {
+		return fmt.Errorf("error reading snapshot checksum: %v", err)
+	}
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 || fields[0] != sum {
+		return fmt.Errorf("the checksum of the snapshot %q does not match the checksum computed on the etcd member", b.SnapshotFile())
+	}
+	member, err := ioutil.ReadFile(filepath.Join(b.Directory, etcdBackupMemberFile))
+	if err != nil {
+		return fmt.Errorf("error reading the etcd member of the snapshot: %v", err)
+	}
+	b.Member = strings.TrimSpace(string(member))
+	b.SHA256 = sum
+	b.Size = size
+	by, err := yaml.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("error marshalling backup: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.Directory, etcdBackupFile), by, 0600); err != nil {
+		return fmt.Errorf("error writing backup: %v", err)
+	}
+	return nil
+}
+
+// VerifyEtcdBackup returns an error if the snapshot of the backup does not match its checksum
+func VerifyEtcdBackup(b EtcdBackup) error {
+	sum, _, err := sha256File(b.SnapshotFile())
+	if err != nil {
+		return err
+	}
+	if sum != b.SHA256 {
+		return fmt.Errorf("the checksum of the snapshot %q does not match the checksum recorded in the backup", b.SnapshotFile())
+	}
+	return nil
+}
+
+func sha256File(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, fmt.Errorf("error opening snapshot: %v", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading snapshot: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// ListEtcdBackups returns the backups stored in the directory, the most recent first.
+// Directories of backups that did not complete are ignored.
+func ListEtcdBackups(backupsDir string) ([]EtcdBackup, error) {
+	entries, err := ioutil.ReadDir(backupsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backups directory %q: %v", backupsDir, err)
+	}
+	backups := []EtcdBackup{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(backupsDir, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, etcdBackupFile)); os.IsNotExist(err) {
+			continue
+		}
+		b, err := readEtcdBackup(dir)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// GetEtcdBackup returns the backup with the given name. If the name
+// is empty, the most recent backup is returned.
+func GetEtcdBackup(backupsDir string, name string) (*EtcdBackup, error) {
+	if name != "" {
+		return readEtcdBackup(filepath.Join(backupsDir, name))
+	}
+	backups, err := ListEtcdBackups(backupsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no etcd backups found in %q", backupsDir)
+	}
+	return &backups[0], nil
+}
+
+func readEtcdBackup(dir string) (*EtcdBackup, error) {
+	file := filepath.Join(dir, etcdBackupFile)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading etcd backup %q: %v", file, err)
+	}
+	var backup EtcdBackup
+	if err := yaml.Unmarshal(b, &backup); err != nil {
+		return nil, fmt.Errorf("error unmarshalling etcd backup %q: %v", file, err)
+	}
+	backup.Directory = dir
+	return &backup, nil
+}
+
+// PruneEtcdBackups removes the backups in the directory that are not kept by the
+// retention, and returns the backups that were removed
+func PruneEtcdBackups(backupsDir string, retention EtcdBackupRetention, now time.Time) ([]EtcdBackup, error) {
+	backups, err := ListEtcdBackups(backupsDir)
+	if err != nil {
+		return nil, err
+	}
+	removed := []EtcdBackup{}
+	for i, b := range backups {
+		tooMany := retention.Keep > 0 && i >= retention.Keep
+		tooOld := retention.MaxAge > 0 && now.Sub(b.CreatedAt) > retention.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.RemoveAll(b.Directory); err != nil {
+			return removed, fmt.Errorf("error removing etcd backup %q: %v", b.Directory, err)
+		}
+		removed = append(removed, b)
+	}
+	return removed, nil
+}
+
+// BackupEtcd takes a v3 snapshot of the Kubernetes etcd cluster from a healthy member,
+// and fetches it to the directory of the backup. Snapshots on the etcd nodes are
+// pruned according to the retention.
+func (ae *ansibleExecutor) BackupEtcd(plan Plan, backup EtcdBackup, retention EtcdBackupRetention) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.EtcdBackupName = backup.Name
+	cc.EtcdBackupDirectory = backup.Directory
+	cc.EtcdBackupKeep = retention.Keep
+	cc.EtcdBackupMaxAgeMinutes = int(retention.MaxAge.Minutes())
+	t := task{
+		name:           "etcd-backup",
+		playbook:       "etcd-backup.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, "Backup Etcd", '=')
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error backing up etcd: %v", err)
+	}
+	return nil
+}
+
+// EtcdBackupScheduleNone is the schedule that removes the timer of the etcd backups
+const EtcdBackupScheduleNone = "none"
+
+// the shorthands of the systemd calendar events
+var calendarShorthands = []string{"minutely", "hourly", "daily", "weekly", "monthly", "yearly", "annually", "quarterly", "semiannually"}
+
+var calendarWeekdays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// ValidateEtcdBackupSchedule returns an error if the schedule is not EtcdBackupScheduleNone,
+// or a systemd calendar event in the "[weekdays] [[year-]month-day] [hour:minute[:second]]"
+// format, optionally followed by a time zone, or one of its shorthands such as "daily".
+func ValidateEtcdBackupSchedule(schedule string) error {
+	if schedule == EtcdBackupScheduleNone || util.Contains(strings.ToLower(schedule), calendarShorthands) {
+		return nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) == 5 && !strings.ContainsAny(schedule, ":~") {
+		return fmt.Errorf("schedule %q is not a systemd calendar event, cron expressions are not supported (e.g. use \"*-*-* 02:00:00\" instead of \"0 2 * * *\")", schedule)
+	}
+	if len(fields) > 0 && validCalendarWeekdays(fields[0]) {
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.ContainsAny(fields[0], "-~") {
+		if !validCalendarDate(fields[0]) {
+			return fmt.Errorf("schedule %q: %q is not a valid date", schedule, fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.Contains(fields[0], ":") {
+		if !validCalendarTime(fields[0]) {
+			return fmt.Errorf("schedule %q: %q is not a valid time", schedule, fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == len(strings.Fields(schedule)) {
+		return fmt.Errorf("schedule %q is not a systemd calendar event (e.g. \"daily\" or \"Mon..Fri *-*-* 02:00:00\")", schedule)
+	}
+	// the event can end with a time zone
+	if len(fields) == 1 {
+		if _, err := time.LoadLocation(fields[0]); err == nil && fields[0] != "Local" {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		return fmt.Errorf("schedule %q: unexpected %q", schedule, strings.Join(fields, " "))
+	}
+	return nil
+}
+
+// returns true if the field is a list of weekdays or ranges of weekdays
+func validCalendarWeekdays(field string) bool {
+	for _, r := range strings.Split(strings.ToLower(field), ",") {
+		for _, d := range strings.SplitN(r, "..", 2) {
+			if !util.Contains(d, calendarWeekdays) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func validCalendarDate(field string) bool {
+	parts := strings.Split(field, "-")
+	// the day can be counted from the end of the month with "~"
+	if last := parts[len(parts)-1]; strings.Contains(last, "~") {
+		parts = append(parts[:len(parts)-1], strings.SplitN(last, "~", 2)...)
+	}
+	switch len(parts) {
+	case 2:
+		return validCalendarValues(parts[0], 1, 12) && validCalendarValues(parts[1], 1, 31)
+	case 3:
+		return validCalendarValues(parts[0], 1970, 2199) && validCalendarValues(parts[1], 1, 12) && validCalendarValues(parts[2], 1, 31)
+	}
+	return false
+}
+
+func validCalendarTime(field string) bool {
+	parts := strings.Split(field, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return false
+	}
+	if !validCalendarValues(parts[0], 0, 23) || !validCalendarValues(parts[1], 0, 59) {
+		return false
+	}
+	if len(parts) == 3 {
+		// seconds can have a fraction
+		seconds := parts[2]
+		if i := strings.Index(seconds, "."); i >= 0 && !strings.Contains(seconds, "..") {
+			if _, err := strconv.ParseUint(seconds[i+1:], 10, 32); err != nil {
+				return false
+			}
+			seconds = seconds[:i]
+		}
+		return validCalendarValues(seconds, 0, 59)
+	}
+	return true
+}
+
+// returns true if the component of the calendar event is "*", or a list of values
+// or ranges between min and max, optionally repeated with "/"
+func validCalendarValues(component string, min, max int) bool {
+	for _, v := range strings.Split(component, ",") {
+		if i := strings.Index(v, "/"); i >= 0 {
+			if n, err := strconv.Atoi(v[i+1:]); err != nil || n < 1 {
+				return false
+			}
+			v = v[:i]
+		}
+		if v == "*" {
+			continue
+		}
+		for _, n := range strings.SplitN(v, "..", 2) {
+			if i, err := strconv.Atoi(n); err != nil || i < min || i > max {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ScheduleEtcdBackups installs a systemd timer on the etcd nodes that saves a snapshot of the
+// Kubernetes etcd cluster on the node, and prunes old snapshots according to the retention.
+// The schedule is a systemd calendar event. When the schedule is EtcdBackupScheduleNone, the timer is removed.
+func (ae *ansibleExecutor) ScheduleEtcdBackups(plan Plan, schedule string, retention EtcdBackupRetention) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.EtcdBackupSchedule = schedule
+	cc.EtcdBackupKeep = retention.Keep
+	cc.EtcdBackupMaxAgeMinutes = int(retention.MaxAge.Minutes())
+	t := task{
+		name:           "etcd-backup-schedule",
+		playbook:       "etcd-backup-schedule.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, "Schedule Etcd Backups", '=')
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error scheduling etcd backups: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writes the files that are fetched from the etcd member into the backup directory
+func writeFetchedSnapshot(t *testing.T, b *EtcdBackup, contents string, checksum string) {
+	if err := ioutil.WriteFile(b.SnapshotFile(), []byte(contents), 0600); err != nil {
+		t.Fatalf("error writing snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.Directory, etcdBackupChecksumFile), []byte(checksum+"  foo.db\n"), 0600); err != nil {
+		t.Fatalf("error writing checksum: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.Directory, etcdBackupMemberFile), []byte("etcd01"), 0600); err != nil {
+		t.Fatalf("error writing member: %v", err)
+	}
+}
+
+func sha256String(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFinalizeEtcdBackup(t *testing.T) {
+	dir := mustGetTempDir(t)
+	defer os.RemoveAll(dir)
+	plan := Plan{Cluster: Cluster{Name: "kubernetes", Version: "v1.10.5"}}
+	b, err := NewEtcdBackup(plan, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFetchedSnapshot(t, b, "snapshot", sha256String("snapshot"))
+	if err = FinalizeEtcdBackup(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := GetEtcdBackup(dir, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if read.Member != "etcd01" || read.ClusterName != "kubernetes" || read.KubernetesVersion != "v1.10.5" || read.Size != 8 {
+		t.Errorf("unexpected backup metadata: %+v", read)
+	}
+	if err = VerifyEtcdBackup(*read); err != nil {
+		t.Errorf("unexpected error verifying backup: %v", err)
+	}
+	ioutil.WriteFile(read.SnapshotFile(), []byte("corrupted"), 0600)
+	if err = VerifyEtcdBackup(*read); err == nil {
+		t.Errorf("expected an error verifying a corrupted backup, but got none")
+	}
+}
+
+func TestFinalizeEtcdBackupChecksumMismatch(t *testing.T) {
+	dir := mustGetTempDir(t)
+	defer os.RemoveAll(dir)
+	b, err := NewEtcdBackup(Plan{}, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFetchedSnapshot(t, b, "truncated", sha256String("snapshot"))
+	if err = FinalizeEtcdBackup(b); err == nil {
+		t.Errorf("expected an error, but got none")
+	}
+	// incomplete backups are not listed
+	backups, err := ListEtcdBackups(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected no backups, but got %v", backups)
+	}
+}
+
+func TestPruneEtcdBackups(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		retention EtcdBackupRetention
+		remaining []string
+	}{
+		{
+			name:      "keep all",
+			remaining: []string{"1h", "2h", "3h", "48h"},
+		},
+		{
+			name:      "keep by count",
+			retention: EtcdBackupRetention{Keep: 2},
+			remaining: []string{"1h", "2h"},
+		},
+		{
+			name:      "keep by age",
+			retention: EtcdBackupRetention{MaxAge: 24 * time.Hour},
+			remaining: []string{"1h", "2h", "3h"},
+		},
+		{
+			name:      "keep by count and age",
+			retention: EtcdBackupRetention{Keep: 1, MaxAge: 24 * time.Hour},
+			remaining: []string{"1h"},
+		},
+	}
+	for _, test := range tests {
+		dir := mustGetTempDir(t)
+		for _, age := range []string{"2h", "48h", "1h", "3h"} {
+			d, _ := time.ParseDuration(age)
+			b := EtcdBackup{Name: age, CreatedAt: now.Add(-d), Directory: filepath.Join(dir, age)}
+			os.MkdirAll(b.Directory, 0700)
+			writeFetchedSnapshot(t, &b, age, sha256String(age))
+			if err := FinalizeEtcdBackup(&b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if _, err := PruneEtcdBackups(dir, test.retention, now); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		backups, _ := ListEtcdBackups(dir)
+		remaining := []string{}
+		for _, b := range backups {
+			remaining = append(remaining, b.Name)
+		}
+		if len(remaining) != len(test.remaining) {
+			t.Errorf("%s: expected %v to remain, but got %v", test.name, test.remaining, remaining)
+			continue
+		}
+		for i := range remaining {
+			if remaining[i] != test.remaining[i] {
+				t.Errorf("%s: expected %v to remain, but got %v", test.name, test.remaining, remaining)
+				break
+			}
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestValidateEtcdBackupSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		valid    bool
+	}{
+		{schedule: "none", valid: true},
+		{schedule: "daily", valid: true},
+		{schedule: "Weekly", valid: true},
+		{schedule: "*-*-* 02:00:00", valid: true},
+		{schedule: "02:30", valid: true},
+		{schedule: "Mon..Fri *-*-* 02:00:00", valid: true},
+		{schedule: "Sat,Sun 03:15", valid: true},
+		{schedule: "*-*-01 00:00:00", valid: true},
+		{schedule: "*-02~03 00:00", valid: true},
+		{schedule: "2019-*-* 00/6:00:00", valid: true},
+		{schedule: "*:0/15", valid: true},
+		{schedule: "*-*-* 02:00:00 UTC", valid: true},
+		{schedule: "0 2 * * *"},
+		{schedule: "*/15 * * * *"},
+		{schedule: "every day"},
+		{schedule: "*-*-* 25:00:00"},
+		{schedule: "*-13-* 02:00:00"},
+		{schedule: "*-*-* 02:00:00 later"},
+		{schedule: "Mon..Funday 02:00"},
+		{schedule: "*-*-* 02:00/0"},
+		{schedule: ""},
+	}
+	for _, test := range tests {
+		err := ValidateEtcdBackupSchedule(test.schedule)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid to be %v, but got error %v", test.schedule, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// EtcdRestoreSnapshot returns the path to the etcd snapshot to restore. The snapshot is either
+// the name of a backup in the backups directory, or the path to a snapshot file. The checksum of
+// the snapshot is verified when it is known, and the backup is returned when there is one.
+func EtcdRestoreSnapshot(backupsDir string, snapshot string) (string, *EtcdBackup, error) {
+	if fi, err := os.Stat(snapshot); err == nil && fi.Mode().IsRegular() {
+		file, err := filepath.Abs(snapshot)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to determine absolute path to %s: %v", snapshot, err)
+		}
+		// the checksum is next to snapshots taken by "kismatic etcd backup"
+		checksum, err := ioutil.ReadFile(file + ".sha256")
+		if os.IsNotExist(err) {
+			return file, nil, nil
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("error reading snapshot checksum: %v", err)
+		}
+		sum, _, err := sha256File(file)
+		if err != nil {
+			return "", nil, err
+		}
+		if fields := strings.Fields(string(checksum)); len(fields) == 0 || fields[0] != sum {
+			return "", nil, fmt.Errorf("the checksum of the snapshot %q does not match %s.sha256", file, file)
+		}
+		return file, nil, nil
+	}
+	backup, err := GetEtcdBackup(backupsDir, snapshot)
+	if err != nil {
+		return "", nil, fmt.Errorf("%q is neither a snapshot file nor a backup: %v", snapshot, err)
+	}
+	if err = VerifyEtcdBackup(*backup); err != nil {
+		return "", nil, err
+	}
+	return backup.SnapshotFile(), backup, nil
+}
+
+// RestoreEtcd rebuilds the Kubernetes etcd cluster from the snapshot, on the etcd nodes
+// of the plan. The control plane is stopped, and every member is restored from the snapshot
+// with a new member ID. Once the members are healthy and the cluster has all the members,
+// the control plane is started again. The data directory of every member is kept on the node.
+func (ae *ansibleExecutor) RestoreEtcd(plan Plan, snapshotFile string) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.EtcdRestoreFile = snapshotFile
+	cc.EtcdRestoreName = "restore-" + time.Now().Format(etcdBackupNameTimeFormat)
+	t := task{
+		name:           "etcd-restore",
+		playbook:       "etcd-restore.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, "Restore Etcd", '=')
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error restoring etcd: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEtcdRestoreSnapshot(t *testing.T) {
+	dir := mustGetTempDir(t)
+	defer os.RemoveAll(dir)
+	b, err := NewEtcdBackup(Plan{}, filepath.Join(dir, "backups"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	writeFetchedSnapshot(t, b, "snapshot", sha256String("snapshot"))
+	if err = FinalizeEtcdBackup(b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, backup, err := EtcdRestoreSnapshot(filepath.Join(dir, "backups"), b.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file != b.SnapshotFile() || backup == nil || backup.Name != b.Name {
+		t.Errorf("expected the snapshot of backup %q, but got %q %+v", b.Name, file, backup)
+	}
+
+	// a snapshot file without a checksum
+	plain := filepath.Join(dir, "plain.db")
+	ioutil.WriteFile(plain, []byte("plain"), 0600)
+	file, backup, err = EtcdRestoreSnapshot(filepath.Join(dir, "backups"), plain)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file != plain || backup != nil {
+		t.Errorf("expected the snapshot file %q, but got %q %+v", plain, file, backup)
+	}
+
+	// a snapshot file with a checksum that does not match
+	ioutil.WriteFile(plain+".sha256", []byte(sha256String("other")+"  plain.db\n"), 0600)
+	if _, _, err = EtcdRestoreSnapshot(filepath.Join(dir, "backups"), plain); err == nil {
+		t.Errorf("expected an error for a checksum mismatch, but got none")
+	}
+
+	if _, _, err = EtcdRestoreSnapshot(filepath.Join(dir, "backups"), "missing"); err == nil {
+		t.Errorf("expected an error for a missing backup, but got none")
+	}
+}
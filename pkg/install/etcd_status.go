@@ -0,0 +1,168 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// the etcd API version of the etcdctl commands
+const etcdctlAPIVersion = "3"
+
+// the variables of an etcd cluster that is installed by the etcd role, as set in its
+// file of ansible/group_vars
+type etcdClusterVars struct {
+	// etcd_name, the name of the etcd container
+	name string
+	// etcd_install_dir, where the certificates are deployed
+	installDir string
+	// etcd_service_client_port
+	clientPort int
+	// etcd_insecure_validate, the member does not use TLS
+	insecure bool
+}
+
+// the Kubernetes etcd cluster, as set in ansible/group_vars/etcd-k8s.yaml
+var etcdK8sVars = etcdClusterVars{name: "etcd_k8s", installDir: "/etc/etcd_k8s", clientPort: 2379}
+
+// returns the networking etcd cluster, as set in ansible/group_vars/etcd-networking.yaml. Its
+// members do not use TLS when insecure_networking_etcd is set in the cluster catalog.
+func etcdNetworkingVars(cc ansible.ClusterCatalog) etcdClusterVars {
+	return etcdClusterVars{name: "etcd_networking", installDir: "/etc/etcd_networking", clientPort: 6666, insecure: cc.InsecureNetworkingEtcd}
+}
+
+// returns the path of a certificate of the cluster on the node, as set in etcd_certificates
+// of ansible/group_vars/all.yaml
+func (e etcdClusterVars) certificate(file string) string {
+	return path.Join(e.installDir, file)
+}
+
+// returns the etcdctl command that runs in the etcd container of a node, against the
+// local member. The certificates are only used when the member uses TLS, like the
+// etcdctl command of ansible/roles/etcd/tasks/etcdctl.yaml.
+func (e etcdClusterVars) etcdctlLocal() string {
+	if e.insecure {
+		return fmt.Sprintf("sudo docker exec -e ETCDCTL_API=%s %s /usr/local/bin/etcdctl --endpoints=http://127.0.0.1:%d",
+			etcdctlAPIVersion, e.name, e.clientPort)
+	}
+	return fmt.Sprintf("sudo docker exec -e ETCDCTL_API=%s %s /usr/local/bin/etcdctl --endpoints=https://127.0.0.1:%d --cacert=%s --cert=%s --key=%s",
+		etcdctlAPIVersion, e.name, e.clientPort, e.certificate("ca.pem"), e.certificate("etcd-client.pem"), e.certificate("etcd-client-key.pem"))
+}
+
+// EtcdMemberStatus is the status of a member of the Kubernetes etcd cluster
+type EtcdMemberStatus struct {
+	Host      string `json:"host"`
+	Healthy   bool   `json:"healthy"`
+	Leader    bool   `json:"leader"`
+	MemberID  string `json:"memberID,omitempty"`
+	RaftTerm  uint64 `json:"raftTerm"`
+	RaftIndex uint64 `json:"raftIndex"`
+	DBSize    int64  `json:"dbSize"`
+	Version   string `json:"version,omitempty"`
+	// Error is set when the status of the member could not be determined
+	Error string `json:"error,omitempty"`
+}
+
+// the output of "etcdctl endpoint status -w json"
+type etcdctlEndpointStatus struct {
+	Status struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+		} `json:"header"`
+		Version   string `json:"version"`
+		DBSize    int64  `json:"dbSize"`
+		Leader    uint64 `json:"leader"`
+		RaftIndex uint64 `json:"raftIndex"`
+		RaftTerm  uint64 `json:"raftTerm"`
+	}
+}
+
+// runs a command on the node, and returns its output
+type remoteCommandRunner func(host string, cmd string) (string, error)
+
+// EtcdClusterStatus returns the status of every member of the Kubernetes etcd cluster
+// of the plan, by running etcdctl against the member on every etcd node
+func EtcdClusterStatus(plan *Plan) []EtcdMemberStatus {
+	return etcdClusterStatus(plan.Etcd.Nodes, etcdK8sVars, func(host string, cmd string) (string, error) {
+		client, err := plan.GetSSHClient(host)
+		if err != nil {
+			return "", err
+		}
+		return client.Output(false, cmd)
+	})
+}
+
+func etcdClusterStatus(nodes []Node, etcd etcdClusterVars, run remoteCommandRunner) []EtcdMemberStatus {
+	etcdctl := etcd.etcdctlLocal()
+	statuses := []EtcdMemberStatus{}
+	for _, n := range nodes {
+		s := EtcdMemberStatus{Host: n.Host}
+		// the status of an unhealthy member can be reported, so health is checked separately
+		if out, err := run(n.Host, etcdctl+" endpoint health"); err != nil {
+			s.Error = strings.TrimSpace(out)
+			if s.Error == "" {
+				s.Error = err.Error()
+			}
+		} else {
+			s.Healthy = true
+		}
+		out, err := run(n.Host, etcdctl+" endpoint status -w json 2>/dev/null")
+		if err != nil {
+			if s.Error == "" {
+				s.Error = fmt.Sprintf("error getting member status: %v", err)
+			}
+			statuses = append(statuses, s)
+			continue
+		}
+		var endpoints []etcdctlEndpointStatus
+		if err := json.Unmarshal([]byte(out), &endpoints); err != nil || len(endpoints) != 1 {
+			if s.Error == "" {
+				s.Error = fmt.Sprintf("unexpected member status: %q", out)
+			}
+			statuses = append(statuses, s)
+			continue
+		}
+		es := endpoints[0].Status
+		s.MemberID = fmt.Sprintf("%x", es.Header.MemberID)
+		s.Leader = es.Leader != 0 && es.Leader == es.Header.MemberID
+		s.RaftTerm = es.RaftTerm
+		s.RaftIndex = es.RaftIndex
+		s.DBSize = es.DBSize
+		s.Version = es.Version
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// DefragEtcd defragments the members of the Kubernetes etcd cluster one at a time, verifying
+// that all the members are healthy before and after defragmenting each of them. When compact
+// is true, the keyspace is first compacted at its current revision, discarding the history
+// of the keys, so that defragmenting the members releases the space it used.
+func (ae *ansibleExecutor) DefragEtcd(plan Plan, compact bool) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.EtcdCompact = compact
+	t := task{
+		name:           "etcd-defrag",
+		playbook:       "etcd-defrag.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	if compact {
+		util.PrintHeader(ae.stdout, "Compact and Defragment Etcd", '=')
+	} else {
+		util.PrintHeader(ae.stdout, "Defragment Etcd", '=')
+	}
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error running etcd maintenance: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package install
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestEtcdClusterStatus(t *testing.T) {
+	nodes := []Node{{Host: "etcd01"}, {Host: "etcd02"}, {Host: "etcd03"}}
+	status := map[string]string{
+		"etcd01": `[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"cluster_id":1,"member_id":10,"revision":50,"raft_term":2},"version":"3.4.3","dbSize":24576,"leader":10,"raftIndex":120,"raftTerm":2}}]`,
+		"etcd02": `[{"Endpoint":"https://127.0.0.1:2379","Status":{"header":{"cluster_id":1,"member_id":11,"revision":50,"raft_term":2},"version":"3.4.3","dbSize":20480,"leader":10,"raftIndex":119,"raftTerm":2}}]`,
+	}
+	run := func(host string, cmd string) (string, error) {
+		if host == "etcd03" {
+			return "https://127.0.0.1:2379 is unhealthy: failed to connect", errors.New("exit status 1")
+		}
+		if strings.Contains(cmd, "endpoint health") {
+			return "https://127.0.0.1:2379 is healthy", nil
+		}
+		return status[host], nil
+	}
+	statuses := etcdClusterStatus(nodes, etcdK8sVars, run)
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 statuses, but got %d", len(statuses))
+	}
+	leader := statuses[0]
+	if !leader.Healthy || !leader.Leader || leader.MemberID != "a" || leader.RaftIndex != 120 || leader.DBSize != 24576 || leader.Version != "3.4.3" {
+		t.Errorf("unexpected status for etcd01: %+v", leader)
+	}
+	if !statuses[1].Healthy || statuses[1].Leader {
+		t.Errorf("expected etcd02 to be a healthy follower, but got %+v", statuses[1])
+	}
+	if statuses[2].Healthy || statuses[2].Error == "" {
+		t.Errorf("expected etcd03 to be unhealthy with an error, but got %+v", statuses[2])
+	}
+}
+
+func TestEtcdctlLocal(t *testing.T) {
+	secure := etcdK8sVars.etcdctlLocal()
+	expected := "sudo docker exec -e ETCDCTL_API=3 etcd_k8s /usr/local/bin/etcdctl --endpoints=https://127.0.0.1:2379 " +
+		"--cacert=/etc/etcd_k8s/ca.pem --cert=/etc/etcd_k8s/etcd-client.pem --key=/etc/etcd_k8s/etcd-client-key.pem"
+	if secure != expected {
+		t.Errorf("expected %q, but got %q", expected, secure)
+	}
+}
+
+func TestEtcdctlLocalNetworking(t *testing.T) {
+	cc := ansible.ClusterCatalog{}
+	expected := "sudo docker exec -e ETCDCTL_API=3 etcd_networking /usr/local/bin/etcdctl --endpoints=https://127.0.0.1:6666 " +
+		"--cacert=/etc/etcd_networking/ca.pem --cert=/etc/etcd_networking/etcd-client.pem --key=/etc/etcd_networking/etcd-client-key.pem"
+	if cmd := etcdNetworkingVars(cc).etcdctlLocal(); cmd != expected {
+		t.Errorf("expected %q, but got %q", expected, cmd)
+	}
+	cc.InsecureNetworkingEtcd = true
+	expected = "sudo docker exec -e ETCDCTL_API=3 etcd_networking /usr/local/bin/etcdctl --endpoints=http://127.0.0.1:6666"
+	if cmd := etcdNetworkingVars(cc).etcdctlLocal(); cmd != expected {
+		t.Errorf("expected %q, but got %q", expected, cmd)
+	}
+}
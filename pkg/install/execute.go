@@ -22,6 +22,7 @@ import (
 type PreFlightExecutor interface {
 	RunPreFlightCheck(plan *Plan, nodes ...string) error
 	RunNewNodePreFlightCheck(Plan, Node) error
+	RunNewNodesPreFlightCheck(Plan, []NewNode) ([]string, error)
 	RunUpgradePreFlightCheck(*Plan, ListableNode) error
 }
 
@@ -33,12 +34,29 @@ type Executor interface {
 	GenerateCertificates(p *Plan, useExistingCA bool) error
 	RunSmokeTest(*Plan) error
 	AddNode(plan *Plan, node Node, roles []string, restartServices bool) (*Plan, error)
+	AddNodes(plan *Plan, nodes []NewNode, restartServices bool) (*Plan, []AddNodeResult, error)
+	RemoveNode(plan *Plan, host string, unreachable bool) (*Plan, error)
 	RunPlay(name string, plan *Plan, restartServices bool, nodes ...string) error
 	AddVolume(*Plan, StorageVolume) error
 	DeleteVolume(*Plan, string) error
-	UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) error
+	UpgradeNodes(plan Plan, batches []UpgradeBatch, onlineUpgrade bool, restartServices bool) error
+	SnapshotNodes(plan Plan, snapshot UpgradeSnapshot) error
+	RollbackNodes(plan Plan, snapshot UpgradeSnapshot, nodes []NodeSnapshot, restoreEtcd bool) error
 	ValidateControlPlane(plan Plan) error
 	UpgradeClusterServices(plan Plan) error
+	ApplyAddOns(plan Plan, addOns []string) error
+	ReconcileNodes(plan Plan, drift []NodeDrift) error
+	DrainNode(plan Plan, node Node) error
+	UncordonNode(plan Plan, node Node) error
+	RebootNodes(plan Plan, batches []UpgradeBatch, timeout time.Duration) error
+	UpdateOSPackages(plan Plan, batches []UpgradeBatch, rebootTimeout time.Duration) ([]OSUpdateResult, error)
+	BackupEtcd(plan Plan, backup EtcdBackup, retention EtcdBackupRetention) error
+	ScheduleEtcdBackups(plan Plan, schedule string, retention EtcdBackupRetention) error
+	RestoreEtcd(plan Plan, snapshotFile string) error
+	DefragEtcd(plan Plan, compact bool) error
+	RotateEncryptionKey(plan Plan) error
+	RotateCertificates(plan Plan, components []string) error
+	RotateClusterCA(plan Plan) error
 }
 
 // DiagnosticsExecutor will run diagnostics on the nodes after an install
@@ -46,6 +64,10 @@ type DiagnosticsExecutor interface {
 	DiagnoseNodes(plan Plan) error
 }
 
+// DefaultRunsDirectory is where information about installation runs is kept,
+// unless specified otherwise in the ExecutorOptions
+const DefaultRunsDirectory = "./runs"
+
 // ExecutorOptions are used to configure the executor
 type ExecutorOptions struct {
 	// GeneratedAssetsDirectory is the location where generated assets
@@ -70,7 +92,7 @@ func NewExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOptions) (E
 		return nil, fmt.Errorf("GeneratedAssetsDirectory option cannot be empty")
 	}
 	if options.RunsDirectory == "" {
-		options.RunsDirectory = "./runs"
+		options.RunsDirectory = DefaultRunsDirectory
 	}
 
 	// Setup the console output format
@@ -103,7 +125,7 @@ func NewExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOptions) (E
 func NewPreFlightExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOptions) (PreFlightExecutor, error) {
 	ansibleDir := "ansible"
 	if options.RunsDirectory == "" {
-		options.RunsDirectory = "./runs"
+		options.RunsDirectory = DefaultRunsDirectory
 	}
 	// Setup the console output format
 	var outFormat ansible.OutputFormat
@@ -128,7 +150,7 @@ func NewPreFlightExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOp
 func NewDiagnosticsExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOptions) (DiagnosticsExecutor, error) {
 	ansibleDir := "ansible"
 	if options.RunsDirectory == "" {
-		options.RunsDirectory = "./runs"
+		options.RunsDirectory = DefaultRunsDirectory
 	}
 	if options.DiagnosticsDirecty == "" {
 		wd, err := os.Getwd()
@@ -508,7 +530,8 @@ func (ae *ansibleExecutor) DeleteVolume(plan *Plan, name string) error {
 	return ae.execute(t)
 }
 
-// UpgradeNodes upgrades the nodes of the cluster in the following phases:
+// UpgradeNodes upgrades the nodes of the cluster, one batch at a time. The batches are
+// expected to be in the following phases (see UpgradeBatches and OnlineUpgradeBatches):
 //   1. Etcd nodes
 //   2. Master nodes
 //   3. Worker nodes (regardless of specialization)
@@ -517,62 +540,10 @@ func (ae *ansibleExecutor) DeleteVolume(plan *Plan, name string) error {
 // which phase of the upgrade we are in. For example, when upgrading a node that is both an etcd and master,
 // the etcd components and the master components will be upgraded when we are in the upgrade etcd nodes
 // phase.
-func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) error {
-	// Nodes can have multiple roles. For this reason, we need to keep track of which nodes
-	// have been upgraded to avoid re-upgrading them.
-	upgradedNodes := map[string]bool{}
-	// Upgrade etcd nodes
-	for _, nodeToUpgrade := range nodesToUpgrade {
-		for _, role := range nodeToUpgrade.Roles {
-			if role == "etcd" {
-				node := nodeToUpgrade
-				if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, node); err != nil {
-					return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
-		}
-	}
-
-	// Upgrade master nodes
-	for _, nodeToUpgrade := range nodesToUpgrade {
-		if upgradedNodes[nodeToUpgrade.Node.IP] == true {
-			continue
-		}
-		for _, role := range nodeToUpgrade.Roles {
-			if role == "master" {
-				node := nodeToUpgrade
-				if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, node); err != nil {
-					return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
-		}
-	}
-
-	var limitNodes []ListableNode
-	// Upgrade the rest of the nodes
-	for n, nodeToUpgrade := range nodesToUpgrade {
-		if upgradedNodes[nodeToUpgrade.Node.IP] == true {
-			continue
-		}
-		for _, role := range nodeToUpgrade.Roles {
-			if role != "etcd" && role != "master" {
-				node := nodeToUpgrade
-				limitNodes = append(limitNodes, node)
-				// don't forget to run the remaining nodes if its < maxParallelWorkers
-				if len(limitNodes) == maxParallelWorkers || n == len(nodesToUpgrade)-1 {
-					if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, limitNodes...); err != nil {
-						return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-					}
-					// empty the slice
-					limitNodes = limitNodes[:0]
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
+func (ae *ansibleExecutor) UpgradeNodes(plan Plan, batches []UpgradeBatch, onlineUpgrade bool, restartServices bool) error {
+	for _, batch := range batches {
+		if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, batch.Nodes...); err != nil {
+			return fmt.Errorf("error upgrading node %q: %v", batch.Nodes[0].Node.Host, err)
 		}
 	}
 	return nil
@@ -646,6 +617,104 @@ func (ae *ansibleExecutor) UpgradeClusterServices(plan Plan) error {
 	return ae.execute(t)
 }
 
+// ApplyAddOns deploys the given add-ons according to the plan, without
+// touching the rest of the cluster.
+func (ae *ansibleExecutor) ApplyAddOns(plan Plan, addOns []string) error {
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.AddOnsToApply = addOns
+	t := task{
+		name:           "apply-addons",
+		playbook:       "addons.yaml",
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	return ae.execute(t)
+}
+
+// SnapshotNodes takes a snapshot of the etcd cluster, and stores it in the snapshot directory.
+// The configuration of every node is archived on the node itself, so that it can
+// be restored by RollbackNodes.
+func (ae *ansibleExecutor) SnapshotNodes(plan Plan, snapshot UpgradeSnapshot) error {
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(snapshot.Directory)
+	if err != nil {
+		return fmt.Errorf("failed to determine absolute path to %s: %v", snapshot.Directory, err)
+	}
+	cc.UpgradeSnapshotName = snapshot.Name
+	cc.UpgradeSnapshotDirectory = dir
+	t := task{
+		name:           "upgrade-snapshot",
+		playbook:       "upgrade-snapshot.yaml",
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, "Snapshot Cluster State", '=')
+	return ae.execute(t)
+}
+
+// RollbackNodes restores the given nodes to the state recorded in the snapshot,
+// one node at a time. When restoreEtcd is true, the control plane is stopped
+// and the etcd cluster is restored from the snapshot before rolling back the nodes.
+func (ae *ansibleExecutor) RollbackNodes(plan Plan, snapshot UpgradeSnapshot, nodes []NodeSnapshot, restoreEtcd bool) error {
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(snapshot.Directory)
+	if err != nil {
+		return fmt.Errorf("failed to determine absolute path to %s: %v", snapshot.Directory, err)
+	}
+	cc.UpgradeSnapshotName = snapshot.Name
+	cc.UpgradeSnapshotDirectory = dir
+	cc.EtcdRestoreFile = filepath.Join(dir, UpgradeSnapshotEtcdFile)
+	cc.EtcdRestoreName = snapshot.Name
+	if restoreEtcd {
+		t := task{
+			name:           "rollback-etcd",
+			playbook:       "rollback-etcd.yaml",
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			plan:           plan,
+			explainer:      ae.defaultExplainer(),
+		}
+		util.PrintHeader(ae.stdout, "Restore Etcd Snapshot", '=')
+		if err := ae.execute(t); err != nil {
+			return fmt.Errorf("error restoring etcd: %v", err)
+		}
+	}
+	for _, n := range nodes {
+		nodeCC := *cc
+		nodeCC.RollbackPackages = n.Packages
+		t := task{
+			name:           "rollback-node",
+			playbook:       "rollback-node.yaml",
+			inventory:      inventory,
+			clusterCatalog: nodeCC,
+			plan:           plan,
+			explainer:      ae.defaultExplainer(),
+			limit:          []string{n.Host},
+		}
+		util.PrintHeader(ae.stdout, fmt.Sprintf("Rollback Node: %s %s", n.Host, n.Roles), '=')
+		if err := ae.execute(t); err != nil {
+			return fmt.Errorf("error rolling back node %q: %v", n.Host, err)
+		}
+	}
+	return nil
+}
+
 func (ae *ansibleExecutor) DiagnoseNodes(plan Plan) error {
 	inventory := buildInventoryFromPlan(&plan)
 	cc, err := ae.buildClusterCatalog(&plan)
@@ -778,6 +847,26 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 	cc.CloudProvider = p.Cluster.CloudProvider.Provider
 	cc.CloudConfig = p.Cluster.CloudProvider.Config
 
+	// the encryption configuration is kept after encryption is disabled,
+	// so that the secrets that were encrypted can still be read
+	encryptionConfig, err := filepath.Abs(EncryptionConfigFile(ae.options.GeneratedAssetsDirectory))
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine absolute path to the encryption configuration: %v", err)
+	}
+	if _, err := os.Stat(encryptionConfig); err == nil || p.Cluster.EncryptionAtRest.Enabled {
+		cc.EncryptionConfig = encryptionConfig
+	}
+
+	// the nodes trust both the old and new CAs during a CA rotation,
+	// and the full chain of an intermediate CA
+	caTrustFile, err := filepath.Abs(clusterCATrustFile(ae.certsDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine absolute path to the CA bundle: %v", err)
+	}
+	if filepath.Base(caTrustFile) != "ca.pem" {
+		cc.CABundle = caTrustFile
+	}
+
 	// additional files
 	for _, n := range p.AdditionalFiles {
 		cc.AdditionalFiles = append(cc.AdditionalFiles, ansible.AdditionalFile{
@@ -865,6 +954,15 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 			cc.NodeTaints[n.Host] = keyValueEffectList(n.Taints)
 		}
 	}
+	// record the labels and taints that are applied from the plan file
+	cc.NodeManagedLabels = make(map[string]string)
+	cc.NodeManagedTaints = make(map[string]string)
+	labels := planNodeLabels(*p)
+	taints := planNodeTaints(*p)
+	for host := range labels {
+		cc.NodeManagedLabels[host] = managedLabelsValue(labels[host])
+		cc.NodeManagedTaints[host] = managedTaintsValue(taints[host])
+	}
 
 	// setup kubelet node overrides
 	cc.KubeletNodeOptions = make(map[string]map[string]string)
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/apprenda/kismatic/pkg/data"
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
@@ -61,7 +62,7 @@ func GenerateKubeconfig(p *Plan, generatedAssetsDir string) error {
 	certsDir := filepath.Join(generatedAssetsDir, "keys")
 
 	// Base64 encoded ca
-	caEncoded, err := util.Base64String(filepath.Join(certsDir, "ca.pem"))
+	caEncoded, err := util.Base64String(clusterCATrustFile(certsDir))
 	if err != nil {
 		return fmt.Errorf("error reading ca file for kubeconfig: %v", err)
 	}
@@ -94,7 +95,7 @@ func GenerateDashboardAdminKubeconfig(token string, p *Plan, generatedAssetsDir
 	certsDir := filepath.Join(generatedAssetsDir, "keys")
 
 	// Base64 encoded ca
-	caEncoded, err := util.Base64String(filepath.Join(certsDir, "ca.pem"))
+	caEncoded, err := util.Base64String(clusterCATrustFile(certsDir))
 	if err != nil {
 		return fmt.Errorf("error reading ca file for kubeconfig: %v", err)
 	}
@@ -160,3 +161,26 @@ func RegenerateKubeconfig(p *Plan, generatedAssetsDir string) (bool, error) {
 
 	return true, nil
 }
+
+// NewKubernetesAPIClient returns a client for the cluster's API server that
+// authenticates using the generated kubeconfig file. When the kubeconfig file
+// does not exist, the admin certificate in the generated keys directory is used.
+func NewKubernetesAPIClient(p *Plan, generatedAssetsDir string) (*data.APIClient, error) {
+	kubeconfigFile := filepath.Join(generatedAssetsDir, kubeconfigFilename)
+	if _, err := os.Stat(kubeconfigFile); err == nil {
+		return data.NewAPIClientFromKubeconfig(kubeconfigFile, data.DefaultAPITimeout)
+	}
+	host, port, err := p.ClusterAddress()
+	if err != nil {
+		return nil, err
+	}
+	certsDir := filepath.Join(generatedAssetsDir, "keys")
+	files := []string{clusterCATrustFile(certsDir), filepath.Join(certsDir, "admin.pem"), filepath.Join(certsDir, "admin-key.pem")}
+	pems := make([][]byte, len(files))
+	for i, f := range files {
+		if pems[i], err = ioutil.ReadFile(f); err != nil {
+			return nil, fmt.Errorf("error reading %q: %v", f, err)
+		}
+	}
+	return data.NewAPIClient("https://"+host+":"+port, pems[0], pems[1], pems[2], data.DefaultAPITimeout)
+}
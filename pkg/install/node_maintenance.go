@@ -0,0 +1,66 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// MaintenanceNode returns the node with the given host name, if it is registered
+// as a node with Kubernetes
+func MaintenanceNode(plan Plan, host string) (Node, error) {
+	for _, n := range plan.GetUniqueNodes() {
+		if n.Host != host {
+			continue
+		}
+		if !containsAny([]string{"master", "worker", "ingress", "storage"}, plan.GetRolesForIP(n.IP)) {
+			return Node{}, fmt.Errorf("node %q is not a Kubernetes node", host)
+		}
+		return n, nil
+	}
+	return Node{}, fmt.Errorf("node %q was not found in the plan file", host)
+}
+
+// DrainNode cordons the node, and evicts the pods running on it
+func (ae *ansibleExecutor) DrainNode(plan Plan, node Node) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	util.PrintHeader(ae.stdout, "Draining Node", '=')
+	t := task{
+		name:           "node-drain",
+		playbook:       "_kube-drain-node.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          []string{node.Host},
+	}
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error draining node: %v", err)
+	}
+	return nil
+}
+
+// UncordonNode waits for the node to be Ready, and marks it as schedulable
+func (ae *ansibleExecutor) UncordonNode(plan Plan, node Node) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	util.PrintHeader(ae.stdout, "Uncordoning Node", '=')
+	t := task{
+		name:           "node-uncordon",
+		playbook:       "node-uncordon.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          []string{node.Host},
+	}
+	if err = ae.execute(t); err != nil {
+		return fmt.Errorf("error uncordoning node: %v", err)
+	}
+	return nil
+}
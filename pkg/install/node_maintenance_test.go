@@ -0,0 +1,105 @@
+package install
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+func TestMaintenanceNode(t *testing.T) {
+	plan := removeNodeTestPlan()
+	node, err := MaintenanceNode(plan, "worker02")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.IP != "10.0.0.4" {
+		t.Errorf("expected worker02, but got %+v", node)
+	}
+	// etcd nodes are not registered with Kubernetes
+	for _, host := range []string{"etcd01", "foo"} {
+		if _, err := MaintenanceNode(plan, host); err == nil {
+			t.Errorf("%s: expected an error, but got none", host)
+		}
+	}
+}
+
+// records the playbook that was run, and the nodes it was limited to
+type playbookRecorder struct {
+	fakeRunner
+	playbook string
+	limit    []string
+}
+
+func (r *playbookRecorder) StartPlaybookOnNode(playbookFile string, inventory ansible.Inventory, cc ansible.ClusterCatalog, node ...string) (<-chan ansible.Event, error) {
+	r.playbook = playbookFile
+	r.limit = node
+	return r.eventChan, r.err
+}
+
+func nodeMaintenanceExecutor(t *testing.T, runner *playbookRecorder) ansibleExecutor {
+	return ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t)},
+		stdout:              ioutil.Discard,
+		consoleOutputFormat: ansible.RawFormat,
+		runnerExplainerFactory: func(explain.AnsibleEventExplainer, io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return runner, &explain.AnsibleEventStreamExplainer{}, nil
+		},
+	}
+}
+
+func nodeMaintenanceTestPlan() Plan {
+	plan := removeNodeTestPlan()
+	plan.Cluster.Networking.ServiceCIDRBlock = "10.0.0.0/16"
+	plan.Cluster.Version = "v1.10.5"
+	return plan
+}
+
+func TestDrainNode(t *testing.T) {
+	plan := nodeMaintenanceTestPlan()
+	node, _ := MaintenanceNode(plan, "worker02")
+	runner := &playbookRecorder{}
+	e := nodeMaintenanceExecutor(t, runner)
+	if err := e.DrainNode(plan, node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runner.playbook != "_kube-drain-node.yaml" {
+		t.Errorf("expected the drain playbook to be run, but got %q", runner.playbook)
+	}
+	if !reflect.DeepEqual(runner.limit, []string{"worker02"}) {
+		t.Errorf("expected the playbook to be limited to the node, but got %v", runner.limit)
+	}
+
+	runner.err = errors.New("exec error")
+	err := e.DrainNode(plan, node)
+	if err == nil || !strings.Contains(err.Error(), "error draining node") {
+		t.Errorf("expected an error draining the node, but got %v", err)
+	}
+}
+
+func TestUncordonNode(t *testing.T) {
+	plan := nodeMaintenanceTestPlan()
+	node, _ := MaintenanceNode(plan, "master01")
+	runner := &playbookRecorder{}
+	e := nodeMaintenanceExecutor(t, runner)
+	if err := e.UncordonNode(plan, node); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runner.playbook != "node-uncordon.yaml" {
+		t.Errorf("expected the uncordon playbook to be run, but got %q", runner.playbook)
+	}
+	if !reflect.DeepEqual(runner.limit, []string{"master01"}) {
+		t.Errorf("expected the playbook to be limited to the node, but got %v", runner.limit)
+	}
+
+	runner.err = errors.New("exec error")
+	err := e.UncordonNode(plan, node)
+	if err == nil || !strings.Contains(err.Error(), "error uncordoning node") {
+		t.Errorf("expected an error uncordoning the node, but got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+// The annotations record the labels and taints that were applied from the plan file,
+// so that the ones removed from the plan file can also be removed from the node.
+const (
+	managedLabelsAnnotation = "kismatic/managed-labels"
+	managedTaintsAnnotation = "kismatic/managed-taints"
+)
+
+// NodeDrift is the difference between the labels and taints of a node
+// in the plan file, and those of the node in the cluster
+type NodeDrift struct {
+	Host string
+	// NotFound is true when the node is not registered with the cluster
+	NotFound bool
+	// Labels that are missing from the node, or that have a different value
+	AddLabels map[string]string
+	// Labels that were applied from the plan file, but are no longer in it
+	RemoveLabels []string
+	// Taints that are missing from the node, or that have a different value
+	AddTaints []Taint
+	// Taints that were applied from the plan file, but are no longer in it
+	RemoveTaints []Taint
+	// whether the annotations that record the applied labels and taints are out of date
+	staleAnnotations bool
+}
+
+// HasDrift returns true if the labels or taints of the node do not match the plan file
+func (d NodeDrift) HasDrift() bool {
+	return len(d.AddLabels) > 0 || len(d.RemoveLabels) > 0 || len(d.AddTaints) > 0 || len(d.RemoveTaints) > 0
+}
+
+// NeedsReconcile returns true if the node has to be updated to match the plan file
+func (d NodeDrift) NeedsReconcile() bool {
+	return !d.NotFound && (d.HasDrift() || d.staleAnnotations)
+}
+
+// DetectNodeDrift compares the labels and taints of the Kubernetes nodes in the plan
+// file with those of the nodes in the cluster. Labels and taints that were not
+// applied from the plan file, such as the ones set by Kubernetes, are ignored.
+func DetectNodeDrift(plan Plan, client data.NodeLister) ([]NodeDrift, error) {
+	nodeList, err := client.ListNodes()
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+	liveNodes := map[string]data.Node{}
+	if nodeList != nil {
+		for _, n := range nodeList.Items {
+			name := n.Name
+			if hostname, ok := n.Labels["kubernetes.io/hostname"]; ok {
+				name = hostname
+			}
+			liveNodes[name] = n
+		}
+	}
+	labels := planNodeLabels(plan)
+	taints := planNodeTaints(plan)
+	drift := []NodeDrift{}
+	for _, host := range kubernetesNodeHosts(plan) {
+		d := NodeDrift{Host: host, AddLabels: map[string]string{}}
+		live, ok := liveNodes[strings.ToLower(host)]
+		if !ok {
+			d.NotFound = true
+			drift = append(drift, d)
+			continue
+		}
+		d.AddLabels, d.RemoveLabels = labelDrift(labels[host], live)
+		d.AddTaints, d.RemoveTaints = taintDrift(taints[host], live)
+		d.staleAnnotations = live.Annotations[managedLabelsAnnotation] != managedLabelsValue(labels[host]) ||
+			live.Annotations[managedTaintsAnnotation] != managedTaintsValue(taints[host])
+		drift = append(drift, d)
+	}
+	return drift, nil
+}
+
+func labelDrift(desired map[string]string, live data.Node) (map[string]string, []string) {
+	add := map[string]string{}
+	for k, v := range desired {
+		if liveValue, ok := live.Labels[k]; !ok || liveValue != v {
+			add[k] = v
+		}
+	}
+	remove := []string{}
+	for _, k := range splitAnnotation(live.Annotations[managedLabelsAnnotation]) {
+		if _, inPlan := desired[k]; inPlan {
+			continue
+		}
+		if _, onNode := live.Labels[k]; onNode {
+			remove = append(remove, k)
+		}
+	}
+	sort.Strings(remove)
+	return add, remove
+}
+
+func taintDrift(desired []Taint, live data.Node) ([]Taint, []Taint) {
+	liveTaints := map[string]data.Taint{}
+	for _, t := range live.Spec.Taints {
+		liveTaints[taintID(t.Key, t.Effect)] = t
+	}
+	add := []Taint{}
+	desiredIDs := map[string]bool{}
+	for _, t := range desired {
+		id := taintID(t.Key, t.Effect)
+		desiredIDs[id] = true
+		if lt, ok := liveTaints[id]; !ok || lt.Value != t.Value {
+			add = append(add, t)
+		}
+	}
+	remove := []Taint{}
+	for _, id := range splitAnnotation(live.Annotations[managedTaintsAnnotation]) {
+		if desiredIDs[id] {
+			continue
+		}
+		if lt, ok := liveTaints[id]; ok {
+			remove = append(remove, Taint{Key: lt.Key, Value: lt.Value, Effect: lt.Effect})
+		}
+	}
+	return add, remove
+}
+
+// returns the hosts that are registered as nodes with Kubernetes, in plan order
+func kubernetesNodeHosts(plan Plan) []string {
+	hosts := []string{}
+	seen := map[string]bool{}
+	groups := [][]Node{plan.Master.Nodes, plan.Worker.Nodes, plan.Ingress.Nodes, plan.Storage.Nodes}
+	for _, nodes := range groups {
+		for _, n := range nodes {
+			if !seen[n.Host] {
+				hosts = append(hosts, n.Host)
+				seen[n.Host] = true
+			}
+		}
+	}
+	return hosts
+}
+
+// merges the labels of the node across roles. Later roles take precedence,
+// in the same way as when the labels are applied.
+func planNodeLabels(plan Plan) map[string]map[string]string {
+	labels := map[string]map[string]string{}
+	for _, n := range plan.getAllNodes() {
+		if labels[n.Host] == nil {
+			labels[n.Host] = map[string]string{}
+		}
+		for k, v := range n.Labels {
+			labels[n.Host][k] = v
+		}
+	}
+	return labels
+}
+
+// merges the taints of the node across roles. A taint is identified by its key and effect.
+func planNodeTaints(plan Plan) map[string][]Taint {
+	taints := map[string][]Taint{}
+	for _, n := range plan.getAllNodes() {
+		for _, t := range n.Taints {
+			merged := []Taint{}
+			for _, existing := range taints[n.Host] {
+				if taintID(existing.Key, existing.Effect) != taintID(t.Key, t.Effect) {
+					merged = append(merged, existing)
+				}
+			}
+			taints[n.Host] = append(merged, t)
+		}
+	}
+	return taints
+}
+
+func taintID(key, effect string) string {
+	return key + ":" + effect
+}
+
+func managedLabelsValue(labels map[string]string) string {
+	keys := []string{}
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func managedTaintsValue(taints []Taint) string {
+	ids := []string{}
+	for _, t := range taints {
+		ids = append(ids, taintID(t.Key, t.Effect))
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func splitAnnotation(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// ReconcileNodes applies the labels and taints of the plan file to the nodes that
+// have drifted, and removes the ones that are no longer in the plan file.
+func (ae *ansibleExecutor) ReconcileNodes(plan Plan, drift []NodeDrift) error {
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.NodeLabelsRemove = map[string][]string{}
+	cc.NodeTaintsRemove = map[string][]string{}
+	hosts := []string{}
+	for _, d := range drift {
+		if !d.NeedsReconcile() {
+			continue
+		}
+		hosts = append(hosts, d.Host)
+		for _, k := range d.RemoveLabels {
+			cc.NodeLabelsRemove[d.Host] = append(cc.NodeLabelsRemove[d.Host], k+"-")
+		}
+		for _, t := range d.RemoveTaints {
+			cc.NodeTaintsRemove[d.Host] = append(cc.NodeTaintsRemove[d.Host], taintID(t.Key, t.Effect)+"-")
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	t := task{
+		name:           "reconcile-nodes",
+		playbook:       "_label-nodes.yaml",
+		inventory:      buildInventoryFromPlan(&plan),
+		clusterCatalog: *cc,
+		plan:           plan,
+		explainer:      ae.defaultExplainer(),
+		limit:          hosts,
+	}
+	return ae.execute(t)
+}
@@ -0,0 +1,89 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeNodeLister struct {
+	nodes *data.NodeList
+}
+
+func (f fakeNodeLister) ListNodes() (*data.NodeList, error) {
+	return f.nodes, nil
+}
+
+func liveNode(name string, labels, annotations map[string]string, taints ...data.Taint) data.Node {
+	n := data.Node{}
+	n.Name = name
+	n.Labels = labels
+	n.Annotations = annotations
+	n.Spec.Taints = taints
+	return n
+}
+
+func TestDetectNodeDrift(t *testing.T) {
+	plan := Plan{
+		Master: MasterNodeGroup{Nodes: []Node{{Host: "master01"}}},
+		Worker: NodeGroup{Nodes: []Node{
+			{Host: "Worker01", Labels: map[string]string{"team": "web", "tier": "front"}, Taints: []Taint{{Key: "dedicated", Value: "web", Effect: "NoSchedule"}}},
+			{Host: "worker02"},
+		}},
+		Ingress: OptionalNodeGroup{Nodes: []Node{{Host: "Worker01", Labels: map[string]string{"tier": "edge"}}}},
+	}
+	nodes := &data.NodeList{Items: []data.Node{
+		liveNode("master01", nil, map[string]string{managedLabelsAnnotation: "", managedTaintsAnnotation: ""}),
+		liveNode("worker01",
+			map[string]string{"kubernetes.io/hostname": "worker01", "team": "web", "tier": "front", "old": "label", "other": "label"},
+			map[string]string{managedLabelsAnnotation: "old,team,tier", managedTaintsAnnotation: "dedicated:NoSchedule,gone:NoExecute"},
+			data.Taint{Key: "dedicated", Value: "db", Effect: "NoSchedule"},
+			data.Taint{Key: "gone", Effect: "NoExecute"},
+			data.Taint{Key: "node.kubernetes.io/unreachable", Effect: "NoExecute"},
+		),
+	}}
+	drift, err := DetectNodeDrift(plan, fakeNodeLister{nodes: nodes})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(drift) != 3 {
+		t.Fatalf("expected drift for 3 nodes, but got %d", len(drift))
+	}
+	if drift[0].HasDrift() || drift[0].NeedsReconcile() {
+		t.Errorf("expected master01 not to have drifted, but got %+v", drift[0])
+	}
+	w := drift[1]
+	if !reflect.DeepEqual(w.AddLabels, map[string]string{"tier": "edge"}) {
+		t.Errorf("expected the ingress label to take precedence, but got %v", w.AddLabels)
+	}
+	if !reflect.DeepEqual(w.RemoveLabels, []string{"old"}) {
+		t.Errorf("expected the old label to be removed, but got %v", w.RemoveLabels)
+	}
+	if !reflect.DeepEqual(w.AddTaints, []Taint{{Key: "dedicated", Value: "web", Effect: "NoSchedule"}}) {
+		t.Errorf("expected the dedicated taint to be updated, but got %v", w.AddTaints)
+	}
+	if !reflect.DeepEqual(w.RemoveTaints, []Taint{{Key: "gone", Effect: "NoExecute"}}) {
+		t.Errorf("expected the gone taint to be removed, but got %v", w.RemoveTaints)
+	}
+	if !drift[2].NotFound || drift[2].NeedsReconcile() {
+		t.Errorf("expected worker02 not to be found, but got %+v", drift[2])
+	}
+}
+
+func TestDetectNodeDriftStaleAnnotations(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{Nodes: []Node{{Host: "worker01", Labels: map[string]string{"team": "web"}}}},
+	}
+	nodes := &data.NodeList{Items: []data.Node{liveNode("worker01", map[string]string{"team": "web"}, nil)}}
+	drift, err := DetectNodeDrift(plan, fakeNodeLister{nodes: nodes})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if drift[0].HasDrift() {
+		t.Errorf("expected no drift, but got %+v", drift[0])
+	}
+	if !drift[0].NeedsReconcile() {
+		t.Errorf("expected the node to need reconciling to record the applied labels")
+	}
+}
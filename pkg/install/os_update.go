@@ -0,0 +1,220 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/inspector/check"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+const osUpdatesDirName = "os-updates"
+
+// OSUpdateHeldPackages are the packages that Kismatic installs at pinned versions: the Kubernetes
+// packages at the version of the plan file, and the docker and GlusterFS packages at the versions of
+// ansible/group_vars/all.yaml. They are not updated when updating the OS packages of the nodes.
+// Globs are supported.
+var OSUpdateHeldPackages = []string{"kubelet", "kubectl", "kubernetes-cni", "docker-ce*", "glusterfs*"}
+
+// PackageChange is a package that was installed, updated or removed on a node.
+// Before is empty when the package was installed, and After is empty when it was removed.
+type PackageChange struct {
+	Name   string
+	Before string
+	After  string
+}
+
+// Held returns true if the package is one of the OSUpdateHeldPackages
+func (c PackageChange) Held() bool {
+	for _, p := range OSUpdateHeldPackages {
+		if ok, _ := path.Match(p, c.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OSUpdateResult contains the packages that were changed on a node by an OS update
+type OSUpdateResult struct {
+	Host    string
+	Changes []PackageChange
+	// Err is set when the package versions pinned by Kismatic were changed
+	Err error
+}
+
+// OSUpdateNodes returns the nodes of the plan that have the given role. When the
+// role is empty, every node of the plan is returned.
+func OSUpdateNodes(plan Plan, role string) ([]ListableNode, error) {
+	if role != "" {
+		return NodesWithRole(plan, role)
+	}
+	nodes := []ListableNode{}
+	for _, n := range plan.GetUniqueNodes() {
+		nodes = append(nodes, ListableNode{Node: n, Roles: plan.GetRolesForIP(n.IP)})
+	}
+	return nodes, nil
+}
+
+// DiffPackages returns the packages that were installed, updated or removed between
+// the two package lists, sorted by name. Packages that can have many versions installed,
+// such as the kernel, are compared by the set of versions that are installed.
+func DiffPackages(before, after []check.PackageQuery) []PackageChange {
+	b := packageVersions(before)
+	a := packageVersions(after)
+	names := map[string]bool{}
+	for n := range b {
+		names[n] = true
+	}
+	for n := range a {
+		names[n] = true
+	}
+	changes := []PackageChange{}
+	for n := range names {
+		removed := versionsNotIn(b[n], a[n])
+		added := versionsNotIn(a[n], b[n])
+		if len(removed) == 0 && len(added) == 0 {
+			continue
+		}
+		changes = append(changes, PackageChange{Name: n, Before: strings.Join(removed, ", "), After: strings.Join(added, ", ")})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func packageVersions(pkgs []check.PackageQuery) map[string]map[string]bool {
+	m := map[string]map[string]bool{}
+	for _, p := range pkgs {
+		if m[p.Name] == nil {
+			m[p.Name] = map[string]bool{}
+		}
+		m[p.Name][p.Version] = true
+	}
+	return m
+}
+
+// returns the versions in a that are not in b, sorted
+func versionsNotIn(a, b map[string]bool) []string {
+	versions := []string{}
+	for v := range a {
+		if !b[v] {
+			versions = append(versions, v)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// verifyPinnedPackages returns an error if a held package was changed, or
+// if kubelet is not installed at the version in the cluster catalog. The version
+// of kubelet is not verified when Kismatic does not install the packages.
+func verifyPinnedPackages(changes []PackageChange, after []check.PackageQuery, cc ansible.ClusterCatalog) error {
+	held := []string{}
+	for _, c := range changes {
+		if c.Held() {
+			held = append(held, c.Name)
+		}
+	}
+	if len(held) > 0 {
+		return fmt.Errorf("pinned packages were changed: %s", strings.Join(held, ", "))
+	}
+	if !cc.EnablePackageInstallation {
+		return nil
+	}
+	for _, p := range after {
+		if p.Name == "kubelet" && p.Version != cc.Versions.KubernetesYum && p.Version != cc.Versions.KubernetesDeb {
+			return fmt.Errorf("kubelet is at version %q, expected %q", p.Version, cc.Versions.Kubernetes)
+		}
+	}
+	return nil
+}
+
+func readPackageList(file string) ([]check.PackageQuery, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading package list: %v", err)
+	}
+	var pkgs []check.PackageQuery
+	if err := json.Unmarshal(b, &pkgs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling package list %q: %v", file, err)
+	}
+	return pkgs, nil
+}
+
+// UpdateOSPackages updates the OS packages of the nodes, one batch at a time (see OnlineUpgradeBatches).
+// The OSUpdateHeldPackages are not updated. The nodes are drained before the update, and rebooted if
+// a newer kernel was installed. The health of the cluster is verified before and after every batch, and
+// the worker nodes are uncordoned once Ready. The packages installed on every node before and after the update
+// are recorded in the generated assets directory. The rollout stops at the first batch that fails, and
+// the results of the batches that were updated are returned.
+func (ae *ansibleExecutor) UpdateOSPackages(plan Plan, batches []UpgradeBatch, rebootTimeout time.Duration) ([]OSUpdateResult, error) {
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Join(ae.options.GeneratedAssetsDirectory, osUpdatesDirName, time.Now().Format("2006-01-02-15-04-05")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine absolute path to the OS updates directory: %v", err)
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating OS updates directory: %v", err)
+	}
+	cc.OSUpdateDirectory = dir
+	cc.OSUpdateHeldPackages = OSUpdateHeldPackages
+	cc.RebootTimeoutSeconds = int(rebootTimeout.Seconds())
+	results := []OSUpdateResult{}
+	for i, batch := range batches {
+		hosts := batch.hosts()
+		util.PrintHeader(ae.stdout, fmt.Sprintf("Update OS Packages (%d/%d): %s", i+1, len(batches), strings.Join(hosts, ", ")), '=')
+		t := task{
+			name:           "os-update",
+			playbook:       "os-update.yaml",
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			plan:           plan,
+			explainer:      ae.defaultExplainer(),
+			limit:          hosts,
+		}
+		if err = ae.execute(t); err != nil {
+			return results, fmt.Errorf("error updating nodes %s, stopping the rollout: %v", strings.Join(hosts, ", "), err)
+		}
+		if ae.options.DryRun {
+			continue
+		}
+		for _, h := range hosts {
+			r, err := osUpdateResult(filepath.Join(dir, h), h, *cc)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, *r)
+		}
+	}
+	return results, nil
+}
+
+func osUpdateResult(dir string, host string, cc ansible.ClusterCatalog) (*OSUpdateResult, error) {
+	before, err := readPackageList(filepath.Join(dir, "packages-before.json"))
+	if err != nil {
+		return nil, err
+	}
+	after, err := readPackageList(filepath.Join(dir, "packages-after.json"))
+	if err != nil {
+		return nil, err
+	}
+	changes := DiffPackages(before, after)
+	return &OSUpdateResult{
+		Host:    host,
+		Changes: changes,
+		Err:     verifyPinnedPackages(changes, after, cc),
+	}, nil
+}
@@ -0,0 +1,98 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/inspector/check"
+)
+
+func TestDiffPackages(t *testing.T) {
+	before := []check.PackageQuery{
+		{Name: "kernel", Version: "3.10.0-862.el7"},
+		{Name: "openssl", Version: "1.0.2k-12.el7"},
+		{Name: "kubelet", Version: "1.10.5-0"},
+		{Name: "telnet", Version: "0.17-64.el7"},
+	}
+	after := []check.PackageQuery{
+		{Name: "kernel", Version: "3.10.0-862.el7"},
+		{Name: "kernel", Version: "3.10.0-957.el7"},
+		{Name: "openssl", Version: "1.0.2k-16.el7"},
+		{Name: "kubelet", Version: "1.10.5-0"},
+		{Name: "vim", Version: "7.4.160-5.el7"},
+	}
+	expected := []PackageChange{
+		{Name: "kernel", After: "3.10.0-957.el7"},
+		{Name: "openssl", Before: "1.0.2k-12.el7", After: "1.0.2k-16.el7"},
+		{Name: "telnet", Before: "0.17-64.el7"},
+		{Name: "vim", After: "7.4.160-5.el7"},
+	}
+	changes := DiffPackages(before, after)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, but got %v", expected, changes)
+	}
+}
+
+func TestVerifyPinnedPackages(t *testing.T) {
+	cc := ansible.ClusterCatalog{EnablePackageInstallation: true}
+	cc.Versions.Kubernetes = "v1.10.5"
+	cc.Versions.KubernetesYum = "1.10.5-0"
+	cc.Versions.KubernetesDeb = "1.10.5-00"
+	tests := []struct {
+		name                       string
+		changes                    []PackageChange
+		after                      []check.PackageQuery
+		disablePackageInstallation bool
+		expectError                bool
+	}{
+		{
+			name:    "unpinned package changed",
+			changes: []PackageChange{{Name: "openssl", Before: "1", After: "2"}},
+			after:   []check.PackageQuery{{Name: "kubelet", Version: "1.10.5-00"}},
+		},
+		{
+			name:        "held package changed",
+			changes:     []PackageChange{{Name: "docker-ce", Before: "17.03.2.ce-1.el7.centos", After: "18.09.0-3.el7"}},
+			expectError: true,
+		},
+		{
+			name:        "kubelet at another version",
+			after:       []check.PackageQuery{{Name: "kubelet", Version: "1.11.0-0"}},
+			expectError: true,
+		},
+		{
+			name:                       "kubelet at another version without package installation",
+			after:                      []check.PackageQuery{{Name: "kubelet", Version: "1.11.0-0"}},
+			disablePackageInstallation: true,
+		},
+		{
+			name: "etcd node without kubelet",
+		},
+	}
+	for _, test := range tests {
+		cc.EnablePackageInstallation = !test.disablePackageInstallation
+		err := verifyPinnedPackages(test.changes, test.after, cc)
+		if (err != nil) != test.expectError {
+			t.Errorf("%s: expected error %v, but got %v", test.name, test.expectError, err)
+		}
+	}
+}
+
+func TestOSUpdateNodes(t *testing.T) {
+	plan := removeNodeTestPlan()
+	nodes, err := OSUpdateNodes(plan, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 6 {
+		t.Errorf("expected all 6 nodes, but got %d", len(nodes))
+	}
+	nodes, err = OSUpdateNodes(plan, "master")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Node.Host != "master01" {
+		t.Errorf("expected master01, but got %v", nodes)
+	}
+}
@@ -1,6 +1,7 @@
 package install
 
 import (
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -44,8 +45,12 @@ type PKI interface {
 	GetProxyClientCA() (*tls.CA, error)
 	GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, proxyClientCA *tls.CA) error
 	NodeCertificateExists(node Node) (bool, error)
+	RemoveNodeCertificates(node Node) error
 	GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) error
-	GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error)
+	RegenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) (bool, error)
+	GenerateCertificate(name string, certs CertsConfig, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error)
+	RotateCertificates(p *Plan, components []string, clusterCA *tls.CA, proxyClientCA *tls.CA) ([]string, error)
+	PrepareCARotationPhase(p *Plan, phase string) error
 }
 
 // LocalPKI is a file-based PKI
@@ -62,8 +67,17 @@ type certificateSpec struct {
 	subjectAlternateNames []string
 	organizations         []string
 	ca                    *tls.CA
+	// the extended key usages that the certificate must have
+	usages []x509.ExtKeyUsage
 }
 
+// the extended key usages of the certificates
+var (
+	serverAuthUsages       = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	clientAuthUsages       = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	serverClientAuthUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+)
+
 func (s certificateSpec) equal(other certificateSpec) bool {
 	prelimEqual := s.description == other.description &&
 		s.filename == other.filename &&
@@ -110,7 +124,11 @@ func (s certificateSpec) equal(other certificateSpec) bool {
 
 // CertificateAuthorityExists returns true if the CA for the cluster exists
 func (lp *LocalPKI) CertificateAuthorityExists() (bool, error) {
-	return tls.CertKeyPairExists("ca", lp.GeneratedCertsDirectory)
+	exists, err := tls.CertKeyPairExists("ca", lp.GeneratedCertsDirectory)
+	if err != nil || exists {
+		return exists, err
+	}
+	return externalCAExists("ca", lp.GeneratedCertsDirectory)
 }
 
 // GenerateClusterCA creates a Certificate Authority for the cluster
@@ -120,12 +138,34 @@ func (lp *LocalPKI) GenerateClusterCA(p *Plan) (*tls.CA, error) {
 		return nil, fmt.Errorf("error verifying CA certificate/key: %v", err)
 	}
 	if exists {
+		if err = writeCAChain(p, lp.GeneratedCertsDirectory); err != nil {
+			return nil, err
+		}
+		return lp.GetClusterCA()
+	}
+	// the certificates were signed outside of kismatic and imported
+	external, err := externalCAExists("ca", lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying CA certificate: %v", err)
+	}
+	if external {
+		util.PrettyPrintOk(lp.Log, "Using imported cluster Certificate Authority")
 		return lp.GetClusterCA()
 	}
 
+	// the CA is provided in the plan file, it is never generated
+	if p.Cluster.Certificates.CACert != "" {
+		util.PrettyPrintOk(lp.Log, "Using cluster Certificate Authority %q", p.Cluster.Certificates.CACert)
+		ca, err := importConfiguredCA(p, lp.GeneratedCertsDirectory, "ca")
+		if err != nil {
+			return nil, err
+		}
+		return ca, writeCAChain(p, lp.GeneratedCertsDirectory)
+	}
+
 	// CA keypair doesn't exist, generate one
 	util.PrettyPrintOk(lp.Log, "Generating cluster Certificate Authority")
-	key, cert, err := tls.NewCACert(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry)
+	key, cert, err := tls.NewCACert(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.CAKeyAlgorithm, p.Cluster.Certificates.Subject.tlsSubject())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CA Cert: %v", err)
 	}
@@ -140,14 +180,11 @@ func (lp *LocalPKI) GenerateClusterCA(p *Plan) (*tls.CA, error) {
 
 // GetClusterCA returns the cluster CA
 func (lp *LocalPKI) GetClusterCA() (*tls.CA, error) {
-	key, cert, err := tls.ReadCACert("ca", lp.GeneratedCertsDirectory)
+	ca, err := readCA("ca", lp.GeneratedCertsDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("error reading CA certificate/key: %v", err)
 	}
-	return &tls.CA{
-		Cert: cert,
-		Key:  key,
-	}, nil
+	return ca, nil
 }
 
 // GenerateProxyClientCA creates a Certificate Authority for the cluster
@@ -159,10 +196,18 @@ func (lp *LocalPKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 	if exists {
 		return lp.GetProxyClientCA()
 	}
+	external, err := externalCAExists("proxy-client-ca", lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying proxy-client CA certificate: %v", err)
+	}
+	if external {
+		util.PrettyPrintOk(lp.Log, "Using imported proxy-client Certificate Authority")
+		return lp.GetProxyClientCA()
+	}
 
 	// CA keypair doesn't exist, generate one
 	util.PrettyPrintOk(lp.Log, "Generating proxy-client Certificate Authority")
-	key, cert, err := tls.NewCACert(lp.CACsr, proxyClientCACommonName, p.Cluster.Certificates.CAExpiry)
+	key, cert, err := tls.NewCACert(lp.CACsr, proxyClientCACommonName, p.Cluster.Certificates.CAExpiry, p.Cluster.Certificates.CAKeyAlgorithm, p.Cluster.Certificates.Subject.tlsSubject())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy-client CA Cert: %v", err)
 	}
@@ -177,14 +222,11 @@ func (lp *LocalPKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 
 // GetProxyClientCA returns the cluster CA
 func (lp *LocalPKI) GetProxyClientCA() (*tls.CA, error) {
-	key, cert, err := tls.ReadCACert("proxy-client-ca", lp.GeneratedCertsDirectory)
+	ca, err := readCA("proxy-client-ca", lp.GeneratedCertsDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("error reading proxy-client CA certificate/key: %v", err)
 	}
-	return &tls.CA{
-		Cert: cert,
-		Key:  key,
-	}, nil
+	return ca, nil
 }
 
 // GenerateClusterCertificates creates all certificates required for the cluster
@@ -229,13 +271,16 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 				util.PrintValidationErrors(lp.Log, warnings)
 				return fmt.Errorf("invalid certificate found for %q", s.description)
 			}
+			if err = lp.warnKeyAlgorithm(p.Cluster.Certificates.KeyAlgorithm, s, lp.GeneratedCertsDirectory); err != nil {
+				return err
+			}
 			// This cert is valid, move onto the next certificate
 			util.PrettyPrintOk(lp.Log, "Found valid certificate for %s", s.description)
 			continue
 		}
 
 		// Cert doesn't exist. Generate it
-		if err := generateCert(lp.GeneratedCertsDirectory, s, p.Cluster.Certificates.Expiry); err != nil {
+		if err := generateCert(lp.GeneratedCertsDirectory, s, p.Cluster.Certificates); err != nil {
 			return err
 		}
 		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
@@ -293,6 +338,10 @@ func (lp *LocalPKI) ValidateClusterCertificates(p *Plan) (warns []error, errs []
 		if len(warn) > 0 {
 			warns = append(warns, warn...)
 		}
+		// the certificates are only replaced with the new algorithm when rotated
+		if err = lp.warnKeyAlgorithm(p.Cluster.Certificates.KeyAlgorithm, s, lp.GeneratedCertsDirectory); err != nil {
+			errs = append(errs, err)
+		}
 	}
 	return warns, errs
 }
@@ -302,6 +351,21 @@ func (lp *LocalPKI) NodeCertificateExists(node Node) (bool, error) {
 	return tls.CertKeyPairExists(node.Host, lp.GeneratedCertsDirectory)
 }
 
+// RemoveNodeCertificates deletes the private keys and certificates that were
+// generated specifically for the node
+func (lp *LocalPKI) RemoveNodeCertificates(node Node) error {
+	for _, suffix := range []string{"kubelet", "etcd", "apiserver"} {
+		name := fmt.Sprintf("%s-%s", node.Host, suffix)
+		for _, file := range []string{name + ".pem", name + "-key.pem"} {
+			err := os.Remove(filepath.Join(lp.GeneratedCertsDirectory, file))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // GenerateNodeCertificate creates a private key and certificate for the given node
 func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) error {
 	m, err := node.certSpecs(*plan, ca)
@@ -323,12 +387,15 @@ func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) e
 				util.PrintValidationErrors(lp.Log, warn)
 				return fmt.Errorf("invalid certificate found for %q", s.description)
 			}
+			if err = lp.warnKeyAlgorithm(plan.Cluster.Certificates.KeyAlgorithm, s, lp.GeneratedCertsDirectory); err != nil {
+				return err
+			}
 			// This cert is valid, move on
 			util.PrettyPrintOk(lp.Log, "Found valid certificate for %s", s.description)
 			continue
 		}
 		// Cert doesn't exist. Generate it
-		if err := generateCert(lp.GeneratedCertsDirectory, s, plan.Cluster.Certificates.Expiry); err != nil {
+		if err := generateCert(lp.GeneratedCertsDirectory, s, plan.Cluster.Certificates); err != nil {
 			return err
 		}
 		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
@@ -336,15 +403,66 @@ func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) e
 	return nil
 }
 
+// RegenerateNodeCertificate creates the node's private keys and certificates that
+// are missing, and replaces the ones that are no longer valid for the plan, e.g.
+// when the load balanced address of the master nodes has changed.
+// Returns true if any certificate was generated.
+func (lp *LocalPKI) RegenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) (bool, error) {
+	m, err := node.certSpecs(*plan, ca)
+	if err != nil {
+		return false, err
+	}
+	generated := false
+	for _, s := range m {
+		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			warn, err := tls.CertValid(s.commonName, s.subjectAlternateNames, s.organizations, s.filename, lp.GeneratedCertsDirectory)
+			if err != nil {
+				return false, err
+			}
+			if len(warn) == 0 {
+				if err = lp.warnKeyAlgorithm(plan.Cluster.Certificates.KeyAlgorithm, s, lp.GeneratedCertsDirectory); err != nil {
+					return false, err
+				}
+				continue
+			}
+			util.PrettyPrintWarn(lp.Log, "Found certificate for %s, but it is not valid. Regenerating.", s.description)
+		}
+		if err := generateCert(lp.GeneratedCertsDirectory, s, plan.Cluster.Certificates); err != nil {
+			return false, err
+		}
+		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
+		generated = true
+	}
+	return generated, nil
+}
+
+// warns when the key algorithm of the existing certificate is not the key algorithm of the
+// plan file. The certificate remains valid, and the new algorithm is used when it is rotated.
+func (lp *LocalPKI) warnKeyAlgorithm(keyAlgorithm string, s certificateSpec, dir string) error {
+	warn, err := tls.CertKeyAlgorithmValid(keyAlgorithm, s.filename, dir)
+	if err != nil {
+		return err
+	}
+	if warn != nil {
+		util.PrettyPrintWarn(lp.Log, "%v. Use \"kismatic certificates rotate\" to replace it", warn)
+	}
+	return nil
+}
+
 // GenerateCertificate creates a private key and certificate for the given name, CN, subjectAlternateNames and organizations
+// The expiry, key algorithm and subject of the certificate are the ones of the certificates config
 // If cert exists, will not fail
 // Pass overwrite to replace an existing cert
-func (lp *LocalPKI) GenerateCertificate(name string, validityPeriod string, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
+func (lp *LocalPKI) GenerateCertificate(name string, certs CertsConfig, commonName string, subjectAlternateNames []string, organizations []string, ca *tls.CA, overwrite bool) (bool, error) {
 	if name == "" {
 		return false, fmt.Errorf("name cannot be empty")
 	}
-	if validityPeriod == "" {
-		return false, fmt.Errorf("validityPeriod cannot be empty")
+	if certs.Expiry == "" {
+		return false, fmt.Errorf("expiry cannot be empty")
 	}
 	if ca == nil {
 		return false, fmt.Errorf("ca cannot be nil")
@@ -368,43 +486,74 @@ func (lp *LocalPKI) GenerateCertificate(name string, validityPeriod string, comm
 		ca:                    ca,
 	}
 
-	if err := generateCert(lp.GeneratedCertsDirectory, spec, validityPeriod); err != nil {
+	if err := generateCert(lp.GeneratedCertsDirectory, spec, certs); err != nil {
 		return exists, fmt.Errorf("could not generate certificate %s: %v", name, err)
 	}
 
 	return exists, nil
 }
 
-func generateCert(certDir string, spec certificateSpec, expiryStr string) error {
-	expiry, err := time.ParseDuration(expiryStr)
+func generateCert(certDir string, spec certificateSpec, certs CertsConfig) error {
+	expiry, err := time.ParseDuration(certs.Expiry)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration for certificate expiry", certs.Expiry)
+	}
+	// the certificates of a CA without a key are signed outside of kismatic
+	if len(spec.ca.Key) == 0 {
+		return fmt.Errorf("the certificate for %q is missing, and the key of its CA is not available. "+
+			"Use \"kismatic certificates export-csrs\" and \"kismatic certificates import\" to add the certificate", spec.description)
+	}
+	req, err := certificateRequest(spec, certs)
+	if err != nil {
+		return err
+	}
+	key, cert, err := tls.NewCert(spec.ca, req, expiry)
+	if err != nil {
+		return fmt.Errorf("error generating certs for %q: %v", spec.description, err)
+	}
+	if err = tls.WriteCert(key, cert, spec.filename, certDir); err != nil {
+		return fmt.Errorf("error writing cert for %q: %v", spec.description, err)
+	}
+	return nil
+}
+
+func certificateRequest(spec certificateSpec, certs CertsConfig) (csr.CertificateRequest, error) {
+	keyRequest, err := tls.NewKeyRequest(certs.KeyAlgorithm)
 	if err != nil {
-		return fmt.Errorf("%q is not a valid duration for certificate expiry", expiryStr)
+		return csr.CertificateRequest{}, err
 	}
 	req := csr.CertificateRequest{
-		CN: spec.commonName,
-		KeyRequest: &csr.BasicKeyRequest{
-			A: "rsa",
-			S: 2048,
-		},
+		CN:         spec.commonName,
+		KeyRequest: keyRequest,
 	}
 
 	if len(spec.subjectAlternateNames) > 0 {
 		req.Hosts = spec.subjectAlternateNames
 	}
 
+	// the organizations are the groups of the user in Kubernetes, they are
+	// only set by the spec
+	subject := certs.Subject.tlsSubject().Name()
+	subject.O = ""
+	if !csr.IsNameEmpty(subject) {
+		req.Names = append(req.Names, subject)
+	}
+
 	for _, org := range spec.organizations {
 		name := csr.Name{O: org}
 		req.Names = append(req.Names, name)
 	}
+	return req, nil
+}
 
-	key, cert, err := tls.NewCert(spec.ca, req, expiry)
-	if err != nil {
-		return fmt.Errorf("error generating certs for %q: %v", spec.description, err)
+func (s CertificateSubject) tlsSubject() tls.Subject {
+	return tls.Subject{
+		Country:            s.Country,
+		State:              s.State,
+		Locality:           s.Locality,
+		Organization:       s.Organization,
+		OrganizationalUnit: s.OrganizationalUnit,
 	}
-	if err = tls.WriteCert(key, cert, spec.filename, certDir); err != nil {
-		return fmt.Errorf("error writing cert for %q: %v", spec.description, err)
-	}
-	return nil
 }
 
 func clusterCertsSubjectAlternateNames(plan Plan) ([]string, error) {
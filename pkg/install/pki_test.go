@@ -1,6 +1,7 @@
 package install
 
 import (
+	"bytes"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
@@ -567,6 +568,70 @@ func TestGenerateClusterCertificatesPlanFileExpirationIsRespected(t *testing.T)
 	}
 }
 
+func TestGenerateCertificatesPlanFileKeyAlgorithmAndSubjectAreRespected(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+
+	p := getPlan()
+	p.Cluster.Certificates.CAKeyAlgorithm = tls.KeyAlgorithmECDSAP384
+	p.Cluster.Certificates.KeyAlgorithm = tls.KeyAlgorithmECDSAP256
+	p.Cluster.Certificates.Subject = CertificateSubject{Country: "CA", Organization: "Acme", OrganizationalUnit: "Platform"}
+
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	caCert := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "ca.pem"), t)
+	if algorithm := tls.KeyAlgorithm(caCert); algorithm != tls.KeyAlgorithmECDSAP384 {
+		t.Errorf("expected CA key algorithm %q, got %q", tls.KeyAlgorithmECDSAP384, algorithm)
+	}
+	if !reflect.DeepEqual(caCert.Subject.Organization, []string{"Acme"}) {
+		t.Errorf("expected CA organization %q, got %v", "Acme", caCert.Subject.Organization)
+	}
+	node := p.Master.Nodes[0]
+	if err = pki.GenerateNodeCertificate(p, node, ca); err != nil {
+		t.Fatalf("failed to generate certificate for node: %v", err)
+	}
+	cert := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, fmt.Sprintf("%s-kubelet.pem", node.Host)), t)
+	if algorithm := tls.KeyAlgorithm(cert); algorithm != tls.KeyAlgorithmECDSAP256 {
+		t.Errorf("expected key algorithm %q, got %q", tls.KeyAlgorithmECDSAP256, algorithm)
+	}
+	if !reflect.DeepEqual(cert.Subject.Country, []string{"CA"}) || !reflect.DeepEqual(cert.Subject.OrganizationalUnit, []string{"Platform"}) {
+		t.Errorf("expected the subject of the plan file, got %v", cert.Subject)
+	}
+	// the organizations of the certificate are the groups of the node
+	if !reflect.DeepEqual(cert.Subject.Organization, []string{kubeletGroup}) {
+		t.Errorf("expected organization %q, got %v", kubeletGroup, cert.Subject.Organization)
+	}
+
+	// GenerateCertificate uses the configuration of the plan file
+	p.Cluster.Certificates.Expiry = "1h"
+	if _, err = pki.GenerateCertificate("alice", p.Cluster.Certificates, "alice", nil, []string{"dev"}, ca, false); err != nil {
+		t.Fatalf("error generating certificate: %v", err)
+	}
+	cert = mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "alice.pem"), t)
+	if algorithm := tls.KeyAlgorithm(cert); algorithm != tls.KeyAlgorithmECDSAP256 {
+		t.Errorf("expected key algorithm %q, got %q", tls.KeyAlgorithmECDSAP256, algorithm)
+	}
+	if !reflect.DeepEqual(cert.Subject.Country, []string{"CA"}) || !reflect.DeepEqual(cert.Subject.Organization, []string{"dev"}) {
+		t.Errorf("expected the subject of the plan file, got %v", cert.Subject)
+	}
+
+	// the existing certificates remain valid for another algorithm, with a warning
+	p.Cluster.Certificates.KeyAlgorithm = tls.KeyAlgorithmRSA2048
+	log := &bytes.Buffer{}
+	pki.Log = log
+	if err = pki.GenerateNodeCertificate(p, node, ca); err != nil {
+		t.Errorf("unexpected error when the key algorithm of the plan file changed: %v", err)
+	}
+	if !strings.Contains(log.String(), "key algorithm") {
+		t.Errorf("expected a key algorithm warning, got %q", log.String())
+	}
+	if warns, errs := pki.ValidateClusterCertificates(p); len(warns) > 0 || len(errs) > 0 {
+		t.Errorf("expected the key algorithm not to fail validation, got %v %v", warns, errs)
+	}
+}
+
 func validateClientCertificateAndKey(certsDir, filename, expectedIssuer string, expectedCommonName string, expectedSubjectAlternateNames []string, expectedOrganizations ...string) func(t *testing.T) {
 	return func(t *testing.T) {
 		cert := mustReadCertFile(filepath.Join(certsDir, filename), t)
@@ -1105,7 +1170,7 @@ func TestGenerateCertificate(t *testing.T) {
 		},
 	}
 	for i, test := range tests {
-		exists, err := pki.GenerateCertificate(test.name, test.validityPeriod, test.commonName, test.subjectAlternateNames, test.organizations, test.ca, test.overwrite)
+		exists, err := pki.GenerateCertificate(test.name, CertsConfig{Expiry: test.validityPeriod}, test.commonName, test.subjectAlternateNames, test.organizations, test.ca, test.overwrite)
 
 		if (err != nil) == test.valid {
 			t.Errorf("test %d: expect valid to be %t, but got %v", i, test.valid, err)
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/apprenda/kismatic/pkg/tls"
 	"github.com/apprenda/kismatic/pkg/util"
 
 	yaml "gopkg.in/yaml.v2"
@@ -157,6 +158,10 @@ func setDefaults(p *Plan) {
 		p.Docker.Storage.DirectLVMBlockDevice.ThinpoolAutoextendPercent = "20"
 	}
 
+	if p.Cluster.EncryptionAtRest.Provider == "" {
+		p.Cluster.EncryptionAtRest.Provider = encryptionProviderAESCBC
+	}
+
 	if p.AddOns.CNI == nil {
 		p.AddOns.CNI = &CNI{}
 		p.AddOns.CNI.Provider = cniProviderCalico
@@ -212,6 +217,12 @@ func setDefaults(p *Plan) {
 	if p.Cluster.Certificates.CAExpiry == "" {
 		p.Cluster.Certificates.CAExpiry = defaultCAExpiry
 	}
+	if p.Cluster.Certificates.KeyAlgorithm == "" {
+		p.Cluster.Certificates.KeyAlgorithm = tls.KeyAlgorithmRSA2048
+	}
+	if p.Cluster.Certificates.CAKeyAlgorithm == "" {
+		p.Cluster.Certificates.CAKeyAlgorithm = tls.KeyAlgorithmRSA2048
+	}
 
 	if p.AddOns.Dashboard.Options.ServiceType == "" {
 		p.AddOns.Dashboard.Options.ServiceType = "ClusterIP"
@@ -225,7 +236,8 @@ func setDefaults(p *Plan) {
 
 var yamlKeyRE = regexp.MustCompile(`[^a-zA-Z]*([a-z_\-\/A-Z.\d]+)[ ]*:`)
 
-// Write the plan to the file system
+// Write the plan to the file system. The plan is written to a temporary file
+// that replaces the plan file, so that the plan file is never left half written.
 func (fp *FilePlanner) Write(p *Plan) error {
 	// make a copy of the global comment map
 	oneTimeComments := map[string][]string{}
@@ -237,11 +249,7 @@ func (fp *FilePlanner) Write(p *Plan) error {
 		return fmt.Errorf("error marshalling plan to yaml: %v", marshalErr)
 	}
 
-	f, err := os.Create(fp.File)
-	if err != nil {
-		return fmt.Errorf("error making plan file: %v", err)
-	}
-	defer f.Close()
+	f := &bytes.Buffer{}
 
 	// the stack keeps track of the object we are in
 	// for example, when we are inside cluster.networking, looking at the key 'foo'
@@ -306,6 +314,14 @@ func (fp *FilePlanner) Write(p *Plan) error {
 		addNewLineBeforeComment = true
 	}
 
+	tmpFile := fp.File + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, f.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error making plan file: %v", err)
+	}
+	if err := os.Rename(tmpFile, fp.File); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("error making plan file: %v", err)
+	}
 	return nil
 }
 
@@ -376,6 +392,12 @@ func buildPlanFromTemplateOptions(templateOpts PlanTemplateOptions) Plan {
 	// Set Certificate defaults
 	p.Cluster.Certificates.Expiry = "17520h"
 	p.Cluster.Certificates.CAExpiry = defaultCAExpiry
+	p.Cluster.Certificates.KeyAlgorithm = tls.KeyAlgorithmRSA2048
+	p.Cluster.Certificates.CAKeyAlgorithm = tls.KeyAlgorithmRSA2048
+
+	// Encryption at rest defaults
+	p.Cluster.EncryptionAtRest.Enabled = false
+	p.Cluster.EncryptionAtRest.Provider = encryptionProviderAESCBC
 
 	// Docker
 	p.Docker.Logs = DockerLogs{
@@ -491,6 +513,12 @@ var commentMap = map[string][]string{
 	"cluster.certificates.expiry":                        []string{"Self-signed certificate expiration period in hours; default is 2 years."},
 	"cluster.certificates.ca_expiry":                     []string{"CA certificate expiration period in hours; default is 2 years."},
 	"cluster.certificates.apiserver_cert_extra_sans":     []string{"Optional extra Subject Alternative Names (SANs) to use for the API Server serving certificate.", "Can be both IP addresses and DNS names."},
+	"cluster.certificates.ca_cert":                       []string{"Optional path to the certificate of an existing CA, used instead of generating a CA.", "Can be an intermediate CA, followed by the certificates of its issuers."},
+	"cluster.certificates.ca_key":                        []string{"Path to the private key of the existing CA; required when ca_cert is set."},
+	"cluster.certificates.ca_chain":                      []string{"Optional path to the certificates of the CAs that issued the existing CA, up to the root CA."},
+	"cluster.certificates.key_algorithm":                 []string{"Algorithm of the private keys of the generated certificates.", "Options: 'rsa-2048','rsa-4096','ecdsa-p256','ecdsa-p384'."},
+	"cluster.certificates.ca_key_algorithm":              []string{"Algorithm of the private keys of the generated CAs.", "Options: 'rsa-2048','rsa-4096','ecdsa-p256','ecdsa-p384'."},
+	"cluster.certificates.subject":                       []string{"Optional X.509 Subject fields of the generated certificates. The organization", "is only set on the CAs, as the organizations of the other certificates are", "the groups of their users in Kubernetes."},
 	"cluster.ssh":                                        []string{"SSH configuration for cluster nodes."},
 	"cluster.ssh.user":                                   []string{"This user must be able to sudo without password."},
 	"cluster.ssh.ssh_key":                                []string{"Absolute path to the ssh private key we should use to manage nodes."},
@@ -498,6 +526,9 @@ var commentMap = map[string][]string{
 	"cluster.cloud_provider":                             []string{"Kubernetes cloud provider integration."},
 	"cluster.cloud_provider.provider":                    []string{"Options: 'aws','azure','cloudstack','fake','gce','mesos','openstack',", "'ovirt','photon','rackspace','vsphere'.", "Leave empty for bare metal setups or other unsupported providers."},
 	"cluster.cloud_provider.config":                      []string{"Path to the config file, leave empty if provider does not require it."},
+	"cluster.encryption_at_rest":                         []string{"Encryption of the Kubernetes secrets before they are stored in etcd."},
+	"cluster.encryption_at_rest.enabled":                 []string{"Set to true to encrypt the secrets. The encryption key is generated", "in the generated assets directory, keep it safe."},
+	"cluster.encryption_at_rest.provider":                []string{"Options: 'aescbc','secretbox'."},
 	"docker":                                             []string{"Docker daemon configuration of all cluster nodes."},
 	"docker.disable":                                     []string{"Set to true if docker is already installed and configured."},
 	"docker.storage.driver":                              []string{"Leave empty to have docker automatically select the driver."},
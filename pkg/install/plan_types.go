@@ -137,6 +137,8 @@ type Cluster struct {
 	KubeletOptions KubeletOptions `yaml:"kubelet"`
 	// The CloudProvider configuration for the cluster.
 	CloudProvider CloudProvider `yaml:"cloud_provider"`
+	// The encryption at rest configuration of the Kubernetes secrets.
+	EncryptionAtRest EncryptionAtRest `yaml:"encryption_at_rest"`
 }
 
 type APIServerOptions struct {
@@ -214,6 +216,56 @@ type CertsConfig struct {
 	// Comma-separated list of Subject Alternative Names (SANs) to use for the API Server serving certificate.
 	// Can be both IP addresses and DNS names.
 	APIServerCertExtraSANs string `yaml:"apiserver_cert_extra_sans"`
+	// Path to the certificate of an existing CA that signs the cluster certificates,
+	// in PEM format. When set, Kismatic does not generate the cluster CA.
+	// The CA can be an intermediate CA, in which case the certificates of the
+	// issuing CAs must be set in ca_chain, or follow the CA certificate in this file.
+	CACert string `yaml:"ca_cert"`
+	// Path to the private key of the existing CA, in PEM format.
+	// Required when ca_cert is set.
+	CAKey string `yaml:"ca_key"`
+	// Path to the certificates of the CAs that issued the existing CA, in PEM format,
+	// up to and including the root CA.
+	CAChain string `yaml:"ca_chain"`
+	// The algorithm of the private keys of the generated certificates.
+	// +default=rsa-2048
+	// +options=rsa-2048,rsa-4096,ecdsa-p256,ecdsa-p384
+	KeyAlgorithm string `yaml:"key_algorithm"`
+	// The algorithm of the private keys of the generated Certificate Authorities.
+	// +default=rsa-2048
+	// +options=rsa-2048,rsa-4096,ecdsa-p256,ecdsa-p384
+	CAKeyAlgorithm string `yaml:"ca_key_algorithm"`
+	// The X.509 Subject fields of the generated certificates.
+	Subject CertificateSubject
+}
+
+// CertificateSubject describes the X.509 Subject fields of the generated certificates.
+// The organization is only set on the Certificate Authorities, as the organizations
+// of the other certificates are the groups of their users in Kubernetes.
+type CertificateSubject struct {
+	// The country (C) of the certificates.
+	Country string
+	// The state or province (ST) of the certificates.
+	State string
+	// The locality (L) of the certificates.
+	Locality string
+	// The organization (O) of the Certificate Authorities.
+	Organization string
+	// The organizational unit (OU) of the certificates.
+	OrganizationalUnit string `yaml:"organizational_unit"`
+}
+
+// EncryptionAtRest describes how the Kubernetes secrets are encrypted
+// before they are stored in etcd
+type EncryptionAtRest struct {
+	// Whether the secrets should be encrypted before they are stored in etcd.
+	// +default=false
+	Enabled bool
+	// The provider used to encrypt the secrets.
+	// Only used by new keys, when encryption is enabled and when the key is rotated.
+	// +default=aescbc
+	// +options=aescbc,secretbox
+	Provider string
 }
 
 // SSHConfig describes the cluster's SSH configuration for accessing nodes
@@ -954,6 +1006,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 			commonName:            node.Host,
 			subjectAlternateNames: san,
 			ca: ca,
+			usages:                serverClientAuthUsages,
 		})
 	}
 
@@ -989,6 +1042,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 			commonName:            node.Host,
 			subjectAlternateNames: san,
 			ca: ca,
+			usages:                serverAuthUsages,
 		})
 		// Controller manager certificate
 		m = append(m, certificateSpec{
@@ -996,6 +1050,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 			filename:    controllerManagerCertFilenamePrefix,
 			commonName:  controllerManagerUser,
 			ca:          ca,
+			usages:      clientAuthUsages,
 		})
 		// Scheduler client certificate
 		m = append(m, certificateSpec{
@@ -1003,6 +1058,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 			filename:    schedulerCertFilenamePrefix,
 			commonName:  schedulerUser,
 			ca:          ca,
+			usages:      clientAuthUsages,
 		})
 		// Certificate for signing service account tokens
 		m = append(m, certificateSpec{
@@ -1022,6 +1078,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 			subjectAlternateNames: node.KubeletAddresses(),
 			organizations:         []string{kubeletGroup},
 			ca:                    ca,
+			usages:                serverClientAuthUsages,
 		})
 
 		// etcd client certificate
@@ -1031,6 +1088,7 @@ func (node Node) certSpecs(plan Plan, ca *tls.CA) ([]certificateSpec, error) {
 			filename:    "etcd-client",
 			commonName:  "etcd-client",
 			ca:          ca,
+			usages:      clientAuthUsages,
 		})
 	}
 
@@ -1065,6 +1123,7 @@ func (plan Plan) certSpecs(clusterCA *tls.CA, proxyClientCA *tls.CA) ([]certific
 		commonName:    kubeAPIServerKubeletClientClientCommonName,
 		organizations: []string{adminGroup},
 		ca:            clusterCA,
+		usages:        clientAuthUsages,
 	})
 
 	// Proxy Client certificate
@@ -1074,6 +1133,7 @@ func (plan Plan) certSpecs(clusterCA *tls.CA, proxyClientCA *tls.CA) ([]certific
 		commonName:    proxyClientCertCommonName,
 		organizations: []string{adminGroup},
 		ca:            proxyClientCA,
+		usages:        clientAuthUsages,
 	})
 
 	// Contiv certificates
@@ -1083,6 +1143,7 @@ func (plan Plan) certSpecs(clusterCA *tls.CA, proxyClientCA *tls.CA) ([]certific
 			filename:    contivProxyServerCertFilename,
 			commonName:  "auth-local.cisco.com", // using the same as contiv install script
 			ca:          clusterCA,
+			usages:      serverAuthUsages,
 		})
 	}
 
@@ -1093,6 +1154,7 @@ func (plan Plan) certSpecs(clusterCA *tls.CA, proxyClientCA *tls.CA) ([]certific
 		commonName:    adminUser,
 		organizations: []string{adminGroup},
 		ca:            clusterCA,
+		usages:        clientAuthUsages,
 	})
 
 	return m, nil
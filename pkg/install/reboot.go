@@ -0,0 +1,71 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// RebootRoles are the roles whose nodes can be rebooted
+var RebootRoles = []string{"etcd", "master", "worker", "ingress", "storage"}
+
+// NodesWithRole returns the nodes of the plan that have the given role,
+// along with all the roles of each node
+func NodesWithRole(plan Plan, role string) ([]ListableNode, error) {
+	var nodes []Node
+	switch role {
+	case "etcd":
+		nodes = plan.Etcd.Nodes
+	case "master":
+		nodes = plan.Master.Nodes
+	case "worker":
+		nodes = plan.Worker.Nodes
+	case "ingress":
+		nodes = plan.Ingress.Nodes
+	case "storage":
+		nodes = plan.Storage.Nodes
+	default:
+		return nil, fmt.Errorf("invalid role %q, options %v", role, RebootRoles)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("there are no %s nodes in the plan file", role)
+	}
+	listable := []ListableNode{}
+	for _, n := range nodes {
+		listable = append(listable, ListableNode{Node: n, Roles: plan.GetRolesForIP(n.IP)})
+	}
+	return listable, nil
+}
+
+// RebootNodes reboots the nodes of the cluster, one batch at a time (see OnlineUpgradeBatches).
+// The etcd clusters must be healthy, and storage volumes healed, before the nodes of a batch go down.
+// Kubernetes nodes are drained before being rebooted. Once the nodes are back, the health of the etcd
+// clusters, the control plane and the storage volumes is verified, and the worker nodes are uncordoned once Ready.
+// The rollout stops at the first batch that fails.
+func (ae *ansibleExecutor) RebootNodes(plan Plan, batches []UpgradeBatch, timeout time.Duration) error {
+	inventory := buildInventoryFromPlan(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return err
+	}
+	cc.RebootTimeoutSeconds = int(timeout.Seconds())
+	for i, batch := range batches {
+		hosts := batch.hosts()
+		util.PrintHeader(ae.stdout, fmt.Sprintf("Reboot Nodes (%d/%d): %s", i+1, len(batches), strings.Join(hosts, ", ")), '=')
+		t := task{
+			name:           "reboot-nodes",
+			playbook:       "reboot-nodes.yaml",
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			plan:           plan,
+			explainer:      ae.defaultExplainer(),
+			limit:          hosts,
+		}
+		if err = ae.execute(t); err != nil {
+			return fmt.Errorf("error rebooting nodes %s, stopping the rollout: %v", strings.Join(hosts, ", "), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package install
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+func TestNodesWithRole(t *testing.T) {
+	plan := removeNodeTestPlan()
+	nodes, err := NodesWithRole(plan, "worker")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 worker nodes, but got %d", len(nodes))
+	}
+	if len(nodes[1].Roles) != 2 {
+		t.Errorf("expected worker02 to have the worker and ingress roles, but got %v", nodes[1].Roles)
+	}
+	plan.Storage = OptionalNodeGroup{}
+	for _, role := range []string{"storage", "foo"} {
+		if _, err := NodesWithRole(plan, role); err == nil {
+			t.Errorf("%s: expected an error, but got none", role)
+		}
+	}
+}
+
+func TestRebootNodesStopsAtFailedBatch(t *testing.T) {
+	runs := 0
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t)},
+		stdout:              ioutil.Discard,
+		consoleOutputFormat: ansible.RawFormat,
+		runnerExplainerFactory: func(explain.AnsibleEventExplainer, io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			runs++
+			return &fakeRunner{err: errors.New("exec error")}, &explain.AnsibleEventStreamExplainer{}, nil
+		},
+	}
+	plan := removeNodeTestPlan()
+	plan.Cluster.Networking.ServiceCIDRBlock = "10.0.0.0/16"
+	plan.Cluster.Version = "v1.10.5"
+	nodes, _ := NodesWithRole(plan, "worker")
+	batches := []UpgradeBatch{
+		{Phase: "worker", Nodes: nodes[:1]},
+		{Phase: "worker", Nodes: nodes[1:]},
+	}
+	if err := e.RebootNodes(plan, batches, time.Minute); err == nil {
+		t.Errorf("expected an error, but got none")
+	}
+	if runs != 1 {
+		t.Errorf("expected the rollout to stop after the first batch, but %d batches were run", runs)
+	}
+}
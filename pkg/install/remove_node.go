@@ -0,0 +1,286 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+type removeOnlyNodeErr struct {
+	role string
+}
+
+func (e removeOnlyNodeErr) Error() string {
+	return fmt.Sprintf("This is the only %s node in the cluster, and cannot be removed.", e.role)
+}
+
+type removeEtcdNodeCountErr struct{}
+
+func (e removeEtcdNodeCountErr) Error() string {
+	return "Removing this node will leave the etcd cluster with less than 3 members. " +
+		"The etcd cluster will not be able to tolerate the failure of a member."
+}
+
+type removeMasterNodeLoadBalancingErr struct{}
+
+func (e removeMasterNodeLoadBalancingErr) Error() string {
+	return "This node is acting as the load balanced endpoint for the master nodes. " +
+		"Removing it will make the cluster unavailable."
+}
+
+type removeIngressNodeErr struct{}
+
+func (e removeIngressNodeErr) Error() string {
+	return "Removing this node may result in service unavailability if clients are accessing services directly through this ingress point."
+}
+
+type removeLastWorkerNodeErr struct{}
+
+func (e removeLastWorkerNodeErr) Error() string {
+	return "This is the only worker node in the cluster. " +
+		"Removing it will make cluster features unavailable."
+}
+
+type removeLastStorageNodeErr struct{}
+
+func (e removeLastStorageNodeErr) Error() string {
+	return "This is the only storage node in the cluster. " +
+		"Removing it will make the storage cluster unavailable."
+}
+
+type brickOnRemovedNodeErr struct {
+	volume string
+	brick  string
+}
+
+func (e brickOnRemovedNodeErr) Error() string {
+	return fmt.Sprintf("Brick %q of volume %q is on this node. "+
+		"Move the brick to another node, or delete the volume, before removing this node.", e.brick, e.volume)
+}
+
+// RemoveNodeFromPlan returns a copy of the plan without the node with the given
+// host name, along with the removed node and the roles it had.
+// The only etcd or master node of the cluster cannot be removed.
+func RemoveNodeFromPlan(plan Plan, host string) (Plan, Node, []string, error) {
+	var node *Node
+	for _, n := range plan.GetUniqueNodes() {
+		if n.Host == host {
+			n := n
+			node = &n
+			break
+		}
+	}
+	if node == nil {
+		return plan, Node{}, nil, fmt.Errorf("node %q was not found in the plan file", host)
+	}
+	roles := plan.GetRolesForIP(node.IP)
+	if util.Contains("etcd", roles) {
+		if len(plan.Etcd.Nodes) < 2 {
+			return plan, *node, roles, removeOnlyNodeErr{role: "etcd"}
+		}
+		plan.Etcd.Nodes = nodesWithoutHost(plan.Etcd.Nodes, host)
+		plan.Etcd.ExpectedCount--
+	}
+	if util.Contains("master", roles) {
+		if len(plan.Master.Nodes) < 2 {
+			return plan, *node, roles, removeOnlyNodeErr{role: "master"}
+		}
+		plan.Master.Nodes = nodesWithoutHost(plan.Master.Nodes, host)
+		plan.Master.ExpectedCount--
+	}
+	if util.Contains("worker", roles) {
+		plan.Worker.Nodes = nodesWithoutHost(plan.Worker.Nodes, host)
+		plan.Worker.ExpectedCount--
+	}
+	if util.Contains("ingress", roles) {
+		plan.Ingress.Nodes = nodesWithoutHost(plan.Ingress.Nodes, host)
+		plan.Ingress.ExpectedCount--
+	}
+	if util.Contains("storage", roles) {
+		plan.Storage.Nodes = nodesWithoutHost(plan.Storage.Nodes, host)
+		plan.Storage.ExpectedCount--
+	}
+	return plan, *node, roles, nil
+}
+
+// returns a new slice, so that the node slices of the original plan are not modified
+func nodesWithoutHost(nodes []Node, host string) []Node {
+	remaining := []Node{}
+	for _, n := range nodes {
+		if n.Host != host {
+			remaining = append(remaining, n)
+		}
+	}
+	return remaining
+}
+
+// DetectNodeRemovalSafety determines whether it's safe to remove a specific node
+// from the cluster. If any condition that could result in data or availability
+// loss is detected, the removal is deemed unsafe, and the conditions are returned as errors.
+func DetectNodeRemovalSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient, glusterClient upgradeStorageInfoClient) []error {
+	errs := []error{}
+	roles := plan.GetRolesForIP(node.IP)
+	for _, role := range roles {
+		switch role {
+		case "etcd":
+			if len(plan.Etcd.Nodes)-1 < 3 {
+				errs = append(errs, removeEtcdNodeCountErr{})
+			}
+		case "master":
+			lb, _, err := plan.ClusterAddress()
+			if err != nil {
+				errs = append(errs, loadBalancerSplitError{})
+			}
+			if lb == node.Host || lb == node.IP || (node.InternalIP != "" && lb == node.InternalIP) {
+				errs = append(errs, removeMasterNodeLoadBalancingErr{})
+			}
+		case "ingress":
+			errs = append(errs, removeIngressNodeErr{})
+		case "storage":
+			if len(plan.Storage.Nodes) < 2 {
+				errs = append(errs, removeLastStorageNodeErr{})
+			}
+			if storageErrs := detectStorageNodeRemovalSafety(node, glusterClient); storageErrs != nil {
+				errs = append(errs, storageErrs...)
+			}
+		case "worker":
+			if len(plan.Worker.Nodes) < 2 {
+				errs = append(errs, removeLastWorkerNodeErr{})
+			}
+			if workerErrs := detectWorkerNodeUpgradeSafety(node, kubeClient); workerErrs != nil {
+				errs = append(errs, workerErrs...)
+			}
+		}
+	}
+	return errs
+}
+
+// detectStorageNodeRemovalSafety verifies that no volume has a brick on the node.
+// Unlike an upgrade, the bricks on a removed node are gone for good, so replication
+// does not make the removal safe.
+func detectStorageNodeRemovalSafety(node Node, glusterClient upgradeStorageInfoClient) []error {
+	volumeInfo, err := glusterClient.ListVolumes()
+	if err != nil {
+		return []error{fmt.Errorf("unable to determine node removal safety: %v", err)}
+	}
+	if volumeInfo == nil || volumeInfo.VolumeInfo == nil || volumeInfo.VolumeInfo.Volumes == nil {
+		return nil
+	}
+	errs := []error{}
+	for _, v := range volumeInfo.VolumeInfo.Volumes.Volume {
+		if v == nil || v.Bricks == nil {
+			continue
+		}
+		for _, b := range v.Bricks.Brick {
+			if brickOnNode(b.Text, node) {
+				errs = append(errs, brickOnRemovedNodeErr{volume: v.Name, brick: b.Text})
+			}
+		}
+	}
+	return errs
+}
+
+// RemoveNode removes the node from the cluster described in the plan.
+// The node is drained, deleted from Kubernetes and reset. An etcd node is removed
+// from the etcd clusters, and the API servers are reconfigured when an etcd or
+// master node is removed. When the node is unreachable, it is neither drained nor
+// reset. If successful, the updated plan is returned.
+func (ae *ansibleExecutor) RemoveNode(originalPlan *Plan, host string, unreachable bool) (*Plan, error) {
+	updatedPlan, node, roles, err := RemoveNodeFromPlan(*originalPlan, host)
+	if err != nil {
+		return nil, err
+	}
+	removeEtcd := util.Contains("etcd", roles)
+	removeMaster := util.Contains("master", roles)
+
+	// The node is still part of the inventory, so that the plays
+	// have access to its variables
+	inventory := buildInventoryFromPlan(originalPlan)
+	cc, err := ae.buildClusterCatalog(originalPlan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ansible vars: %v", err)
+	}
+	cc.RemoveNode = node.Host
+
+	if !unreachable && containsAny([]string{"master", "worker", "ingress", "storage"}, roles) {
+		util.PrintHeader(ae.stdout, "Draining Node", '=')
+		t := task{
+			name:           "remove-node-drain",
+			playbook:       "_kube-drain-node.yaml",
+			plan:           *originalPlan,
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			explainer:      ae.defaultExplainer(),
+			limit:          []string{node.Host},
+		}
+		if err = ae.execute(t); err != nil {
+			return nil, fmt.Errorf("error draining node: %v", err)
+		}
+	}
+
+	if removeEtcd {
+		util.PrintHeader(ae.stdout, "Removing Etcd Member", '=')
+		t := task{
+			name:           "remove-node-etcd-member",
+			playbook:       "etcd-member-remove.yaml",
+			plan:           *originalPlan,
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			explainer:      ae.defaultExplainer(),
+		}
+		if err = ae.execute(t); err != nil {
+			return nil, fmt.Errorf("error removing etcd member: %v", err)
+		}
+	}
+
+	util.PrintHeader(ae.stdout, "Removing Node From Cluster", '=')
+	t := task{
+		name:           "remove-node",
+		playbook:       "remove-node.yaml",
+		plan:           *originalPlan,
+		inventory:      inventory,
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
+	}
+	if err = ae.execute(t); err != nil {
+		return nil, fmt.Errorf("error removing node from cluster: %v", err)
+	}
+
+	if removeEtcd || removeMaster {
+		var kubeconfigChanged bool
+		if removeMaster && !ae.options.DryRun {
+			kubeconfigChanged, err = RegenerateKubeconfig(&updatedPlan, ae.options.GeneratedAssetsDirectory)
+			if err != nil {
+				return nil, fmt.Errorf("error generating kubeconfig file: %v", err)
+			}
+		}
+		if err = ae.reconfigureControlPlane(updatedPlan, false, kubeconfigChanged); err != nil {
+			return nil, err
+		}
+	}
+
+	if !unreachable {
+		util.PrintHeader(ae.stdout, "Resetting Node", '=')
+		t = task{
+			name:           "remove-node-reset",
+			playbook:       "reset.yaml",
+			plan:           *originalPlan,
+			inventory:      inventory,
+			clusterCatalog: *cc,
+			explainer:      ae.defaultExplainer(),
+			limit:          []string{node.Host},
+		}
+		if err = ae.execute(t); err != nil {
+			return nil, fmt.Errorf("error resetting node: %v", err)
+		}
+	}
+
+	if !ae.options.DryRun {
+		util.PrintHeader(ae.stdout, "Removing Node Certificates", '=')
+		if err = ae.pki.RemoveNodeCertificates(node); err != nil {
+			return nil, fmt.Errorf("error removing node certificates: %v", err)
+		}
+		util.PrettyPrintOk(ae.stdout, "Removed certificates for node %q", node.Host)
+	}
+	return &updatedPlan, nil
+}
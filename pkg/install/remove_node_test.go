@@ -0,0 +1,173 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func removeNodeTestPlan() Plan {
+	return Plan{
+		Etcd:   NodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "etcd01", IP: "10.0.0.1"}}},
+		Master: MasterNodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "master01", IP: "10.0.0.2"}}},
+		Worker: NodeGroup{ExpectedCount: 2, Nodes: []Node{
+			{Host: "worker01", IP: "10.0.0.3"},
+			{Host: "worker02", IP: "10.0.0.4"},
+		}},
+		Ingress: OptionalNodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "worker02", IP: "10.0.0.4"}}},
+		Storage: OptionalNodeGroup{ExpectedCount: 2, Nodes: []Node{
+			{Host: "storage01", IP: "10.0.0.5"},
+			{Host: "storage02", IP: "10.0.0.6"},
+		}},
+	}
+}
+
+func TestRemoveNodeFromPlan(t *testing.T) {
+	plan := removeNodeTestPlan()
+	updated, node, roles, err := RemoveNodeFromPlan(plan, "worker02")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.IP != "10.0.0.4" {
+		t.Errorf("expected the removed node to be returned, but got %+v", node)
+	}
+	if len(roles) != 2 {
+		t.Errorf("expected the worker and ingress roles, but got %v", roles)
+	}
+	if updated.Worker.ExpectedCount != 1 || len(updated.Worker.Nodes) != 1 || updated.Worker.Nodes[0].Host != "worker01" {
+		t.Errorf("expected worker02 to be removed from the worker group, but got %+v", updated.Worker)
+	}
+	if updated.Ingress.ExpectedCount != 0 || len(updated.Ingress.Nodes) != 0 {
+		t.Errorf("expected worker02 to be removed from the ingress group, but got %+v", updated.Ingress)
+	}
+	if updated.Storage.ExpectedCount != 2 || len(updated.Storage.Nodes) != 2 {
+		t.Errorf("expected the storage group to be unchanged, but got %+v", updated.Storage)
+	}
+	// the original plan must not be modified
+	if len(plan.Worker.Nodes) != 2 || plan.Worker.ExpectedCount != 2 {
+		t.Errorf("the original plan was modified: %+v", plan.Worker)
+	}
+}
+
+func TestRemoveEtcdAndMasterNodeFromPlan(t *testing.T) {
+	plan := removeNodeTestPlan()
+	plan.Etcd = NodeGroup{ExpectedCount: 2, Nodes: []Node{{Host: "etcd01", IP: "10.0.0.1"}, {Host: "etcd02", IP: "10.0.0.7"}}}
+	plan.Master = MasterNodeGroup{ExpectedCount: 2, Nodes: []Node{{Host: "master01", IP: "10.0.0.2"}, {Host: "master02", IP: "10.0.0.8"}}}
+	updated, _, _, err := RemoveNodeFromPlan(plan, "etcd02")
+	if err != nil {
+		t.Fatalf("unexpected error removing etcd node: %v", err)
+	}
+	if updated.Etcd.ExpectedCount != 1 || len(updated.Etcd.Nodes) != 1 || updated.Etcd.Nodes[0].Host != "etcd01" {
+		t.Errorf("expected etcd02 to be removed from the etcd group, but got %+v", updated.Etcd)
+	}
+	updated, _, _, err = RemoveNodeFromPlan(plan, "master01")
+	if err != nil {
+		t.Fatalf("unexpected error removing master node: %v", err)
+	}
+	if updated.Master.ExpectedCount != 1 || len(updated.Master.Nodes) != 1 || updated.Master.Nodes[0].Host != "master02" {
+		t.Errorf("expected master01 to be removed from the master group, but got %+v", updated.Master)
+	}
+}
+
+func TestRemoveNodeFromPlanErrors(t *testing.T) {
+	plan := removeNodeTestPlan()
+	for _, host := range []string{"etcd01", "master01", "foo"} {
+		if _, _, _, err := RemoveNodeFromPlan(plan, host); err == nil {
+			t.Errorf("%s: expected an error, but got none", host)
+		}
+	}
+}
+
+func TestDetectNodeRemovalSafety(t *testing.T) {
+	plan := removeNodeTestPlan()
+	tests := []struct {
+		node          Node
+		glusterClient fakeUpgradeGlusterClient
+		expectedErrs  []error
+	}{
+		{
+			node: plan.Worker.Nodes[0],
+		},
+		{
+			node:         plan.Worker.Nodes[1],
+			expectedErrs: []error{removeIngressNodeErr{}},
+		},
+		{
+			node:          plan.Storage.Nodes[0],
+			glusterClient: glusterVolume("foo", 2, []string{"storage02:/data/foo", "storage03:/data/foo"}, nil, nil),
+		},
+		{
+			node:          plan.Storage.Nodes[0],
+			glusterClient: glusterVolume("foo", 2, []string{"storage01:/data/foo", "storage02:/data/foo"}, nil, nil),
+			expectedErrs:  []error{brickOnRemovedNodeErr{volume: "foo", brick: "storage01:/data/foo"}},
+		},
+	}
+	for i, test := range tests {
+		errs := DetectNodeRemovalSafety(plan, test.node, fakeUpgradeKubeClient{}, test.glusterClient)
+		if len(errs) != len(test.expectedErrs) {
+			t.Errorf("test %d: expected errors %v, but got %v", i, test.expectedErrs, errs)
+			continue
+		}
+		for j := range errs {
+			if errs[j] != test.expectedErrs[j] {
+				t.Errorf("test %d: expected error %v, but got %v", i, test.expectedErrs[j], errs[j])
+			}
+		}
+	}
+}
+
+func TestDetectNodeRemovalSafetyControlPlane(t *testing.T) {
+	plan := removeNodeTestPlan()
+	plan.Etcd = NodeGroup{ExpectedCount: 3, Nodes: []Node{{Host: "etcd01", IP: "10.0.0.1"}, {Host: "etcd02", IP: "10.0.0.7"}, {Host: "etcd03", IP: "10.0.0.9"}}}
+	plan.Master = MasterNodeGroup{ExpectedCount: 2, LoadBalancer: "master01:6443", Nodes: []Node{{Host: "master01", IP: "10.0.0.2"}, {Host: "master02", IP: "10.0.0.8"}}}
+	errs := DetectNodeRemovalSafety(plan, plan.Etcd.Nodes[2], fakeUpgradeKubeClient{}, fakeUpgradeGlusterClient{})
+	if len(errs) != 1 || errs[0] != (removeEtcdNodeCountErr{}) {
+		t.Errorf("expected the etcd member count error, but got %v", errs)
+	}
+	errs = DetectNodeRemovalSafety(plan, plan.Master.Nodes[0], fakeUpgradeKubeClient{}, fakeUpgradeGlusterClient{})
+	if len(errs) != 1 || errs[0] != (removeMasterNodeLoadBalancingErr{}) {
+		t.Errorf("expected the load balancing error, but got %v", errs)
+	}
+	if errs := DetectNodeRemovalSafety(plan, plan.Master.Nodes[1], fakeUpgradeKubeClient{}, fakeUpgradeGlusterClient{}); len(errs) != 0 {
+		t.Errorf("expected no errors, but got %v", errs)
+	}
+}
+
+func TestDetectNodeRemovalSafetyLastNode(t *testing.T) {
+	plan := removeNodeTestPlan()
+	plan.Worker.Nodes = plan.Worker.Nodes[:1]
+	plan.Storage.Nodes = plan.Storage.Nodes[:1]
+	errs := DetectNodeRemovalSafety(plan, plan.Worker.Nodes[0], fakeUpgradeKubeClient{}, fakeUpgradeGlusterClient{})
+	if len(errs) != 1 || errs[0] != (removeLastWorkerNodeErr{}) {
+		t.Errorf("expected the last worker node error, but got %v", errs)
+	}
+	errs = DetectNodeRemovalSafety(plan, plan.Storage.Nodes[0], fakeUpgradeKubeClient{}, fakeUpgradeGlusterClient{})
+	if len(errs) != 1 || errs[0] != (removeLastStorageNodeErr{}) {
+		t.Errorf("expected the last storage node error, but got %v", errs)
+	}
+}
+
+func TestRemoveNodeCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ket-remove-node-certs")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := []string{"worker01-kubelet.pem", "worker01-kubelet-key.pem", "worker02-kubelet.pem", "etcd-client.pem"}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte("cert"), 0644); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+	}
+	pki := LocalPKI{GeneratedCertsDirectory: dir}
+	if err := pki.RemoveNodeCertificates(Node{Host: "worker01"}); err != nil {
+		t.Fatalf("unexpected error removing certificates: %v", err)
+	}
+	for i, f := range files {
+		_, err := os.Stat(filepath.Join(dir, f))
+		if removed := os.IsNotExist(err); removed != (i < 2) {
+			t.Errorf("%s: expected removed to be %v, but got %v", f, i < 2, removed)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package install
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
 )
 
 type upgradeKubeInfoClient interface {
@@ -15,6 +17,14 @@ type upgradeKubeInfoClient interface {
 	data.PersistentVolumeClaimGetter
 	data.PersistentVolumeGetter
 	data.StatefulSetGetter
+	data.DeploymentGetter
+	data.JobGetter
+	data.PodDisruptionBudgetLister
+}
+
+type upgradeStorageInfoClient interface {
+	ListVolumes() (*data.GlusterVolumeInfoCliOutput, error)
+	data.GlusterHealthGetter
 }
 
 type etcdNodeCountErr struct{}
@@ -51,10 +61,42 @@ func (e ingressNotSupportedErr) Error() string {
 	return "Upgrading this node may result in service unavailability if clients are accessing services directly through this ingress point."
 }
 
-type storageNotSupportedErr struct{}
+type unreplicatedVolumeErr struct {
+	volume string
+}
+
+func (e unreplicatedVolumeErr) Error() string {
+	return fmt.Sprintf("Volume %q has a brick on this node and is not replicated. "+
+		"Upgrading this node will make the volume unavailable.", e.volume)
+}
+
+type noHealthyReplicaErr struct {
+	volume string
+	brick  string
+}
+
+func (e noHealthyReplicaErr) Error() string {
+	return fmt.Sprintf("Brick %q of volume %q does not have another replica on a different node. "+
+		"Upgrading this node will make the volume unavailable.", e.brick, e.volume)
+}
+
+type replicaBrickOfflineErr struct {
+	volume string
+	brick  string
+}
+
+func (e replicaBrickOfflineErr) Error() string {
+	return fmt.Sprintf("Brick %q, a replica of a brick of volume %q on this node, is offline.", e.brick, e.volume)
+}
+
+type pendingHealErr struct {
+	volume  string
+	brick   string
+	entries string
+}
 
-func (e storageNotSupportedErr) Error() string {
-	return "Upgrading this node may result in storage volumes becoming temporarily unavailable."
+func (e pendingHealErr) Error() string {
+	return fmt.Sprintf("Brick %q of volume %q has %s entries pending self-heal.", e.brick, e.volume, e.entries)
 }
 
 type workerNodeCountErr struct{}
@@ -128,6 +170,18 @@ func (e replicasOnSingleNodeErr) Error() string {
 	return fmt.Sprintf(`All the replicas that belong to the %s "%s/%s" are running on this node.`, e.kind, e.namespace, e.name)
 }
 
+type podDisruptionBudgetErr struct {
+	namespace          string
+	name               string
+	podsOnNode         int32
+	disruptionsAllowed int32
+}
+
+func (e podDisruptionBudgetErr) Error() string {
+	return fmt.Sprintf(`Draining this node would evict %d pod(s) covered by PodDisruptionBudget "%s/%s", `+
+		"which currently allows %d disruption(s).", e.podsOnNode, e.namespace, e.name, e.disruptionsAllowed)
+}
+
 type podRunningJobErr struct {
 	namespace string
 	name      string
@@ -137,10 +191,255 @@ func (e podRunningJobErr) Error() string {
 	return fmt.Sprintf(`Pod that belongs to job "%s/%s" is running on this node.`, e.name, e.namespace)
 }
 
+// NodeNeedsUpgrade returns true if the node is not at the version of the
+// running binary, or if its components are not at the version defined in the plan.
+// Component versions are not checked on nodes that only have the "etcd" role.
+func NodeNeedsUpgrade(plan Plan, node ListableNode) bool {
+	if IsOlderVersion(node.Version) {
+		return true
+	}
+	etcdOnly := len(node.Roles) == 1 && node.Roles[0] == "etcd"
+	return !etcdOnly && plan.Cluster.Version != node.ComponentVersions.Kubernetes
+}
+
+// UpgradeBatch is a group of nodes that are upgraded together during
+// a given phase of the upgrade.
+type UpgradeBatch struct {
+	Phase string
+	Nodes []ListableNode
+}
+
+// UpgradeBatches returns the order in which the given nodes are upgraded:
+//   1. Etcd nodes, one at a time
+//   2. Master nodes, one at a time
+//   3. Worker nodes (regardless of specialization), in batches of maxParallelWorkers
+//
+// A node with multiple roles is only included in the first phase it belongs to.
+func UpgradeBatches(nodes []ListableNode, maxParallelWorkers int) []UpgradeBatch {
+	if maxParallelWorkers < 1 {
+		maxParallelWorkers = 1
+	}
+	batches := []UpgradeBatch{}
+	seen := map[string]bool{}
+	for _, phase := range []string{"etcd", "master"} {
+		for _, n := range nodes {
+			if seen[n.Node.IP] || !util.Contains(phase, n.Roles) {
+				continue
+			}
+			batches = append(batches, UpgradeBatch{Phase: phase, Nodes: []ListableNode{n}})
+			seen[n.Node.IP] = true
+		}
+	}
+	var workers []ListableNode
+	for _, n := range nodes {
+		if seen[n.Node.IP] {
+			continue
+		}
+		workers = append(workers, n)
+		seen[n.Node.IP] = true
+	}
+	for len(workers) > 0 {
+		size := maxParallelWorkers
+		if len(workers) < size {
+			size = len(workers)
+		}
+		batches = append(batches, UpgradeBatch{Phase: "worker", Nodes: workers[:size]})
+		workers = workers[size:]
+	}
+	return batches
+}
+
+// ParseMaxUnavailable returns the maximum number of nodes that can be unavailable
+// at the same time, given as a count (e.g. "2") or as a percentage (e.g. "25%") of
+// the total number of nodes. Percentages are rounded down, but never result in
+// less than one node.
+func ParseMaxUnavailable(value string, total int) (int, error) {
+	v := strings.TrimSpace(value)
+	if strings.HasSuffix(v, "%") {
+		p, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+		if err != nil || p < 1 || p > 100 {
+			return 0, fmt.Errorf("invalid percentage %q: must be between 1%% and 100%%", value)
+		}
+		n := total * p / 100
+		if n < 1 {
+			n = 1
+		}
+		return n, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid value %q: must be a number greater or equal to 1, or a percentage", value)
+	}
+	return n, nil
+}
+
+// OnlineUpgradeBatches returns the order in which the given nodes are upgraded
+// during an online upgrade:
+//   1. Etcd nodes, one at a time
+//   2. Master nodes, one at a time
+//   3. Storage nodes, one at a time
+//   4. Worker nodes (regardless of specialization), in batches of up to maxUnavailable
+//
+// Worker nodes are only placed in the same batch if, together, they don't host
+// all the replicas of a workload, and draining them does not evict more pods than
+// allowed by any PodDisruptionBudget.
+func OnlineUpgradeBatches(nodes []ListableNode, maxUnavailable int, kubeClient upgradeKubeInfoClient) ([]UpgradeBatch, error) {
+	batches := []UpgradeBatch{}
+	var workers []ListableNode
+	for _, b := range UpgradeBatches(nodes, 1) {
+		if b.Phase != "worker" {
+			batches = append(batches, b)
+			continue
+		}
+		// Storage nodes wait for volumes to heal after the upgrade,
+		// so they can't be upgraded together
+		if util.Contains("storage", b.Nodes[0].Roles) {
+			batches = append(batches, UpgradeBatch{Phase: "storage", Nodes: b.Nodes})
+			continue
+		}
+		workers = append(workers, b.Nodes...)
+	}
+	if len(workers) == 0 {
+		return batches, nil
+	}
+	if maxUnavailable < 2 {
+		for _, n := range workers {
+			batches = append(batches, UpgradeBatch{Phase: "worker", Nodes: []ListableNode{n}})
+		}
+		return batches, nil
+	}
+
+	wp, err := getWorkloadPlacement(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	// Place each node in the first batch that has room for it, and that does
+	// not conflict with it
+	var workerBatches []UpgradeBatch
+	for _, n := range workers {
+		placed := false
+		for i, b := range workerBatches {
+			if len(b.Nodes) >= maxUnavailable || !wp.canDrainTogether(append(b.hosts(), n.Node.Host)) {
+				continue
+			}
+			workerBatches[i].Nodes = append(workerBatches[i].Nodes, n)
+			placed = true
+			break
+		}
+		if !placed {
+			workerBatches = append(workerBatches, UpgradeBatch{Phase: "worker", Nodes: []ListableNode{n}})
+		}
+	}
+	return append(batches, workerBatches...), nil
+}
+
+func (b UpgradeBatch) hosts() []string {
+	hosts := make([]string, 0, len(b.Nodes)+1)
+	for _, n := range b.Nodes {
+		hosts = append(hosts, n.Node.Host)
+	}
+	return hosts
+}
+
+// workloadPlacement contains the number of pods that are running on each node,
+// grouped by the workload or PodDisruptionBudget they belong to.
+type workloadPlacement struct {
+	workloadPods map[string]map[string]int32
+	pdbPods      map[string]map[string]int32
+	pdbAllowed   map[string]int32
+}
+
+func getWorkloadPlacement(kubeClient upgradeKubeInfoClient) (*workloadPlacement, error) {
+	podList, err := kubeClient.ListPods()
+	if err != nil || podList == nil {
+		return nil, fmt.Errorf("unable to get pods running in the cluster: %v", err)
+	}
+	pdbList, err := kubeClient.ListPodDisruptionBudgets()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get information about PodDisruptionBudgets: %v", err)
+	}
+	wp := &workloadPlacement{
+		workloadPods: map[string]map[string]int32{},
+		pdbPods:      map[string]map[string]int32{},
+		pdbAllowed:   map[string]int32{},
+	}
+	// Replicated workloads are looked up once, regardless of the number of pods they own
+	ownerWorkloads := map[string]*replicatedWorkload{}
+	for _, p := range podList.Items {
+		// Pods in the "kube-system" namespace are not checked for upgrade safety
+		if p.Spec.NodeName == "" || p.Namespace == "kube-system" {
+			continue
+		}
+		if pdbList != nil {
+			for _, pdb := range pdbList.Items {
+				if p.Namespace != pdb.Namespace || !pdb.Spec.Selector.Matches(p.Labels) {
+					continue
+				}
+				key := pdb.Namespace + "/" + pdb.Name
+				if wp.pdbPods[key] == nil {
+					wp.pdbPods[key] = map[string]int32{}
+				}
+				wp.pdbPods[key][p.Spec.NodeName]++
+				wp.pdbAllowed[key] = pdb.Status.DisruptionsAllowed
+			}
+		}
+		owner := controllerOf(p.ObjectMeta)
+		if owner == nil {
+			continue
+		}
+		switch strings.ToLower(owner.Kind) {
+		case "replicationcontroller", "replicaset", "statefulset":
+		default:
+			continue
+		}
+		ownerKey := strings.ToLower(owner.Kind) + "/" + p.Namespace + "/" + owner.Name
+		w, ok := ownerWorkloads[ownerKey]
+		if !ok {
+			if w, err = replicatedWorkloadOf(p.Namespace, *owner, kubeClient); err != nil {
+				return nil, err
+			}
+			ownerWorkloads[ownerKey] = w
+		}
+		if wp.workloadPods[w.key()] == nil {
+			wp.workloadPods[w.key()] = map[string]int32{}
+		}
+		wp.workloadPods[w.key()][p.Spec.NodeName]++
+	}
+	return wp, nil
+}
+
+// canDrainTogether returns true if the nodes can be drained at the same time
+// without taking down all the replicas of a workload, or evicting more pods
+// than allowed by a PodDisruptionBudget.
+func (wp *workloadPlacement) canDrainTogether(nodes []string) bool {
+	for _, podsPerNode := range wp.workloadPods {
+		var onNodes, total int32
+		for node, pods := range podsPerNode {
+			total += pods
+			if util.Contains(node, nodes) {
+				onNodes += pods
+			}
+		}
+		if onNodes == total {
+			return false
+		}
+	}
+	for key, podsPerNode := range wp.pdbPods {
+		var onNodes int32
+		for _, node := range nodes {
+			onNodes += podsPerNode[node]
+		}
+		if onNodes > wp.pdbAllowed[key] {
+			return false
+		}
+	}
+	return true
+}
+
 // DetectNodeUpgradeSafety determines whether it's safe to upgrade a specific node
 // listed in the plan file. If any condition that could result in data or availability
 // loss is detected, the upgrade is deemed unsafe, and the conditions are returned as errors.
-func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient) []error {
+func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoClient, glusterClient upgradeStorageInfoClient) []error {
 	errs := []error{}
 	roles := plan.GetRolesForIP(node.IP)
 	for _, role := range roles {
@@ -165,9 +464,9 @@ func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoCli
 			// upgrading an ingress node is potentially unsafe
 			errs = append(errs, ingressNotSupportedErr{})
 		case "storage":
-			// we could potentially detect safety of upgrading storage nodes by inspecting
-			// the volumes on the node. for now, we are choosing not to support online upgrade of storage nodes
-			errs = append(errs, storageNotSupportedErr{})
+			if storageErrs := detectStorageNodeUpgradeSafety(node, glusterClient); storageErrs != nil {
+				errs = append(errs, storageErrs...)
+			}
 		case "worker":
 			if plan.Worker.ExpectedCount < 2 {
 				errs = append(errs, workerNodeCountErr{})
@@ -180,6 +479,114 @@ func DetectNodeUpgradeSafety(plan Plan, node Node, kubeClient upgradeKubeInfoCli
 	return errs
 }
 
+// detectStorageNodeUpgradeSafety verifies that every volume with a brick on the node
+// is replicated, and that the other bricks in the same replica set are online and
+// have no entries pending self-heal.
+func detectStorageNodeUpgradeSafety(node Node, glusterClient upgradeStorageInfoClient) []error {
+	errs := []error{}
+	volumeInfo, err := glusterClient.ListVolumes()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("unable to determine node upgrade safety: %v", err))
+		return errs
+	}
+	// no volumes in the cluster
+	if volumeInfo == nil || volumeInfo.VolumeInfo == nil || volumeInfo.VolumeInfo.Volumes == nil {
+		return nil
+	}
+	for _, v := range volumeInfo.VolumeInfo.Volumes.Volume {
+		if v == nil || v.Bricks == nil {
+			continue
+		}
+		bricks := []string{}
+		for _, b := range v.Bricks.Brick {
+			bricks = append(bricks, b.Text)
+		}
+		onNode := false
+		for _, b := range bricks {
+			if brickOnNode(b, node) {
+				onNode = true
+				break
+			}
+		}
+		if !onNode {
+			continue
+		}
+		if v.ReplicaCount < 2 {
+			errs = append(errs, unreplicatedVolumeErr{volume: v.Name})
+			continue
+		}
+
+		status, err := glusterClient.GetVolumeStatus(v.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to determine node upgrade safety: %v", err))
+			continue
+		}
+		online := map[string]bool{}
+		for _, sv := range status.VolumeStatus.Volumes.Volume {
+			for _, n := range sv.Nodes {
+				online[n.Hostname+":"+n.Path] = n.Status == 1
+			}
+		}
+		heal, err := glusterClient.GetHealInfo(v.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to determine node upgrade safety: %v", err))
+			continue
+		}
+		pending := map[string]string{}
+		for _, b := range heal.HealInfo.Bricks.Brick {
+			pending[b.Name] = b.NumberOfEntries
+		}
+
+		// Bricks are listed in order, and consecutive bricks make up a replica set
+		replicaCount := int(v.ReplicaCount)
+		for i := 0; i < len(bricks); i += replicaCount {
+			end := i + replicaCount
+			if end > len(bricks) {
+				end = len(bricks)
+			}
+			set := bricks[i:end]
+			var nodeBricks, otherBricks []string
+			for _, b := range set {
+				if brickOnNode(b, node) {
+					nodeBricks = append(nodeBricks, b)
+				} else {
+					otherBricks = append(otherBricks, b)
+				}
+			}
+			if len(nodeBricks) == 0 {
+				continue
+			}
+			if len(otherBricks) == 0 {
+				errs = append(errs, noHealthyReplicaErr{volume: v.Name, brick: nodeBricks[0]})
+				continue
+			}
+			for _, b := range otherBricks {
+				if !online[b] {
+					errs = append(errs, replicaBrickOfflineErr{volume: v.Name, brick: b})
+				}
+			}
+			// entries pending self-heal on any brick of the replica set
+			// mean that the replicas are not in sync. Bricks that are not
+			// connected report "-", and are covered by the status check above.
+			for _, b := range set {
+				if entries, ok := pending[b]; ok && entries != "0" && entries != "-" {
+					errs = append(errs, pendingHealErr{volume: v.Name, brick: b, entries: entries})
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// brickOnNode returns true if the brick, in the form "host:/path", is on the node
+func brickOnNode(brick string, node Node) bool {
+	host := brick
+	if i := strings.Index(brick, ":/"); i >= 0 {
+		host = brick[:i]
+	}
+	return strings.EqualFold(host, node.Host) || host == node.IP || (node.InternalIP != "" && host == node.InternalIP)
+}
+
 func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient) []error {
 	errs := []error{}
 	podList, err := kubeClient.ListPods()
@@ -224,11 +631,34 @@ func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient)
 		}
 	}
 
-	// Keep track of how many pods managed by replication controllers and replicasets
-	// are running on this node. If all replicas are running on the node, we need to
-	// return an error, as it would take the workload down.
-	rcPods := map[string]int32{}
-	rsPods := map[string]int32{}
+	// Pods that are covered by a PodDisruptionBudget are checked against the budget
+	// instead of the replica count of their controller, as the budget is the
+	// authoritative declaration of how many disruptions the workload can tolerate.
+	pdbList, err := kubeClient.ListPodDisruptionBudgets()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed to get information about PodDisruptionBudgets: %v", err))
+		return errs
+	}
+	coveredPods := map[string]bool{}
+	if pdbList != nil {
+		for _, pdb := range pdbList.Items {
+			var podsOnNode int32
+			for _, p := range nodePods {
+				if p.Namespace == pdb.Namespace && pdb.Spec.Selector.Matches(p.Labels) {
+					podsOnNode++
+					coveredPods[p.Namespace+"/"+p.Name] = true
+				}
+			}
+			if podsOnNode > pdb.Status.DisruptionsAllowed {
+				errs = append(errs, podDisruptionBudgetErr{namespace: pdb.Namespace, name: pdb.Name, podsOnNode: podsOnNode, disruptionsAllowed: pdb.Status.DisruptionsAllowed})
+			}
+		}
+	}
+
+	// Keep track of how many pods managed by each workload are running on this node.
+	// If all replicas are running on the node, we need to return an error, as it
+	// would take the workload down.
+	workloadPods := map[string]int32{}
 
 	// 1. Are there any pods running on this node that are not managed by a controller?
 	// 2. Are there any pods running on this node that are managed by a controller,
@@ -246,9 +676,15 @@ func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient)
 			errs = append(errs, fmt.Errorf("Unable to determine the owner of pod %s/%s", p.Namespace, p.Name))
 			continue
 		}
+		// The workload that owns the pod, and its replica count
+		var kind, name string
+		var replicas int32
+		// Whether to check if all the replicas of the workload are on this node
+		checkSingleNode := true
 		switch strings.ToLower(owner.Kind) {
 		default:
 			errs = append(errs, fmt.Errorf("Unable to determine upgrade safety for a pod managed by a controller of type %q", owner.Kind))
+			continue
 		case "daemonset":
 			ds, err := kubeClient.GetDaemonSet(p.Namespace, owner.Name)
 			if err != nil || ds == nil {
@@ -259,45 +695,104 @@ func detectWorkerNodeUpgradeSafety(node Node, kubeClient upgradeKubeInfoClient)
 			if ds.Status.DesiredNumberScheduled < 2 {
 				errs = append(errs, podUnsafeDaemonErr{dsNamespace: p.Namespace, dsName: owner.Name})
 			}
+			continue
 		case "job":
-			errs = append(errs, podRunningJobErr{namespace: p.Namespace, name: owner.Name})
-		case "replicationcontroller":
-			rc, err := kubeClient.GetReplicationController(p.Namespace, owner.Name)
-			if err != nil || rc == nil {
-				errs = append(errs, fmt.Errorf(`Failed to get information about ReplicationController "%s/%s"`, p.Namespace, owner.Name))
+			job, err := kubeClient.GetJob(p.Namespace, owner.Name)
+			if err != nil || job == nil {
+				errs = append(errs, fmt.Errorf(`Failed to get information about Job "%s/%s"`, p.Namespace, owner.Name))
 				continue
 			}
-			if rc.Status.Replicas < 2 {
-				errs = append(errs, unsafeReplicaCountErr{kind: owner.Kind, namespace: p.Namespace, name: owner.Name})
-			}
-			rcPods[p.Namespace+owner.Name]++
-			if rcPods[p.Namespace+owner.Name] == rc.Status.Replicas {
-				errs = append(errs, replicasOnSingleNodeErr{kind: owner.Kind, namespace: p.Namespace, name: owner.Name})
-			}
-		case "replicaset":
-			rs, err := kubeClient.GetReplicaSet(p.Namespace, owner.Name)
-			if err != nil || rs == nil {
-				errs = append(errs, fmt.Errorf(`Failed to get information about ReplicaSet "%s/%s"`, p.Namespace, owner.Name))
+			// Jobs that are created by a CronJob will be run again on the next schedule
+			if jobOwner := controllerOf(job.ObjectMeta); jobOwner != nil && strings.ToLower(jobOwner.Kind) == "cronjob" {
 				continue
 			}
-			if rs.Status.Replicas < 2 {
-				errs = append(errs, unsafeReplicaCountErr{kind: owner.Kind, namespace: p.Namespace, name: owner.Name})
-			}
-			rsPods[p.Namespace+owner.Name]++
-			if rsPods[p.Namespace+owner.Name] == rs.Status.Replicas {
-				errs = append(errs, replicasOnSingleNodeErr{kind: owner.Kind, namespace: p.Namespace, name: owner.Name})
-			}
-		case "statefulset":
-			sts, err := kubeClient.GetStatefulSet(p.Namespace, owner.Name)
-			if err != nil || sts == nil {
-				errs = append(errs, fmt.Errorf(`Failed to get information about StatefulSet "%s/%s"`, p.Namespace, owner.Name))
+			errs = append(errs, podRunningJobErr{namespace: p.Namespace, name: owner.Name})
+			continue
+		case "replicationcontroller", "replicaset", "statefulset":
+			w, err := replicatedWorkloadOf(p.Namespace, owner, kubeClient)
+			if err != nil {
+				errs = append(errs, err)
 				continue
 			}
-			if sts.Status.Replicas < 2 {
-				errs = append(errs, unsafeReplicaCountErr{kind: owner.Kind, namespace: p.Namespace, name: owner.Name})
-			}
+			kind, name, replicas = w.kind, w.name, w.replicas
+			checkSingleNode = strings.ToLower(owner.Kind) != "statefulset"
+		}
+
+		if coveredPods[p.Namespace+"/"+p.Name] {
+			continue
+		}
+		if replicas < 2 {
+			errs = append(errs, unsafeReplicaCountErr{kind: kind, namespace: p.Namespace, name: name})
+		}
+		if !checkSingleNode {
+			continue
+		}
+		key := strings.ToLower(kind) + "/" + p.Namespace + "/" + name
+		workloadPods[key]++
+		if workloadPods[key] == replicas {
+			errs = append(errs, replicasOnSingleNodeErr{kind: kind, namespace: p.Namespace, name: name})
 		}
 	}
 
 	return errs
 }
+
+// replicatedWorkload is a controller that manages the replicas of a pod
+type replicatedWorkload struct {
+	kind      string
+	namespace string
+	name      string
+	replicas  int32
+}
+
+func (w replicatedWorkload) key() string {
+	return strings.ToLower(w.kind) + "/" + w.namespace + "/" + w.name
+}
+
+// replicatedWorkloadOf returns the workload that manages the replicas of a pod owned
+// by a ReplicationController, ReplicaSet or StatefulSet. Pods owned by a ReplicaSet
+// that is managed by a Deployment are reported as part of the Deployment.
+func replicatedWorkloadOf(namespace string, owner data.OwnerReference, kubeClient upgradeKubeInfoClient) (*replicatedWorkload, error) {
+	switch strings.ToLower(owner.Kind) {
+	case "replicationcontroller":
+		rc, err := kubeClient.GetReplicationController(namespace, owner.Name)
+		if err != nil || rc == nil {
+			return nil, fmt.Errorf(`Failed to get information about ReplicationController "%s/%s"`, namespace, owner.Name)
+		}
+		return &replicatedWorkload{kind: owner.Kind, namespace: namespace, name: owner.Name, replicas: rc.Status.Replicas}, nil
+	case "replicaset":
+		rs, err := kubeClient.GetReplicaSet(namespace, owner.Name)
+		if err != nil || rs == nil {
+			return nil, fmt.Errorf(`Failed to get information about ReplicaSet "%s/%s"`, namespace, owner.Name)
+		}
+		if rsOwner := controllerOf(rs.ObjectMeta); rsOwner != nil && strings.ToLower(rsOwner.Kind) == "deployment" {
+			d, err := kubeClient.GetDeployment(namespace, rsOwner.Name)
+			if err != nil || d == nil {
+				return nil, fmt.Errorf(`Failed to get information about Deployment "%s/%s"`, namespace, rsOwner.Name)
+			}
+			return &replicatedWorkload{kind: rsOwner.Kind, namespace: namespace, name: rsOwner.Name, replicas: d.Status.Replicas}, nil
+		}
+		return &replicatedWorkload{kind: owner.Kind, namespace: namespace, name: owner.Name, replicas: rs.Status.Replicas}, nil
+	case "statefulset":
+		sts, err := kubeClient.GetStatefulSet(namespace, owner.Name)
+		if err != nil || sts == nil {
+			return nil, fmt.Errorf(`Failed to get information about StatefulSet "%s/%s"`, namespace, owner.Name)
+		}
+		return &replicatedWorkload{kind: owner.Kind, namespace: namespace, name: owner.Name, replicas: sts.Status.Replicas}, nil
+	}
+	return nil, fmt.Errorf("Unable to determine upgrade safety for a pod managed by a controller of type %q", owner.Kind)
+}
+
+// controllerOf returns the owner reference of the object that is its managing
+// controller. If the object does not have a controller, the first owner is returned.
+func controllerOf(meta data.ObjectMeta) *data.OwnerReference {
+	for i := range meta.OwnerReferences {
+		if meta.OwnerReferences[i].Controller {
+			return &meta.OwnerReferences[i]
+		}
+	}
+	if len(meta.OwnerReferences) > 0 {
+		return &meta.OwnerReferences[0]
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const pausedUpgradeFile = "upgrade-paused.yaml"
+
+// PausedUpgrade is the state of a canary upgrade that is waiting for an operator
+// to either continue or abort it
+type PausedUpgrade struct {
+	PausedAt      time.Time `yaml:"pausedAt"`
+	TargetVersion string    `yaml:"targetVersion"`
+	// Snapshot is the name of the upgrade snapshot taken before the upgrade started
+	Snapshot string `yaml:"snapshot,omitempty"`
+	// CanaryNodes are the nodes that were upgraded before pausing
+	CanaryNodes []string `yaml:"canaryNodes"`
+	// RemainingNodes are the nodes that are left to upgrade
+	RemainingNodes []string `yaml:"remainingNodes"`
+	// VerificationError is set when the smoke test or the user provided checks failed
+	VerificationError string `yaml:"verificationError,omitempty"`
+
+	// Options used to continue the upgrade
+	MaxUnavailable     string `yaml:"maxUnavailable"`
+	IgnoreSafetyChecks bool   `yaml:"ignoreSafetyChecks"`
+	RestartServices    bool   `yaml:"restartServices"`
+	PartialAllowed     bool   `yaml:"partialAllowed"`
+}
+
+func pausedUpgradePath(runsDir string) string {
+	return filepath.Join(runsDir, pausedUpgradeFile)
+}
+
+// WritePausedUpgrade saves the state of a paused upgrade in the runs directory
+func WritePausedUpgrade(runsDir string, pu PausedUpgrade) error {
+	if err := os.MkdirAll(runsDir, 0777); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	b, err := yaml.Marshal(pu)
+	if err != nil {
+		return fmt.Errorf("error marshalling upgrade state: %v", err)
+	}
+	if err := ioutil.WriteFile(pausedUpgradePath(runsDir), b, 0644); err != nil {
+		return fmt.Errorf("error writing upgrade state: %v", err)
+	}
+	return nil
+}
+
+// ReadPausedUpgrade returns the state of the paused upgrade, or nil if there
+// is no paused upgrade in the runs directory
+func ReadPausedUpgrade(runsDir string) (*PausedUpgrade, error) {
+	b, err := ioutil.ReadFile(pausedUpgradePath(runsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading upgrade state: %v", err)
+	}
+	var pu PausedUpgrade
+	if err := yaml.Unmarshal(b, &pu); err != nil {
+		return nil, fmt.Errorf("error unmarshalling upgrade state %q: %v", pausedUpgradePath(runsDir), err)
+	}
+	return &pu, nil
+}
+
+// RemovePausedUpgrade removes the state of the paused upgrade from the runs directory
+func RemovePausedUpgrade(runsDir string) error {
+	if err := os.Remove(pausedUpgradePath(runsDir)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing upgrade state: %v", err)
+	}
+	return nil
+}
+
+// CanaryBatches splits the upgrade batches into the batches that are upgraded
+// during the canary phase, and the ones that are upgraded after the canary is
+// verified. The canary contains the etcd and master nodes, and the first
+// canaryWorkers worker nodes. Worker batches are split if necessary.
+// Returns an error if the canary contains all the worker nodes, as the upgrade
+// would not be paused.
+func CanaryBatches(batches []UpgradeBatch, canaryWorkers int) (canary []UpgradeBatch, remaining []UpgradeBatch, err error) {
+	totalWorkers := 0
+	for _, b := range batches {
+		if b.Phase == "worker" {
+			totalWorkers += len(b.Nodes)
+		}
+	}
+	if canaryWorkers >= totalWorkers {
+		return nil, nil, fmt.Errorf("the canary must contain less worker nodes than the %d worker nodes to upgrade, got %d", totalWorkers, canaryWorkers)
+	}
+	workers := 0
+	for _, b := range batches {
+		switch {
+		case b.Phase == "etcd" || b.Phase == "master":
+			canary = append(canary, b)
+		case b.Phase == "worker" && workers < canaryWorkers:
+			n := canaryWorkers - workers
+			if n > len(b.Nodes) {
+				n = len(b.Nodes)
+			}
+			canary = append(canary, UpgradeBatch{Phase: b.Phase, Nodes: b.Nodes[:n]})
+			workers += n
+			if n < len(b.Nodes) {
+				remaining = append(remaining, UpgradeBatch{Phase: b.Phase, Nodes: b.Nodes[n:]})
+			}
+		default:
+			remaining = append(remaining, b)
+		}
+	}
+	return canary, remaining, nil
+}
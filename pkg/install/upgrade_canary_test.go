@@ -0,0 +1,107 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCanaryBatches(t *testing.T) {
+	node := func(host string) ListableNode {
+		return ListableNode{Node: Node{Host: host}}
+	}
+	batches := []UpgradeBatch{
+		{Phase: "etcd", Nodes: []ListableNode{node("etcd")}},
+		{Phase: "master", Nodes: []ListableNode{node("master")}},
+		{Phase: "storage", Nodes: []ListableNode{node("storage")}},
+		{Phase: "worker", Nodes: []ListableNode{node("worker1"), node("worker2")}},
+		{Phase: "worker", Nodes: []ListableNode{node("worker3"), node("worker4")}},
+	}
+	tests := []struct {
+		canaryWorkers     int
+		expectedCanary    [][]string
+		expectedRemaining [][]string
+	}{
+		{
+			canaryWorkers:     1,
+			expectedCanary:    [][]string{{"etcd"}, {"master"}, {"worker1"}},
+			expectedRemaining: [][]string{{"storage"}, {"worker2"}, {"worker3", "worker4"}},
+		},
+		{
+			canaryWorkers:     3,
+			expectedCanary:    [][]string{{"etcd"}, {"master"}, {"worker1", "worker2"}, {"worker3"}},
+			expectedRemaining: [][]string{{"storage"}, {"worker4"}},
+		},
+	}
+	for _, test := range tests {
+		canary, remaining, err := CanaryBatches(batches, test.canaryWorkers)
+		if err != nil {
+			t.Errorf("canary %d: unexpected error: %v", test.canaryWorkers, err)
+		}
+		if got := batchHosts(canary); !reflect.DeepEqual(got, test.expectedCanary) {
+			t.Errorf("canary %d: expected canary batches %v, but got %v", test.canaryWorkers, test.expectedCanary, got)
+		}
+		if got := batchHosts(remaining); !reflect.DeepEqual(got, test.expectedRemaining) {
+			t.Errorf("canary %d: expected remaining batches %v, but got %v", test.canaryWorkers, test.expectedRemaining, got)
+		}
+	}
+}
+
+func TestCanaryBatchesAllWorkers(t *testing.T) {
+	batches := []UpgradeBatch{
+		{Phase: "master", Nodes: []ListableNode{{Node: Node{Host: "master"}}}},
+		{Phase: "storage", Nodes: []ListableNode{{Node: Node{Host: "storage"}}}},
+		{Phase: "worker", Nodes: []ListableNode{{Node: Node{Host: "worker1"}}, {Node: Node{Host: "worker2"}}}},
+	}
+	for _, canaryWorkers := range []int{2, 10} {
+		if _, _, err := CanaryBatches(batches, canaryWorkers); err == nil {
+			t.Errorf("canary %d: expected an error when the canary contains all the workers", canaryWorkers)
+		}
+	}
+}
+
+func TestPausedUpgrade(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ket-paused-upgrade-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pu, err := ReadPausedUpgrade(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error reading paused upgrade: %v", err)
+	}
+	if pu != nil {
+		t.Errorf("expected no paused upgrade, but got %+v", pu)
+	}
+
+	expected := PausedUpgrade{
+		PausedAt:          time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		TargetVersion:     "1.2.0",
+		Snapshot:          "2018-01-01-00-00-00",
+		CanaryNodes:       []string{"etcd", "master", "worker1"},
+		RemainingNodes:    []string{"worker2"},
+		VerificationError: "smoke test failed",
+		MaxUnavailable:    "10%",
+		RestartServices:   true,
+	}
+	if err := WritePausedUpgrade(tmpDir, expected); err != nil {
+		t.Fatalf("unexpected error writing paused upgrade: %v", err)
+	}
+	pu, err = ReadPausedUpgrade(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error reading paused upgrade: %v", err)
+	}
+	if pu == nil || !reflect.DeepEqual(*pu, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, pu)
+	}
+
+	if err := RemovePausedUpgrade(tmpDir); err != nil {
+		t.Fatalf("unexpected error removing paused upgrade: %v", err)
+	}
+	if pu, _ = ReadPausedUpgrade(tmpDir); pu != nil {
+		t.Errorf("expected the paused upgrade to be removed, but got %+v", pu)
+	}
+}
@@ -0,0 +1,132 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+const (
+	nodeStatusReady         = "Ready"
+	nodeStatusNotReady      = "NotReady"
+	nodeStatusNotRegistered = "NotRegistered"
+)
+
+type upgradePlanKubeClient interface {
+	upgradeKubeInfoClient
+	data.NodeLister
+}
+
+// UpgradePlan is a preview of the upgrade that would be performed on the cluster
+type UpgradePlan struct {
+	TargetVersion           string             `json:"targetVersion"`
+	TargetKubernetesVersion string             `json:"targetKubernetesVersion"`
+	MaxParallelWorkers      int                `json:"maxParallelWorkers,omitempty"`
+	MaxUnavailable          int                `json:"maxUnavailable,omitempty"`
+	Batches                 []UpgradePlanBatch `json:"batches"`
+	Skipped                 []NodeUpgradePlan  `json:"skipped"`
+}
+
+// UpgradePlanBatch is a group of nodes that would be upgraded together
+type UpgradePlanBatch struct {
+	Phase string            `json:"phase"`
+	Nodes []NodeUpgradePlan `json:"nodes"`
+}
+
+// NodeUpgradePlan contains the upgrade details of a single node
+type NodeUpgradePlan struct {
+	Host                     string   `json:"host"`
+	IP                       string   `json:"ip"`
+	Roles                    []string `json:"roles"`
+	CurrentVersion           string   `json:"currentVersion"`
+	TargetVersion            string   `json:"targetVersion"`
+	CurrentKubernetesVersion string   `json:"currentKubernetesVersion"`
+	TargetKubernetesVersion  string   `json:"targetKubernetesVersion"`
+	// Status is the state of the node according to the Kubernetes API.
+	// Nodes that are not registered with the API server (i.e. etcd-only nodes)
+	// have a status of "NotRegistered".
+	Status string `json:"status"`
+	// SafetyErrors contains the conditions that make an online upgrade
+	// of the node unsafe.
+	SafetyErrors []string `json:"safetyErrors"`
+}
+
+// BuildUpgradePlan computes the upgrade that would be performed on the cluster,
+// without making any changes to it. Nodes are ordered in the same way they are
+// upgraded, and the safety of upgrading each node online is determined using
+// DetectNodeUpgradeSafety. When maxUnavailable is greater than zero, nodes are
+// batched as they would be during an online upgrade. Otherwise, workers are
+// batched in groups of maxParallelWorkers, as during an offline upgrade.
+func BuildUpgradePlan(plan Plan, cv ClusterVersion, maxParallelWorkers int, maxUnavailable int, kubeClient upgradePlanKubeClient, glusterClient upgradeStorageInfoClient) (*UpgradePlan, error) {
+	nodeList, err := kubeClient.ListNodes()
+	if err != nil {
+		return nil, fmt.Errorf("error listing cluster nodes: %v", err)
+	}
+	statuses := map[string]string{}
+	if nodeList != nil {
+		for _, n := range nodeList.Items {
+			status := nodeStatusNotReady
+			if n.Ready() {
+				status = nodeStatusReady
+			}
+			statuses[strings.ToLower(n.Name)] = status
+		}
+	}
+
+	up := &UpgradePlan{
+		TargetVersion:           KismaticVersion.String(),
+		TargetKubernetesVersion: plan.Cluster.Version,
+		Batches:                 []UpgradePlanBatch{},
+		Skipped:                 []NodeUpgradePlan{},
+	}
+	var toUpgrade []ListableNode
+	for _, n := range cv.Nodes {
+		if NodeNeedsUpgrade(plan, n) {
+			toUpgrade = append(toUpgrade, n)
+			continue
+		}
+		up.Skipped = append(up.Skipped, nodeUpgradePlan(plan, n, statuses))
+	}
+
+	var batches []UpgradeBatch
+	if maxUnavailable > 0 {
+		up.MaxUnavailable = maxUnavailable
+		if batches, err = OnlineUpgradeBatches(toUpgrade, maxUnavailable, kubeClient); err != nil {
+			return nil, fmt.Errorf("error computing online upgrade batches: %v", err)
+		}
+	} else {
+		up.MaxParallelWorkers = maxParallelWorkers
+		batches = UpgradeBatches(toUpgrade, maxParallelWorkers)
+	}
+	for _, b := range batches {
+		batch := UpgradePlanBatch{Phase: b.Phase}
+		for _, n := range b.Nodes {
+			np := nodeUpgradePlan(plan, n, statuses)
+			for _, err := range DetectNodeUpgradeSafety(plan, n.Node, kubeClient, glusterClient) {
+				np.SafetyErrors = append(np.SafetyErrors, err.Error())
+			}
+			batch.Nodes = append(batch.Nodes, np)
+		}
+		up.Batches = append(up.Batches, batch)
+	}
+	return up, nil
+}
+
+func nodeUpgradePlan(plan Plan, n ListableNode, statuses map[string]string) NodeUpgradePlan {
+	status, ok := statuses[strings.ToLower(n.Node.Host)]
+	if !ok {
+		status = nodeStatusNotRegistered
+	}
+	return NodeUpgradePlan{
+		Host:                     n.Node.Host,
+		IP:                       n.Node.IP,
+		Roles:                    n.Roles,
+		CurrentVersion:           n.Version.String(),
+		TargetVersion:            KismaticVersion.String(),
+		CurrentKubernetesVersion: n.ComponentVersions.Kubernetes,
+		TargetKubernetesVersion:  plan.Cluster.Version,
+		Status:                   status,
+		SafetyErrors:             []string{},
+	}
+}
@@ -0,0 +1,259 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/blang/semver"
+)
+
+type fakeUpgradePlanKubeClient struct {
+	fakeUpgradeKubeClient
+	nodes *data.NodeList
+}
+
+func (f fakeUpgradePlanKubeClient) ListNodes() (*data.NodeList, error) {
+	return f.nodes, nil
+}
+
+func batchHosts(batches []UpgradeBatch) [][]string {
+	hosts := [][]string{}
+	for _, b := range batches {
+		h := []string{}
+		for _, n := range b.Nodes {
+			h = append(h, n.Node.Host)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+func TestUpgradeBatches(t *testing.T) {
+	nodes := []ListableNode{
+		{Node: Node{Host: "worker1", IP: "10.0.0.4"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "master", IP: "10.0.0.2"}, Roles: []string{"master"}},
+		{Node: Node{Host: "etcd", IP: "10.0.0.1"}, Roles: []string{"etcd", "master"}},
+		{Node: Node{Host: "worker2", IP: "10.0.0.5"}, Roles: []string{"worker", "ingress"}},
+		{Node: Node{Host: "storage", IP: "10.0.0.6"}, Roles: []string{"storage"}},
+	}
+	tests := []struct {
+		maxParallelWorkers int
+		expected           [][]string
+	}{
+		{
+			maxParallelWorkers: 1,
+			expected:           [][]string{{"etcd"}, {"master"}, {"worker1"}, {"worker2"}, {"storage"}},
+		},
+		{
+			maxParallelWorkers: 2,
+			expected:           [][]string{{"etcd"}, {"master"}, {"worker1", "worker2"}, {"storage"}},
+		},
+		{
+			maxParallelWorkers: 5,
+			expected:           [][]string{{"etcd"}, {"master"}, {"worker1", "worker2", "storage"}},
+		},
+	}
+	for _, test := range tests {
+		got := batchHosts(UpgradeBatches(nodes, test.maxParallelWorkers))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("max parallel workers %d: expected %v, but got %v", test.maxParallelWorkers, test.expected, got)
+		}
+	}
+}
+
+func TestUpgradeBatchesLastNodeAlreadyUpgraded(t *testing.T) {
+	// The last node in the list belongs to the etcd phase, which means
+	// that the pending worker batch must still be upgraded.
+	nodes := []ListableNode{
+		{Node: Node{Host: "worker1", IP: "10.0.0.4"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "worker2", IP: "10.0.0.5"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "etcd", IP: "10.0.0.1"}, Roles: []string{"etcd"}},
+	}
+	got := batchHosts(UpgradeBatches(nodes, 3))
+	expected := [][]string{{"etcd"}, {"worker1", "worker2"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, but got %v", expected, got)
+	}
+}
+
+func TestOnlineUpgradeBatches(t *testing.T) {
+	nodes := []ListableNode{
+		{Node: Node{Host: "etcd", IP: "10.0.0.1"}, Roles: []string{"etcd"}},
+		{Node: Node{Host: "master", IP: "10.0.0.2"}, Roles: []string{"master"}},
+		{Node: Node{Host: "w1", IP: "10.0.0.3"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "storage1", IP: "10.0.0.4"}, Roles: []string{"storage"}},
+		{Node: Node{Host: "w2", IP: "10.0.0.5"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "w3", IP: "10.0.0.6"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "storage2", IP: "10.0.0.7"}, Roles: []string{"storage"}},
+		{Node: Node{Host: "w4", IP: "10.0.0.8"}, Roles: []string{"worker"}},
+	}
+	pod := func(name, node, ownerKind, ownerName string, labels map[string]string) data.Pod {
+		p := data.Pod{
+			ObjectMeta: data.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				Labels:          labels,
+				OwnerReferences: []data.OwnerReference{{Kind: ownerKind, Name: ownerName, Controller: true}},
+			},
+		}
+		p.Spec.NodeName = node
+		return p
+	}
+	db := map[string]string{"app": "db"}
+	client := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{
+				Items: []data.Pod{
+					// The replicas of the "web" deployment are on w1 and w2
+					pod("web-1", "w1", "ReplicaSet", "web-123", nil),
+					pod("web-2", "w2", "ReplicaSet", "web-123", nil),
+					// The "db" PDB allows a single pod on w1, w3 and w4 to be evicted
+					pod("db-0", "w3", "StatefulSet", "db", db),
+					pod("db-1", "w4", "StatefulSet", "db", db),
+					pod("db-2", "w1", "StatefulSet", "db", db),
+				},
+			}, nil
+		},
+		getReplicaSet: func() (*data.ReplicaSet, error) {
+			return &data.ReplicaSet{
+				ObjectMeta: data.ObjectMeta{OwnerReferences: []data.OwnerReference{{Kind: "Deployment", Name: "web", Controller: true}}},
+				Status:     data.ReplicaSetStatus{Replicas: 2},
+			}, nil
+		},
+		getDeployment: func() (*data.Deployment, error) {
+			return &data.Deployment{Status: data.DeploymentStatus{Replicas: 2, AvailableReplicas: 2}}, nil
+		},
+		getStatefulSet: func() (*data.StatefulSet, error) {
+			return &data.StatefulSet{Status: data.StatefulSetStatus{Replicas: 3}}, nil
+		},
+		listPDBs: func() (*data.PodDisruptionBudgetList, error) {
+			pdb := data.PodDisruptionBudget{ObjectMeta: data.ObjectMeta{Name: "db", Namespace: "default"}}
+			pdb.Spec.Selector = &data.LabelSelector{MatchLabels: db}
+			pdb.Status.DisruptionsAllowed = 1
+			return &data.PodDisruptionBudgetList{Items: []data.PodDisruptionBudget{pdb}}, nil
+		},
+	}
+	tests := []struct {
+		maxUnavailable int
+		expected       [][]string
+	}{
+		{
+			maxUnavailable: 1,
+			expected:       [][]string{{"etcd"}, {"master"}, {"storage1"}, {"storage2"}, {"w1"}, {"w2"}, {"w3"}, {"w4"}},
+		},
+		{
+			maxUnavailable: 3,
+			expected:       [][]string{{"etcd"}, {"master"}, {"storage1"}, {"storage2"}, {"w1"}, {"w2", "w3"}, {"w4"}},
+		},
+	}
+	for _, test := range tests {
+		batches, err := OnlineUpgradeBatches(nodes, test.maxUnavailable, client)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := batchHosts(batches); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("max unavailable %d: expected %v, but got %v", test.maxUnavailable, test.expected, got)
+		}
+	}
+}
+
+func TestParseMaxUnavailable(t *testing.T) {
+	tests := []struct {
+		value    string
+		total    int
+		expected int
+		valid    bool
+	}{
+		{value: "2", total: 10, expected: 2, valid: true},
+		{value: "20", total: 10, expected: 20, valid: true},
+		{value: "25%", total: 10, expected: 2, valid: true},
+		{value: "10%", total: 5, expected: 1, valid: true},
+		{value: "100%", total: 150, expected: 150, valid: true},
+		{value: "0", total: 10},
+		{value: "0%", total: 10},
+		{value: "101%", total: 10},
+		{value: "foo", total: 10},
+		{value: "", total: 10},
+	}
+	for _, test := range tests {
+		got, err := ParseMaxUnavailable(test.value, test.total)
+		if test.valid && err != nil {
+			t.Errorf("%q: unexpected error: %v", test.value, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%q: expected an error, but didn't get one", test.value)
+		}
+		if got != test.expected {
+			t.Errorf("%q of %d: expected %d, but got %d", test.value, test.total, test.expected, got)
+		}
+	}
+}
+
+func TestBuildUpgradePlan(t *testing.T) {
+	SetVersion("v1.2.0")
+	plan := Plan{
+		Cluster: Cluster{Version: "v1.18.3"},
+		Etcd: NodeGroup{
+			ExpectedCount: 1,
+			Nodes:         []Node{{Host: "etcd", IP: "10.0.0.1"}},
+		},
+		Master: MasterNodeGroup{
+			ExpectedCount: 1,
+			Nodes:         []Node{{Host: "master", IP: "10.0.0.2"}},
+		},
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes:         []Node{{Host: "Worker1", IP: "10.0.0.3"}, {Host: "worker2", IP: "10.0.0.4"}},
+		},
+	}
+	old := semver.Version{Major: 1, Minor: 1, Patch: 0}
+	current := semver.Version{Major: 1, Minor: 2, Patch: 0}
+	cv := ClusterVersion{
+		Nodes: []ListableNode{
+			{Node: plan.Etcd.Nodes[0], Roles: []string{"etcd"}, Version: old},
+			{Node: plan.Master.Nodes[0], Roles: []string{"master"}, Version: old, ComponentVersions: ComponentVersions{Kubernetes: "v1.18.0"}},
+			{Node: plan.Worker.Nodes[0], Roles: []string{"worker"}, Version: old, ComponentVersions: ComponentVersions{Kubernetes: "v1.18.0"}},
+			{Node: plan.Worker.Nodes[1], Roles: []string{"worker"}, Version: current, ComponentVersions: ComponentVersions{Kubernetes: "v1.18.3"}},
+		},
+	}
+	ready := data.NodeCondition{Type: "Ready", Status: "True"}
+	notReady := data.NodeCondition{Type: "Ready", Status: "False"}
+	client := fakeUpgradePlanKubeClient{
+		nodes: &data.NodeList{
+			Items: []data.Node{
+				{ObjectMeta: data.ObjectMeta{Name: "master"}, Status: data.NodeStatus{Conditions: []data.NodeCondition{ready}}},
+				{ObjectMeta: data.ObjectMeta{Name: "worker1"}, Status: data.NodeStatus{Conditions: []data.NodeCondition{notReady}}},
+				{ObjectMeta: data.ObjectMeta{Name: "worker2"}, Status: data.NodeStatus{Conditions: []data.NodeCondition{ready}}},
+			},
+		},
+	}
+
+	up, err := BuildUpgradePlan(plan, cv, 1, 0, client, fakeUpgradeGlusterClient{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(up.Batches) != 3 {
+		t.Fatalf("expected 3 batches, but got %d", len(up.Batches))
+	}
+	etcd := up.Batches[0].Nodes[0]
+	if etcd.Status != nodeStatusNotRegistered {
+		t.Errorf("expected etcd node status to be %q, but got %q", nodeStatusNotRegistered, etcd.Status)
+	}
+	if len(etcd.SafetyErrors) != 1 || etcd.SafetyErrors[0] != (etcdNodeCountErr{}).Error() {
+		t.Errorf("expected etcd node count error, but got %v", etcd.SafetyErrors)
+	}
+	if up.Batches[1].Nodes[0].Status != nodeStatusReady {
+		t.Errorf("expected master node status to be %q, but got %q", nodeStatusReady, up.Batches[1].Nodes[0].Status)
+	}
+	worker := up.Batches[2].Nodes[0]
+	if worker.Host != "Worker1" || worker.Status != nodeStatusNotReady {
+		t.Errorf("expected Worker1 to be %q, but got %+v", nodeStatusNotReady, worker)
+	}
+	if worker.CurrentVersion != "1.1.0" || worker.TargetVersion != "1.2.0" {
+		t.Errorf("unexpected versions for worker: %+v", worker)
+	}
+	if len(up.Skipped) != 1 || up.Skipped[0].Host != "worker2" {
+		t.Errorf("expected worker2 to be skipped, but got %v", up.Skipped)
+	}
+}
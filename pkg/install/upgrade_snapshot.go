@@ -0,0 +1,322 @@
+package install
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	upgradeSnapshotsDirName   = "upgrade-snapshots"
+	upgradeSnapshotFile       = "snapshot.yaml"
+	upgradeSnapshotAssetsFile = "assets.tar.gz"
+	upgradeSnapshotPlanFile   = "kismatic-cluster.yaml"
+	// UpgradeSnapshotEtcdFile is the name of the etcd snapshot in the upgrade snapshot directory
+	UpgradeSnapshotEtcdFile = "etcd-snapshot.db"
+)
+
+// The packages installed by Kismatic that are recorded before an upgrade
+var snapshotPackages = []string{"kubelet", "kubectl", "docker-ce"}
+
+// UpgradeSnapshot is the state of the cluster that was recorded before an upgrade
+type UpgradeSnapshot struct {
+	Name      string    `yaml:"name"`
+	CreatedAt time.Time `yaml:"createdAt"`
+	// TargetVersion is the version of Kismatic that performed the upgrade
+	TargetVersion string `yaml:"targetVersion"`
+	// PreUpgrade is true when none of the nodes were at the target version when
+	// the snapshot was taken, i.e. the snapshot can be used to roll back the whole upgrade
+	PreUpgrade bool           `yaml:"preUpgrade"`
+	Nodes      []NodeSnapshot `yaml:"nodes"`
+	// Directory where the snapshot is stored
+	Directory string `yaml:"-"`
+}
+
+// NodeSnapshot contains the versions that were installed on a node before an upgrade
+type NodeSnapshot struct {
+	Host              string            `yaml:"host"`
+	IP                string            `yaml:"ip"`
+	InternalIP        string            `yaml:"internalip,omitempty"`
+	Roles             []string          `yaml:"roles"`
+	KismaticVersion   string            `yaml:"kismaticVersion"`
+	ComponentVersions ComponentVersions `yaml:"componentVersions"`
+	// Packages maps the name of the installed packages to their version
+	Packages map[string]string `yaml:"packages"`
+}
+
+// Node returns the node that the snapshot belongs to
+func (ns NodeSnapshot) Node() Node {
+	return Node{Host: ns.Host, IP: ns.IP, InternalIP: ns.InternalIP}
+}
+
+// EtcdSnapshotFile returns the path to the etcd snapshot that was taken before the upgrade
+func (s UpgradeSnapshot) EtcdSnapshotFile() string {
+	return filepath.Join(s.Directory, UpgradeSnapshotEtcdFile)
+}
+
+// PlanFile returns the path to the copy of the plan file that was taken before the upgrade
+func (s UpgradeSnapshot) PlanFile() string {
+	return filepath.Join(s.Directory, upgradeSnapshotPlanFile)
+}
+
+func upgradeSnapshotsDir(generatedAssetsDir string) string {
+	return filepath.Join(generatedAssetsDir, upgradeSnapshotsDirName)
+}
+
+// CreateUpgradeSnapshot records the installed package versions of every node
+// in the cluster, and archives the plan file and the generated assets. The snapshot is
+// stored in the generated assets directory. Taking a snapshot of etcd is left to the executor.
+func CreateUpgradeSnapshot(plan *Plan, planFile string, generatedAssetsDir string, cv ClusterVersion) (*UpgradeSnapshot, error) {
+	now := time.Now()
+	s := &UpgradeSnapshot{
+		Name:          now.Format("2006-01-02-15-04-05"),
+		CreatedAt:     now,
+		TargetVersion: KismaticVersion.String(),
+		PreUpgrade:    true,
+	}
+	s.Directory = filepath.Join(upgradeSnapshotsDir(generatedAssetsDir), s.Name)
+	if err := os.MkdirAll(s.Directory, 0700); err != nil {
+		return nil, fmt.Errorf("error creating snapshot directory: %v", err)
+	}
+
+	for _, n := range cv.Nodes {
+		if !NodeNeedsUpgrade(*plan, n) {
+			s.PreUpgrade = false
+		}
+		ns, err := recordNodeSnapshot(plan, n)
+		if err != nil {
+			return nil, err
+		}
+		s.Nodes = append(s.Nodes, *ns)
+	}
+
+	if err := util.ArchiveDirectory(generatedAssetsDir, filepath.Join(s.Directory, upgradeSnapshotAssetsFile), upgradeSnapshotsDirName, etcdBackupsDirName); err != nil {
+		return nil, err
+	}
+	planBytes, err := ioutil.ReadFile(planFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %q: %v", planFile, err)
+	}
+	if err := ioutil.WriteFile(s.PlanFile(), planBytes, 0600); err != nil {
+		return nil, fmt.Errorf("error copying plan file to snapshot: %v", err)
+	}
+
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.Directory, upgradeSnapshotFile), b, 0600); err != nil {
+		return nil, fmt.Errorf("error writing snapshot: %v", err)
+	}
+	return s, nil
+}
+
+func recordNodeSnapshot(plan *Plan, n ListableNode) (*NodeSnapshot, error) {
+	client, err := plan.GetSSHClient(n.Node.Host)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := strings.Join(snapshotPackages, " ")
+	// dpkg-query exits with an error when one of the packages is not installed
+	pkgCmd := fmt.Sprintf(`if [ -x "$(command -v dpkg-query)" ]; then dpkg-query -W -f='${Package} ${Version}\n' %s 2>/dev/null; else rpm -q --qf '%%{NAME} %%{VERSION}-%%{RELEASE}\n' %s | grep -v 'not installed'; fi; true`, pkgs, pkgs)
+	pkgOutput, err := client.Output(false, pkgCmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting installed packages on node %q: %v: %q", n.Node.Host, err, pkgOutput)
+	}
+	return &NodeSnapshot{
+		Host:              n.Node.Host,
+		IP:                n.Node.IP,
+		InternalIP:        n.Node.InternalIP,
+		Roles:             n.Roles,
+		KismaticVersion:   n.Version.String(),
+		ComponentVersions: n.ComponentVersions,
+		Packages:          parsePackageVersions(pkgOutput),
+	}, nil
+}
+
+// parsePackageVersions parses lines in the form "name version"
+func parsePackageVersions(output string) map[string]string {
+	pkgs := map[string]string{}
+	s := bufio.NewScanner(strings.NewReader(output))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		pkgs[fields[0]] = fields[1]
+	}
+	return pkgs
+}
+
+// ListUpgradeSnapshots returns the upgrade snapshots stored in the generated
+// assets directory, the most recent first.
+func ListUpgradeSnapshots(generatedAssetsDir string) ([]UpgradeSnapshot, error) {
+	dir := upgradeSnapshotsDir(generatedAssetsDir)
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshots directory %q: %v", dir, err)
+	}
+	snapshots := []UpgradeSnapshot{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		s, err := readUpgradeSnapshot(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// GetUpgradeSnapshot returns the upgrade snapshot with the given name. If the
+// name is empty, the most recent pre-upgrade snapshot is returned. Snapshots taken
+// when retrying an upgrade that was partially applied are skipped, as they do not
+// contain the state of the cluster before the upgrade.
+func GetUpgradeSnapshot(generatedAssetsDir string, name string) (*UpgradeSnapshot, error) {
+	if name != "" {
+		return readUpgradeSnapshot(filepath.Join(upgradeSnapshotsDir(generatedAssetsDir), name))
+	}
+	snapshots, err := ListUpgradeSnapshots(generatedAssetsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no upgrade snapshots found in %q", upgradeSnapshotsDir(generatedAssetsDir))
+	}
+	for _, s := range snapshots {
+		if s.PreUpgrade {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("no pre-upgrade snapshot found in %q, use --snapshot to choose a snapshot", upgradeSnapshotsDir(generatedAssetsDir))
+}
+
+func readUpgradeSnapshot(dir string) (*UpgradeSnapshot, error) {
+	file := filepath.Join(dir, upgradeSnapshotFile)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading upgrade snapshot %q: %v", file, err)
+	}
+	var s UpgradeSnapshot
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("error unmarshalling upgrade snapshot %q: %v", file, err)
+	}
+	s.Directory = dir
+	return &s, nil
+}
+
+// RestoreGeneratedAssets restores the generated assets and the plan file that were
+// archived when the snapshot was taken. Other upgrade snapshots are left untouched.
+func RestoreGeneratedAssets(s UpgradeSnapshot, planFile string, generatedAssetsDir string) error {
+	if err := util.ExtractArchive(filepath.Join(s.Directory, upgradeSnapshotAssetsFile), generatedAssetsDir); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(s.PlanFile())
+	if err != nil {
+		return fmt.Errorf("error reading plan file from snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(planFile, b, 0644); err != nil {
+		return fmt.Errorf("error restoring plan file %q: %v", planFile, err)
+	}
+	return nil
+}
+
+type rollbackKubeClient interface {
+	data.NodeLister
+	data.PodLister
+}
+
+const podPhaseRunning = "Running"
+
+// The control plane components that run as static pods on the master nodes
+var controlPlaneComponents = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+// VerifyRollback compares the versions that are installed on the nodes against
+// the versions that were recorded in the snapshot, and verifies that the
+// components of the nodes are healthy: the nodes that run a kubelet must be
+// ready, and the control plane components of the master nodes must be running.
+// Returns an error for every node that does not match or is not healthy.
+func VerifyRollback(s UpgradeSnapshot, cv ClusterVersion, kubeClient rollbackKubeClient) []error {
+	errs := []error{}
+	rolledBack := []NodeSnapshot{}
+	for _, n := range cv.Nodes {
+		var ns *NodeSnapshot
+		for i := range s.Nodes {
+			if s.Nodes[i].Host == n.Node.Host {
+				ns = &s.Nodes[i]
+				break
+			}
+		}
+		if ns == nil {
+			continue
+		}
+		rolledBack = append(rolledBack, *ns)
+		if n.Version.String() != ns.KismaticVersion {
+			errs = append(errs, fmt.Errorf("node %q is at version %q, expected %q", n.Node.Host, n.Version, ns.KismaticVersion))
+		}
+		if n.ComponentVersions.Kubernetes != ns.ComponentVersions.Kubernetes {
+			errs = append(errs, fmt.Errorf("node %q is running Kubernetes %q, expected %q", n.Node.Host, n.ComponentVersions.Kubernetes, ns.ComponentVersions.Kubernetes))
+		}
+	}
+	if len(rolledBack) == 0 {
+		return errs
+	}
+
+	nodes, err := kubeClient.ListNodes()
+	if err != nil {
+		return append(errs, fmt.Errorf("error listing nodes: %v", err))
+	}
+	pods, err := kubeClient.ListPods()
+	if err != nil {
+		return append(errs, fmt.Errorf("error listing pods: %v", err))
+	}
+	for _, ns := range rolledBack {
+		if !util.Subset(ns.Roles, []string{"etcd"}) {
+			var node *data.Node
+			for i := range nodes.Items {
+				if strings.ToLower(nodes.Items[i].Name) == strings.ToLower(ns.Host) {
+					node = &nodes.Items[i]
+					break
+				}
+			}
+			if node == nil {
+				errs = append(errs, fmt.Errorf("node %q is not registered with the API server", ns.Host))
+			} else if !node.Ready() {
+				errs = append(errs, fmt.Errorf("node %q is not ready", ns.Host))
+			}
+		}
+		if !util.Contains("master", ns.Roles) {
+			continue
+		}
+		for _, component := range controlPlaneComponents {
+			running := false
+			for _, p := range pods.Items {
+				if p.Labels["component"] == component && p.Labels["kismatic/host"] == ns.Host && p.Status.Phase == podPhaseRunning {
+					running = true
+					break
+				}
+			}
+			if !running {
+				errs = append(errs, fmt.Errorf("%s is not running on node %q", component, ns.Host))
+			}
+		}
+	}
+	return errs
+}
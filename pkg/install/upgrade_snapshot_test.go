@@ -0,0 +1,227 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/blang/semver"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestParsePackageVersions(t *testing.T) {
+	out := `kubelet 1.17.3-0
+kubectl 1.17.3-0
+docker-ce 17.03.2.ce-1.el7.centos
+`
+	expected := map[string]string{
+		"kubelet":   "1.17.3-0",
+		"kubectl":   "1.17.3-0",
+		"docker-ce": "17.03.2.ce-1.el7.centos",
+	}
+	if got := parsePackageVersions(out); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, but got %v", expected, got)
+	}
+}
+
+func writeTestUpgradeSnapshot(t *testing.T, generatedAssetsDir string, s UpgradeSnapshot) {
+	dir := filepath.Join(upgradeSnapshotsDir(generatedAssetsDir), s.Name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("error creating snapshot directory: %v", err)
+	}
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		t.Fatalf("error marshalling snapshot: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, upgradeSnapshotFile), b, 0600); err != nil {
+		t.Fatalf("error writing snapshot: %v", err)
+	}
+}
+
+func TestGetUpgradeSnapshot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ket-upgrade-snapshot-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if _, err := GetUpgradeSnapshot(tmpDir, ""); err == nil {
+		t.Error("expected an error when there are no snapshots")
+	}
+	older := UpgradeSnapshot{Name: "older", CreatedAt: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), PreUpgrade: true}
+	newer := UpgradeSnapshot{
+		Name:       "newer",
+		CreatedAt:  time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+		PreUpgrade: true,
+		Nodes: []NodeSnapshot{
+			{Host: "worker", IP: "10.0.0.1", Roles: []string{"worker"}, KismaticVersion: "1.1.0", Packages: map[string]string{"kubelet": "1.17.3-0"}},
+		},
+	}
+	// taken when retrying a partially applied upgrade
+	retry := UpgradeSnapshot{Name: "retry", CreatedAt: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)}
+	writeTestUpgradeSnapshot(t, tmpDir, older)
+	writeTestUpgradeSnapshot(t, tmpDir, newer)
+	writeTestUpgradeSnapshot(t, tmpDir, retry)
+
+	snapshots, err := ListUpgradeSnapshots(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error listing snapshots: %v", err)
+	}
+	if len(snapshots) != 3 || snapshots[0].Name != "retry" || snapshots[1].Name != "newer" || snapshots[2].Name != "older" {
+		t.Errorf("expected snapshots to be sorted by creation time, but got %v", snapshots)
+	}
+	latest, err := GetUpgradeSnapshot(tmpDir, "")
+	if err != nil {
+		t.Fatalf("unexpected error getting latest snapshot: %v", err)
+	}
+	if latest.Name != "newer" || latest.Directory != filepath.Join(tmpDir, "upgrade-snapshots", "newer") {
+		t.Errorf("expected the latest pre-upgrade snapshot, but got %+v", latest)
+	}
+	if latest.Nodes[0].Packages["kubelet"] != "1.17.3-0" {
+		t.Errorf("expected recorded package versions, but got %v", latest.Nodes[0].Packages)
+	}
+	named, err := GetUpgradeSnapshot(tmpDir, "older")
+	if err != nil {
+		t.Fatalf("unexpected error getting snapshot: %v", err)
+	}
+	if named.Name != "older" {
+		t.Errorf("expected snapshot %q, but got %q", "older", named.Name)
+	}
+	if named, err = GetUpgradeSnapshot(tmpDir, "retry"); err != nil || named.Name != "retry" {
+		t.Errorf("expected snapshot %q, but got %v, %v", "retry", named, err)
+	}
+}
+
+func TestRestoreGeneratedAssets(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ket-upgrade-snapshot-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	generated := filepath.Join(tmpDir, "generated")
+	planFile := filepath.Join(tmpDir, "kismatic-cluster.yaml")
+	if err := os.MkdirAll(filepath.Join(generated, "keys"), 0700); err != nil {
+		t.Fatalf("error creating generated assets directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(generated, "keys", "ca.pem"), []byte("old"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if err := ioutil.WriteFile(planFile, []byte("old plan"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	s := UpgradeSnapshot{Name: "snapshot", Directory: filepath.Join(upgradeSnapshotsDir(generated), "snapshot")}
+	if err := os.MkdirAll(s.Directory, 0700); err != nil {
+		t.Fatalf("error creating snapshot directory: %v", err)
+	}
+	if err := util.ArchiveDirectory(generated, filepath.Join(s.Directory, upgradeSnapshotAssetsFile), upgradeSnapshotsDirName); err != nil {
+		t.Fatalf("error archiving generated assets: %v", err)
+	}
+	if err := ioutil.WriteFile(s.PlanFile(), []byte("old plan"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	// Simulate the upgrade
+	if err := ioutil.WriteFile(filepath.Join(generated, "keys", "ca.pem"), []byte("new"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if err := ioutil.WriteFile(planFile, []byte("new plan"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	if err := RestoreGeneratedAssets(s, planFile, generated); err != nil {
+		t.Fatalf("unexpected error restoring generated assets: %v", err)
+	}
+	for file, expected := range map[string]string{filepath.Join(generated, "keys", "ca.pem"): "old", planFile: "old plan"} {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("error reading file: %v", err)
+		}
+		if string(b) != expected {
+			t.Errorf("expected %q to contain %q, but got %q", file, expected, string(b))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.Directory, upgradeSnapshotAssetsFile)); err != nil {
+		t.Errorf("expected the snapshot to be left untouched, but got %v", err)
+	}
+}
+
+func TestVerifyRollback(t *testing.T) {
+	s := UpgradeSnapshot{
+		Nodes: []NodeSnapshot{
+			{Host: "master", Roles: []string{"master"}, KismaticVersion: "1.1.0", ComponentVersions: ComponentVersions{Kubernetes: "v1.17.3"}},
+			{Host: "worker", Roles: []string{"worker"}, KismaticVersion: "1.1.0", ComponentVersions: ComponentVersions{Kubernetes: "v1.17.3"}},
+			{Host: "etcd", Roles: []string{"etcd"}, KismaticVersion: "1.1.0"},
+		},
+	}
+	old := semver.Version{Major: 1, Minor: 1, Patch: 0}
+	current := semver.Version{Major: 1, Minor: 2, Patch: 0}
+	cv := ClusterVersion{
+		Nodes: []ListableNode{
+			{Node: Node{Host: "master"}, Version: old, ComponentVersions: ComponentVersions{Kubernetes: "v1.17.3"}},
+			{Node: Node{Host: "worker"}, Version: current, ComponentVersions: ComponentVersions{Kubernetes: "v1.18.3"}},
+			{Node: Node{Host: "etcd"}, Version: old},
+			{Node: Node{Host: "unknown"}, Version: current, ComponentVersions: ComponentVersions{Kubernetes: "v1.18.3"}},
+		},
+	}
+	ready := data.NodeStatus{Conditions: []data.NodeCondition{{Type: "Ready", Status: "True"}}}
+	controlPlanePods := func(phase string) *data.PodList {
+		pods := &data.PodList{}
+		for _, c := range controlPlaneComponents {
+			pods.Items = append(pods.Items, data.Pod{
+				ObjectMeta: data.ObjectMeta{Labels: map[string]string{"component": c, "kismatic/host": "master"}},
+				Status:     data.PodStatus{Phase: phase},
+			})
+		}
+		return pods
+	}
+	tests := []struct {
+		name         string
+		nodes        []data.Node
+		pods         *data.PodList
+		expectedErrs int
+	}{
+		{
+			name: "healthy nodes, worker at the wrong versions",
+			nodes: []data.Node{
+				{ObjectMeta: data.ObjectMeta{Name: "master"}, Status: ready},
+				{ObjectMeta: data.ObjectMeta{Name: "worker"}, Status: ready},
+			},
+			pods:         controlPlanePods(podPhaseRunning),
+			expectedErrs: 2,
+		},
+		{
+			name: "worker is not ready",
+			nodes: []data.Node{
+				{ObjectMeta: data.ObjectMeta{Name: "master"}, Status: ready},
+				{ObjectMeta: data.ObjectMeta{Name: "worker"}},
+			},
+			pods:         controlPlanePods(podPhaseRunning),
+			expectedErrs: 3,
+		},
+		{
+			name: "worker is not registered, control plane is not running",
+			nodes: []data.Node{
+				{ObjectMeta: data.ObjectMeta{Name: "master"}, Status: ready},
+			},
+			pods:         controlPlanePods("Pending"),
+			expectedErrs: 6,
+		},
+	}
+	for _, test := range tests {
+		pods := test.pods
+		client := fakeUpgradePlanKubeClient{
+			fakeUpgradeKubeClient: fakeUpgradeKubeClient{listPods: func() (*data.PodList, error) { return pods, nil }},
+			nodes:                 &data.NodeList{Items: test.nodes},
+		}
+		errs := VerifyRollback(s, cv, client)
+		if len(errs) != test.expectedErrs {
+			t.Errorf("%s: expected %d errors, but got %v", test.name, test.expectedErrs, errs)
+		}
+	}
+}
@@ -17,6 +17,9 @@ type fakeUpgradeKubeClient struct {
 	getPersistentVolume      func(name string) (*data.PersistentVolume, error)
 	getPersistentVolumeClaim func(name string) (*data.PersistentVolumeClaim, error)
 	getStatefulSet           func() (*data.StatefulSet, error)
+	getDeployment            func() (*data.Deployment, error)
+	getJob                   func() (*data.Job, error)
+	listPDBs                 func() (*data.PodDisruptionBudgetList, error)
 }
 
 func (f fakeUpgradeKubeClient) ListPods() (*data.PodList, error) {
@@ -68,6 +71,90 @@ func (f fakeUpgradeKubeClient) GetStatefulSet(namespace, name string) (*data.Sta
 	return nil, errors.New("StatefulSet not found")
 }
 
+func (f fakeUpgradeKubeClient) GetDeployment(namespace, name string) (*data.Deployment, error) {
+	if f.getDeployment != nil {
+		return f.getDeployment()
+	}
+	return nil, errors.New("Deployment not found")
+}
+
+func (f fakeUpgradeKubeClient) GetJob(namespace, name string) (*data.Job, error) {
+	if f.getJob != nil {
+		return f.getJob()
+	}
+	return &data.Job{}, nil
+}
+
+func (f fakeUpgradeKubeClient) ListPodDisruptionBudgets() (*data.PodDisruptionBudgetList, error) {
+	if f.listPDBs != nil {
+		return f.listPDBs()
+	}
+	return &data.PodDisruptionBudgetList{}, nil
+}
+
+type fakeUpgradeGlusterClient struct {
+	volumes  *data.GlusterVolumeInfoCliOutput
+	status   map[string]*data.GlusterVolumeStatusCliOutput
+	healInfo map[string]*data.GlusterVolumeHealInfoCliOutput
+}
+
+func (f fakeUpgradeGlusterClient) ListVolumes() (*data.GlusterVolumeInfoCliOutput, error) {
+	return f.volumes, nil
+}
+
+func (f fakeUpgradeGlusterClient) GetVolumeStatus(volume string) (*data.GlusterVolumeStatusCliOutput, error) {
+	if s, ok := f.status[volume]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("volume %s does not exist", volume)
+}
+
+func (f fakeUpgradeGlusterClient) GetHealInfo(volume string) (*data.GlusterVolumeHealInfoCliOutput, error) {
+	if h, ok := f.healInfo[volume]; ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("volume %s does not exist", volume)
+}
+
+// glusterVolume returns a fake gluster client with a single volume made up of the
+// given bricks. Bricks in offlineBricks are offline, and pendingHeal
+// contains the number of entries pending self-heal for each brick.
+func glusterVolume(name string, replicaCount uint, bricks []string, offlineBricks []string, pendingHeal map[string]string) fakeUpgradeGlusterClient {
+	vol := &data.GlusterVolume{Name: name, ReplicaCount: replicaCount, Bricks: &data.GlusterBricks{}}
+	statusVol := &data.GlusterVolumeStatusVolume{Name: name}
+	heal := &data.GlusterHealInfoBricks{}
+	for _, b := range bricks {
+		vol.Bricks.Brick = append(vol.Bricks.Brick, &data.GlusterBrick{Text: b})
+		parts := strings.SplitN(b, ":", 2)
+		status := 1
+		entries := "0"
+		for _, o := range offlineBricks {
+			if o == b {
+				status = 0
+				entries = "-"
+			}
+		}
+		if e, ok := pendingHeal[b]; ok {
+			entries = e
+		}
+		statusVol.Nodes = append(statusVol.Nodes, &data.GlusterVolumeStatusNode{Hostname: parts[0], Path: parts[1], Status: status})
+		heal.Brick = append(heal.Brick, &data.GlusterHealInfoBrick{Name: b, NumberOfEntries: entries})
+	}
+	return fakeUpgradeGlusterClient{
+		volumes: &data.GlusterVolumeInfoCliOutput{
+			VolumeInfo: &data.GlusterVolumeInfo{
+				Volumes: &data.GlusterVolumes{Count: 1, Volume: []*data.GlusterVolume{vol}},
+			},
+		},
+		status: map[string]*data.GlusterVolumeStatusCliOutput{
+			name: {VolumeStatus: &data.GlusterVolumeStatus{Volumes: &data.GlusterVolumeStatusVolumes{Volume: []*data.GlusterVolumeStatusVolume{statusVol}}}},
+		},
+		healInfo: map[string]*data.GlusterVolumeHealInfoCliOutput{
+			name: {HealInfo: &data.GlusterHealInfo{Bricks: heal}},
+		},
+	}
+}
+
 func getSafePodWithCreatedByRef(t *testing.T, nodeName string, createdByKind string) data.Pod {
 	pod := data.Pod{
 		ObjectMeta: data.ObjectMeta{
@@ -98,7 +185,7 @@ func TestDetectNodeUpgradeSafetyEtcdCountUnsafe(t *testing.T) {
 	}
 	node := plan.Etcd.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(etcdNodeCountErr); !ok {
@@ -121,7 +208,7 @@ func TestDetectNodeUpgradeSafetyMasterCountUnsafe(t *testing.T) {
 	}
 	node := plan.Master.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(masterNodeCountErr); !ok {
@@ -148,7 +235,7 @@ func TestDetectNodeUpgradeSafetyMasterLoadBalancingUnsafe(t *testing.T) {
 	}
 	node := plan.Master.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(masterNodeLoadBalancingErr); !ok {
@@ -175,7 +262,7 @@ func TestDetectNodeUpgradeSafetyMasterLoadBalancingSafe(t *testing.T) {
 	}
 	node := plan.Master.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 0 {
 		t.Errorf("did not expect an error, but got %d", len(errs))
 	}
@@ -195,7 +282,7 @@ func TestDetectNodeUpgradeSafetyIngress(t *testing.T) {
 	}
 	node := plan.Ingress.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(ingressNotSupportedErr); !ok {
@@ -206,22 +293,80 @@ func TestDetectNodeUpgradeSafetyIngress(t *testing.T) {
 func TestDetectNodeUpgradeSafetyStorage(t *testing.T) {
 	plan := Plan{
 		Storage: OptionalNodeGroup{
-			ExpectedCount: 1,
+			ExpectedCount: 3,
 			Nodes: []Node{
-				{
-					Host: "foo",
-					IP:   "10.0.0.1",
-				},
+				{Host: "foo", IP: "10.0.0.1"},
+				{Host: "bar", IP: "10.0.0.2"},
+				{Host: "baz", IP: "10.0.0.3"},
 			},
 		},
 	}
 	node := plan.Storage.Nodes[0]
-	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
-	if len(errs) != 1 {
-		t.Errorf("Expected %d errors, but got %v", 1, errs)
-	} else if _, ok := errs[0].(storageNotSupportedErr); !ok {
-		t.Errorf("Expected storageUnsupportedError, but got %v", errs[0])
+	replicated := []string{"foo:/data/vol", "bar:/data/vol"}
+	tests := []struct {
+		name          string
+		glusterClient fakeUpgradeGlusterClient
+		expectedErr   error
+	}{
+		{
+			name:          "no volumes",
+			glusterClient: fakeUpgradeGlusterClient{},
+		},
+		{
+			name:          "volume without a brick on the node",
+			glusterClient: glusterVolume("vol", 1, []string{"bar:/data/vol"}, nil, nil),
+		},
+		{
+			name:          "healthy replicated volume",
+			glusterClient: glusterVolume("vol", 2, replicated, nil, nil),
+		},
+		{
+			name:          "brick on the node is offline",
+			glusterClient: glusterVolume("vol", 2, replicated, []string{"foo:/data/vol"}, nil),
+		},
+		{
+			name:          "distributed replicated volume with offline brick in another replica set",
+			glusterClient: glusterVolume("vol", 2, []string{"foo:/data/vol", "bar:/data/vol", "bar:/data/vol2", "baz:/data/vol"}, []string{"baz:/data/vol"}, nil),
+		},
+		{
+			name:          "volume is not replicated",
+			glusterClient: glusterVolume("vol", 1, []string{"foo:/data/vol", "bar:/data/vol"}, nil, nil),
+			expectedErr:   unreplicatedVolumeErr{volume: "vol"},
+		},
+		{
+			name:          "replica is offline",
+			glusterClient: glusterVolume("vol", 2, replicated, []string{"bar:/data/vol"}, nil),
+			expectedErr:   replicaBrickOfflineErr{volume: "vol", brick: "bar:/data/vol"},
+		},
+		{
+			name:          "entries pending self-heal",
+			glusterClient: glusterVolume("vol", 2, replicated, nil, map[string]string{"foo:/data/vol": "3"}),
+			expectedErr:   pendingHealErr{volume: "vol", brick: "foo:/data/vol", entries: "3"},
+		},
+		{
+			name:          "all replicas on the node",
+			glusterClient: glusterVolume("vol", 2, []string{"foo:/data/vol", "foo:/data/vol2"}, nil, nil),
+			expectedErr:   noHealthyReplicaErr{volume: "vol", brick: "foo:/data/vol"},
+		},
+		{
+			name:          "brick referenced by IP",
+			glusterClient: glusterVolume("vol", 2, []string{"10.0.0.1:/data/vol", "10.0.0.2:/data/vol"}, []string{"10.0.0.2:/data/vol"}, nil),
+			expectedErr:   replicaBrickOfflineErr{volume: "vol", brick: "10.0.0.2:/data/vol"},
+		},
+	}
+	for _, test := range tests {
+		errs := DetectNodeUpgradeSafety(plan, node, fakeUpgradeKubeClient{}, test.glusterClient)
+		if test.expectedErr == nil {
+			if len(errs) != 0 {
+				t.Errorf("%s: expected no errors, but got %v", test.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, but got %v", test.name, errs)
+		} else if errs[0] != test.expectedErr {
+			t.Errorf("%s: expected error %v, but got %v", test.name, test.expectedErr, errs[0])
+		}
 	}
 }
 
@@ -239,7 +384,7 @@ func TestDetectNodeUpgradeSafetyWorkerCountUnsafe(t *testing.T) {
 	}
 	node := plan.Worker.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(workerNodeCountErr); !ok {
@@ -265,7 +410,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodListError(t *testing.T) {
 	}
 	node := plan.Worker.Nodes[0]
 	k8sClient := fakeUpgradeKubeClient{listPods: func() (*data.PodList, error) { return nil, errors.New("some error") }}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if !strings.Contains(errs[0].Error(), "some error") {
@@ -312,7 +457,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodHostPathVol(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafeVolumeErr); !ok {
@@ -361,7 +506,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodEmptyDirVol(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafeVolumeErr); !ok {
@@ -432,7 +577,7 @@ func TestDetectNodeUpgradeSafetyWorkerPodHostPathPersistentVol(t *testing.T) {
 			return nil, fmt.Errorf("PV not found")
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafePersistentVolumeErr); !ok {
@@ -476,7 +621,7 @@ func TestDetectNodeUpgradeSafetyWorkerSingleDaemon(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podUnsafeDaemonErr); !ok {
@@ -520,7 +665,7 @@ func TestDetectNodeUpgradeSafetyWorkerSafeDaemon(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 0 {
 		t.Errorf("Did not expect errors, but got: %v", errs)
 	}
@@ -553,7 +698,7 @@ func TestDetectNodeUpgradeSafetyLonePod(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(unmanagedPodErr); !ok {
@@ -596,7 +741,7 @@ func TestDetectNodeUpgradeSafetyUnreplicatedController(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 2 {
 		t.Fatalf("Expected %d errors, but got %v", 2, errs)
 	}
@@ -642,7 +787,7 @@ func TestDetectNodeUpgradeSafetyUnreplicatedReplicaSet(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 2 {
 		t.Fatalf("Expected %d errors, but got %v", 2, errs)
 	}
@@ -688,7 +833,7 @@ func TestDetectNodeUpgradeSafetyUnreplicatedStatefulSet(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(unsafeReplicaCountErr); !ok {
@@ -723,7 +868,7 @@ func TestDetectNodeUpgradeSafetyJobRunningOnNode(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(podRunningJobErr); !ok {
@@ -766,7 +911,7 @@ func TestDetectNodeUpgradeSafetyAllReplicaSetPodsSameNode(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(replicasOnSingleNodeErr); !ok {
@@ -809,10 +954,176 @@ func TestDetectNodeUpgradeSafetyAllReplicaControllerPodsSameNode(t *testing.T) {
 			}, nil
 		},
 	}
-	errs := DetectNodeUpgradeSafety(plan, node, k8sClient)
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
 	if len(errs) != 1 {
 		t.Errorf("Expected %d errors, but got %v", 1, errs)
 	} else if _, ok := errs[0].(replicasOnSingleNodeErr); !ok {
 		t.Errorf("expected replicasOnSingleNodeErr, but got %T", errs[0])
 	}
 }
+
+func TestDetectNodeUpgradeSafetyReplicaSetOwnedByDeployment(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{
+					Host: "foo",
+					IP:   "10.0.0.1",
+				},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+
+	pod := getSafePodWithCreatedByRef(t, node.Host, "ReplicaSet")
+	k8sClient := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{
+				Items: []data.Pod{pod},
+			}, nil
+		},
+		getReplicaSet: func() (*data.ReplicaSet, error) {
+			return &data.ReplicaSet{
+				ObjectMeta: data.ObjectMeta{
+					OwnerReferences: []data.OwnerReference{{Kind: "Deployment", Name: "bar", Controller: true}},
+				},
+				Status: data.ReplicaSetStatus{
+					Replicas: 1,
+				},
+			}, nil
+		},
+		getDeployment: func() (*data.Deployment, error) {
+			return &data.Deployment{
+				Status: data.DeploymentStatus{
+					Replicas: 1,
+				},
+			}, nil
+		},
+	}
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+	if len(errs) != 2 {
+		t.Fatalf("Expected %d errors, but got %v", 2, errs)
+	}
+	err, ok := errs[0].(unsafeReplicaCountErr)
+	if !ok {
+		t.Fatalf("expected unsafeReplicaCountErr, but got %T", errs[0])
+	}
+	if err.kind != "Deployment" || err.name != "bar" {
+		t.Errorf("expected the error to refer to Deployment bar, but got %s %s", err.kind, err.name)
+	}
+}
+
+func TestDetectNodeUpgradeSafetyCronJobRunningOnNode(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{
+					Host: "foo",
+					IP:   "10.0.0.1",
+				},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+
+	pod := getSafePodWithCreatedByRef(t, node.Host, "Job")
+	k8sClient := fakeUpgradeKubeClient{
+		listPods: func() (*data.PodList, error) {
+			return &data.PodList{
+				Items: []data.Pod{pod},
+			}, nil
+		},
+		getJob: func() (*data.Job, error) {
+			return &data.Job{
+				ObjectMeta: data.ObjectMeta{
+					OwnerReferences: []data.OwnerReference{{Kind: "CronJob", Name: "bar", Controller: true}},
+				},
+			}, nil
+		},
+	}
+	errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors, but got %v", errs)
+	}
+}
+
+func TestDetectNodeUpgradeSafetyPodDisruptionBudget(t *testing.T) {
+	plan := Plan{
+		Worker: NodeGroup{
+			ExpectedCount: 2,
+			Nodes: []Node{
+				{
+					Host: "foo",
+					IP:   "10.0.0.1",
+				},
+			},
+		},
+	}
+	node := plan.Worker.Nodes[0]
+
+	tests := []struct {
+		disruptionsAllowed int32
+		expectedErr        bool
+	}{
+		{
+			disruptionsAllowed: 0,
+			expectedErr:        true,
+		},
+		{
+			disruptionsAllowed: 1,
+			expectedErr:        true,
+		},
+		{
+			disruptionsAllowed: 2,
+		},
+	}
+	for i, test := range tests {
+		pod1 := getSafePodWithCreatedByRef(t, node.Host, "ReplicaSet")
+		pod1.Labels = map[string]string{"app": "foo"}
+		pod2 := getSafePodWithCreatedByRef(t, node.Host, "ReplicaSet")
+		pod2.Name = "foo-2"
+		pod2.Labels = map[string]string{"app": "foo"}
+		k8sClient := fakeUpgradeKubeClient{
+			listPods: func() (*data.PodList, error) {
+				return &data.PodList{
+					Items: []data.Pod{pod1, pod2},
+				}, nil
+			},
+			// The replica count would be unsafe if the pods were not covered by the PDB
+			getReplicaSet: func() (*data.ReplicaSet, error) {
+				return &data.ReplicaSet{
+					Status: data.ReplicaSetStatus{
+						Replicas: 1,
+					},
+				}, nil
+			},
+			listPDBs: func() (*data.PodDisruptionBudgetList, error) {
+				return &data.PodDisruptionBudgetList{
+					Items: []data.PodDisruptionBudget{
+						{
+							ObjectMeta: data.ObjectMeta{Name: "foo-pdb", Namespace: "foo"},
+							Spec: data.PodDisruptionBudgetSpec{
+								Selector: &data.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+							},
+							Status: data.PodDisruptionBudgetStatus{DisruptionsAllowed: test.disruptionsAllowed},
+						},
+					},
+				}, nil
+			},
+		}
+		errs := DetectNodeUpgradeSafety(plan, node, k8sClient, fakeUpgradeGlusterClient{})
+		if test.expectedErr {
+			if len(errs) != 1 {
+				t.Errorf("%d: Expected %d errors, but got %v", i, 1, errs)
+			} else if _, ok := errs[0].(podDisruptionBudgetErr); !ok {
+				t.Errorf("%d: expected podDisruptionBudgetErr, but got %T", i, errs[0])
+			}
+			continue
+		}
+		if len(errs) != 0 {
+			t.Errorf("%d: Expected no errors, but got %v", i, errs)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/apprenda/kismatic/pkg/validation"
 
 	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/tls"
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
@@ -75,6 +76,9 @@ func ValidateSSHConnection(con *SSHConnection, prefix string) (bool, []error) {
 func ValidateCertificates(p *Plan, pki *LocalPKI) (bool, []error) {
 	v := newValidator()
 
+	if err := pki.ValidateClusterCA(p); err != nil {
+		v.addError(err)
+	}
 	warn, err := pki.ValidateClusterCertificates(p)
 	if err != nil && len(err) > 0 {
 		v.addError(err...)
@@ -192,6 +196,10 @@ func (c *Cluster) validate() (bool, []error) {
 	v.validate(&c.KubeSchedulerOptions)
 	v.validate(&c.KubeletOptions)
 	v.validate(&c.CloudProvider)
+	v.validate(&c.EncryptionAtRest)
+	if _, ok := c.APIServerOptions.Overrides[encryptionProviderConfigFlag]; ok && c.EncryptionAtRest.Enabled {
+		v.addError(fmt.Errorf("Kube ApiServer Option %q cannot be overridden when encryption at rest is enabled", encryptionProviderConfigFlag))
+	}
 
 	return v.valid()
 }
@@ -222,6 +230,31 @@ func (c *CertsConfig) validate() (bool, []error) {
 	if _, err := time.ParseDuration(c.CAExpiry); c.CAExpiry != "" && err != nil { // don't error when empty for backwards compat
 		v.addError(fmt.Errorf("Invalid CA certificate expiry %q provider: %v", c.CAExpiry, err))
 	}
+	if c.CACert == "" && (c.CAKey != "" || c.CAChain != "") {
+		v.addError(errors.New("CA certificate is required when a CA key or chain is provided"))
+	}
+	if c.CACert != "" && c.CAKey == "" {
+		v.addError(errors.New("CA key is required when a CA certificate is provided"))
+	}
+	for _, f := range []string{c.CACert, c.CAKey, c.CAChain} {
+		if _, err := os.Stat(f); f != "" && os.IsNotExist(err) {
+			v.addError(fmt.Errorf("CA file was not found at %q", f))
+		}
+	}
+	if c.KeyAlgorithm != "" && !util.Contains(c.KeyAlgorithm, tls.KeyAlgorithms()) {
+		v.addError(fmt.Errorf("%q is not a valid key algorithm. Options are %v", c.KeyAlgorithm, tls.KeyAlgorithms()))
+	}
+	if c.CAKeyAlgorithm != "" && !util.Contains(c.CAKeyAlgorithm, tls.KeyAlgorithms()) {
+		v.addError(fmt.Errorf("%q is not a valid CA key algorithm. Options are %v", c.CAKeyAlgorithm, tls.KeyAlgorithms()))
+	}
+	return v.valid()
+}
+
+func (e *EncryptionAtRest) validate() (bool, []error) {
+	v := newValidator()
+	if e.Enabled && !util.Contains(e.Provider, encryptionProviders()) {
+		v.addError(fmt.Errorf("%q is not a valid encryption provider. Options are %v", e.Provider, encryptionProviders()))
+	}
 	return v.valid()
 }
 
@@ -1626,6 +1626,45 @@ func TestCloudProvider(t *testing.T) {
 	}
 }
 
+func TestEncryptionAtRest(t *testing.T) {
+	tests := []struct {
+		e     EncryptionAtRest
+		valid bool
+	}{
+		{
+			e:     EncryptionAtRest{},
+			valid: true,
+		},
+		{
+			e: EncryptionAtRest{
+				Enabled:  true,
+				Provider: "aescbc",
+			},
+			valid: true,
+		},
+		{
+			e: EncryptionAtRest{
+				Enabled:  true,
+				Provider: "secretbox",
+			},
+			valid: true,
+		},
+		{
+			e: EncryptionAtRest{
+				Enabled:  true,
+				Provider: "kms",
+			},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		ok, _ := test.e.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expect %t, but got %t", i, test.valid, ok)
+		}
+	}
+}
+
 func TestNodeLabels(t *testing.T) {
 	tests := []struct {
 		n     Node
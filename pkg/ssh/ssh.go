@@ -45,6 +45,14 @@ func TestConnection(ip string, port int, user, key string) error {
 	return client.Shell(false, "exit")
 }
 
+// ConnectionError returns true if the ssh command failed because the connection
+// could not be established or was closed, rather than because of the remote command
+func ConnectionError(err error) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	// ssh exits with 255 when an error occurs on its side
+	return ok && exitErr.ExitCode() == 255
+}
+
 // NewClient verifies ssh is available in the PATH and returns an SSH client
 func NewClient(host string, port int, user string, key string) (Client, error) {
 	if err := ValidUnencryptedPrivateKey(key); err != nil {
@@ -26,7 +26,8 @@ type Subject struct {
 }
 
 // NewCACert creates a new Certificate Authority and returns it's private key and public certificate.
-func NewCACert(csrFile string, commonName string, expiry string) (key, cert []byte, err error) {
+// The key algorithm and the subject of the CSR file are used when they are not set.
+func NewCACert(csrFile string, commonName string, expiry string, keyAlgorithm string, subject Subject) (key, cert []byte, err error) {
 	// Open CSR file
 	f, err := os.Open(csrFile)
 	if os.IsNotExist(err) {
@@ -44,6 +45,14 @@ func NewCACert(csrFile string, commonName string, expiry string) (key, cert []by
 		return nil, nil, fmt.Errorf("error decoding CSR: %v", err)
 	}
 	caCSR.CN = commonName
+	if keyAlgorithm != "" {
+		if caCSR.KeyRequest, err = NewKeyRequest(keyAlgorithm); err != nil {
+			return nil, nil, err
+		}
+	}
+	if name := subject.Name(); !csr.IsNameEmpty(name) {
+		caCSR.Names = []csr.Name{name}
+	}
 	caCSR.CA = &csr.CAConfig{Expiry: expiry}
 	// Generate CA Cert according to CSR
 	cert, _, key, err = initca.New(caCSR)
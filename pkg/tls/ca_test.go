@@ -10,7 +10,7 @@ import (
 
 func TestNewCACert(t *testing.T) {
 	duration := 5 * 365 * 24 * time.Hour
-	_, cert, err := NewCACert("test/ca-csr.json", "someCommonName", duration.String())
+	_, cert, err := NewCACert("test/ca-csr.json", "someCommonName", duration.String(), "", Subject{})
 	if err != nil {
 		t.Fatalf("error creating CA cert: %v", err)
 	}
@@ -46,3 +46,24 @@ func TestNewCACert(t *testing.T) {
 		t.Errorf("expected expiration date %q, got %q", expectedExpiration, parsedCert.NotAfter)
 	}
 }
+
+func TestNewCACertKeyAlgorithmAndSubject(t *testing.T) {
+	subject := Subject{Country: "CA", State: "Ontario", Locality: "Toronto", Organization: "Acme", OrganizationalUnit: "Platform"}
+	_, cert, err := NewCACert("test/ca-csr.json", "someCommonName", "1h", KeyAlgorithmECDSAP384, subject)
+	if err != nil {
+		t.Fatalf("error creating CA cert: %v", err)
+	}
+	parsedCert, err := helpers.ParseCertificatePEM(cert)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	if algorithm := KeyAlgorithm(parsedCert); algorithm != KeyAlgorithmECDSAP384 {
+		t.Errorf("expected key algorithm %q, got %q", KeyAlgorithmECDSAP384, algorithm)
+	}
+	s := parsedCert.Subject
+	expected := []string{"CA", "Ontario", "Toronto", "Acme", "Platform"}
+	got := []string{s.Country[0], s.Province[0], s.Locality[0], s.Organization[0], s.OrganizationalUnit[0]}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected subject %v, got %v", expected, got)
+	}
+}
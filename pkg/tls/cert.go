@@ -189,6 +189,23 @@ func CertValid(commonName string, SANs []string, organizations []string, name, d
 	return warn, nil
 }
 
+// CertKeyAlgorithmValid returns a warning if the key algorithm of the certificate is not
+// the expected algorithm. An empty algorithm is not validated.
+// Returns an error if the certificate cannot be read or parsed
+func CertKeyAlgorithmValid(keyAlgorithm string, name, dir string) (warn error, err error) {
+	if keyAlgorithm == "" {
+		return nil, nil
+	}
+	cert, err := ReadCert(name, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cert %s: %v", name, err)
+	}
+	if algorithm := KeyAlgorithm(cert); algorithm != keyAlgorithm {
+		return fmt.Errorf("Certificate %q: the key algorithm is %q instead of %q", certName(name), algorithm, keyAlgorithm), nil
+	}
+	return nil, nil
+}
+
 func keyName(s string) string { return fmt.Sprintf("%s-key.pem", s) }
 
 func certName(s string) string { return fmt.Sprintf("%s.pem", s) }
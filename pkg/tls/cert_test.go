@@ -16,7 +16,7 @@ import (
 )
 
 func TestGenerateNewCertificate(t *testing.T) {
-	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "12345h")
+	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "12345h", "", Subject{})
 	if err != nil {
 		t.Fatalf("error creating CA: %v", err)
 	}
@@ -278,7 +278,7 @@ func TestCertValid(t *testing.T) {
 	}
 	defer cleanup(tempDir, t)
 
-	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "12345h")
+	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "12345h", "", Subject{})
 	if err != nil {
 		t.Fatalf("error creating CA: %v", err)
 	}
@@ -329,6 +329,50 @@ func TestCertValid(t *testing.T) {
 	}
 }
 
+func TestCertKeyAlgorithmValid(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "cert-tests")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer cleanup(tempDir, t)
+	key, caCert, err := NewCACert("test/ca-csr.json", "someCN", "12345h", "", Subject{})
+	if err != nil {
+		t.Fatalf("error creating CA: %v", err)
+	}
+	req := buildReq("node1", nil, nil)
+	if req.KeyRequest, err = NewKeyRequest(KeyAlgorithmECDSAP256); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	certKey, cert, err := NewCert(&CA{Key: key, Cert: caCert}, *req, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	if err = WriteCert(certKey, cert, "node1", tempDir); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+
+	tests := []struct {
+		keyAlgorithm string
+		valid        bool
+	}{
+		{keyAlgorithm: "", valid: true},
+		{keyAlgorithm: KeyAlgorithmECDSAP256, valid: true},
+		{keyAlgorithm: KeyAlgorithmRSA2048, valid: false},
+	}
+	for _, test := range tests {
+		warn, err := CertKeyAlgorithmValid(test.keyAlgorithm, "node1", tempDir)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.keyAlgorithm, err)
+		}
+		if (warn == nil) != test.valid {
+			t.Errorf("%q: expected valid to be %v, but got warning %v", test.keyAlgorithm, test.valid, warn)
+		}
+	}
+	if _, err = CertKeyAlgorithmValid(KeyAlgorithmRSA2048, "doesnotexist", tempDir); err == nil {
+		t.Errorf("expected an error, as the certificate does not exist")
+	}
+}
+
 func buildReq(CN string, SANs []string, organizations []string) *csr.CertificateRequest {
 	req := &csr.CertificateRequest{
 		CN: CN,
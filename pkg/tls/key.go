@@ -0,0 +1,72 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/cloudflare/cfssl/csr"
+)
+
+// The supported algorithms of the private keys
+const (
+	KeyAlgorithmRSA2048   = "rsa-2048"
+	KeyAlgorithmRSA4096   = "rsa-4096"
+	KeyAlgorithmECDSAP256 = "ecdsa-p256"
+	KeyAlgorithmECDSAP384 = "ecdsa-p384"
+)
+
+// KeyAlgorithms returns the supported algorithms of the private keys
+func KeyAlgorithms() []string {
+	return []string{KeyAlgorithmRSA2048, KeyAlgorithmRSA4096, KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384}
+}
+
+// NewKeyRequest returns the request of a private key for the algorithm.
+// An empty algorithm defaults to RSA 2048.
+func NewKeyRequest(algorithm string) (*csr.BasicKeyRequest, error) {
+	switch algorithm {
+	case "", KeyAlgorithmRSA2048:
+		return &csr.BasicKeyRequest{A: "rsa", S: 2048}, nil
+	case KeyAlgorithmRSA4096:
+		return &csr.BasicKeyRequest{A: "rsa", S: 4096}, nil
+	case KeyAlgorithmECDSAP256:
+		return &csr.BasicKeyRequest{A: "ecdsa", S: 256}, nil
+	case KeyAlgorithmECDSAP384:
+		return &csr.BasicKeyRequest{A: "ecdsa", S: 384}, nil
+	}
+	return nil, fmt.Errorf("%q is not a supported key algorithm. Options are %v", algorithm, KeyAlgorithms())
+}
+
+// KeyAlgorithm returns the algorithm of the certificate's key, or an empty
+// string if it is not a supported algorithm
+func KeyAlgorithm(cert *x509.Certificate) string {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return KeyAlgorithmRSA2048
+		case 4096:
+			return KeyAlgorithmRSA4096
+		}
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return KeyAlgorithmECDSAP256
+		case 384:
+			return KeyAlgorithmECDSAP384
+		}
+	}
+	return ""
+}
+
+// Name returns the subject as the name of a certificate request
+func (s Subject) Name() csr.Name {
+	return csr.Name{
+		C:  s.Country,
+		ST: s.State,
+		L:  s.Locality,
+		O:  s.Organization,
+		OU: s.OrganizationalUnit,
+	}
+}
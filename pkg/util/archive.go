@@ -0,0 +1,133 @@
+package util
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveDirectory writes the contents of sourceDir to a gzipped tarball at dest.
+// Paths in the archive are relative to sourceDir. Top-level entries of sourceDir
+// whose name is in exclude are not archived.
+func ArchiveDirectory(sourceDir string, dest string, exclude ...string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("error creating archive %q: %v", dest, err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if Contains(strings.Split(filepath.ToSlash(rel), "/")[0], exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Only regular files and directories are archived
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error archiving %q: %v", sourceDir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing archive %q: %v", dest, err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("error writing archive %q: %v", dest, err)
+	}
+	return nil
+}
+
+// ExtractArchive extracts the gzipped tarball into destDir, overwriting
+// any existing files.
+func ExtractArchive(archive string, destDir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("error opening archive %q: %v", archive, err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("error reading archive %q: %v", archive, err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive %q: %v", archive, err)
+		}
+		target := filepath.Join(destDir, filepath.FromSlash(hdr.Name))
+		// Don't allow entries to escape the destination directory
+		if target != filepath.Clean(destDir) && !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path %q in archive %q", hdr.Name, archive)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)|0700); err != nil {
+				return fmt.Errorf("error creating directory %q: %v", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("error creating directory %q: %v", filepath.Dir(target), err)
+			}
+			if err := extractFile(tr, target, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("error creating file %q: %v", target, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("error writing file %q: %v", target, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveAndExtractDirectory(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ket-archive-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	sourceDir := filepath.Join(tmpDir, "generated")
+	files := map[string]string{
+		"kubeconfig":          "config",
+		"keys/ca.pem":         "ca",
+		"excluded/snapshot":   "snapshot",
+		"keys/nested/foo.pem": "foo",
+	}
+	for name, contents := range files {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error creating directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+	}
+
+	archive := filepath.Join(tmpDir, "assets.tar.gz")
+	if err := ArchiveDirectory(sourceDir, archive, "excluded"); err != nil {
+		t.Fatalf("unexpected error archiving directory: %v", err)
+	}
+	destDir := filepath.Join(tmpDir, "restored")
+	if err := ExtractArchive(archive, destDir); err != nil {
+		t.Fatalf("unexpected error extracting archive: %v", err)
+	}
+	for name, contents := range files {
+		b, err := ioutil.ReadFile(filepath.Join(destDir, name))
+		if name == "excluded/snapshot" {
+			if !os.IsNotExist(err) {
+				t.Errorf("expected %q to be excluded from the archive", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("error reading extracted file %q: %v", name, err)
+			continue
+		}
+		if string(b) != contents {
+			t.Errorf("expected %q to contain %q, but got %q", name, contents, string(b))
+		}
+	}
+}